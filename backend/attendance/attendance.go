@@ -0,0 +1,197 @@
+// Package attendance tracks missed appointments: late cancellations,
+// no-show statistics and the policy that asks caregivers to confirm bookings
+// of patients who keep missing appointments.
+package attendance
+
+import (
+	"dementicare-backend/models"
+	"dementicare-backend/scheduling"
+	"dementicare-backend/settings"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultLateCancellationWindow = 24 * time.Hour
+	defaultPolicyWindow           = 90 * 24 * time.Hour
+)
+
+// Statuses of appointments whose outcome is known
+var OutcomeStatuses = []string{"completed", "no_show", "cancelled"}
+
+// LateCancellationWindow is how close to the start a cancellation by the
+// patient's side counts as late (LATE_CANCELLATION_WINDOW, default 24h)
+func LateCancellationWindow() time.Duration {
+	return settings.Duration("LATE_CANCELLATION_WINDOW", defaultLateCancellationWindow)
+}
+
+// IsLateCancellation reports whether cancelling an appointment that starts
+// at start is late when done at now
+func IsLateCancellation(start, now time.Time) bool {
+	return start.Sub(now) < LateCancellationWindow()
+}
+
+// Policy requires caregiver confirmation for new bookings of patients with
+// at least Threshold no-shows within Window. A zero Threshold disables it.
+type Policy struct {
+	Threshold int           `json:"threshold"`
+	Window    time.Duration `json:"-"`
+}
+
+// CurrentPolicy reads NO_SHOW_CONFIRMATION_THRESHOLD (default 0, off) and
+// NO_SHOW_CONFIRMATION_WINDOW (default 2160h, 90 days)
+func CurrentPolicy() Policy {
+	policy := Policy{Window: settings.Duration("NO_SHOW_CONFIRMATION_WINDOW", defaultPolicyWindow)}
+	if value := os.Getenv("NO_SHOW_CONFIRMATION_THRESHOLD"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			log.Printf("Invalid NO_SHOW_CONFIRMATION_THRESHOLD %q, the policy is off", value)
+			return policy
+		}
+		policy.Threshold = n
+	}
+	return policy
+}
+
+// RecentNoShows counts the patient's no-shows within the policy window
+func (p Policy) RecentNoShows(db *gorm.DB, patientUserID uint) (int64, error) {
+	var count int64
+	err := db.Model(&models.Appointment{}).
+		Where("patient_id = ? AND status = ? AND start_at >= ?", patientUserID, "no_show", time.Now().Add(-p.Window)).
+		Count(&count).Error
+	return count, err
+}
+
+// Applies reports whether new bookings of the patient need a caregiver's
+// confirmation
+func (p Policy) Applies(db *gorm.DB, patientUserID uint) (bool, error) {
+	if p.Threshold <= 0 {
+		return false, nil
+	}
+	count, err := p.RecentNoShows(db, patientUserID)
+	if err != nil {
+		return false, err
+	}
+	return count >= int64(p.Threshold), nil
+}
+
+// Counts summarizes the outcomes of a set of appointments. The no-show rate
+// is taken over appointments that were kept or missed; the late cancellation
+// rate over all of them.
+type Counts struct {
+	Appointments         int     `json:"appointments"`
+	Completed            int     `json:"completed"`
+	NoShows              int     `json:"no_shows"`
+	Cancellations        int     `json:"cancellations"`
+	LateCancellations    int     `json:"late_cancellations"`
+	NoShowRate           float64 `json:"no_show_rate"`
+	LateCancellationRate float64 `json:"late_cancellation_rate"`
+}
+
+func (c *Counts) add(a models.Appointment) {
+	c.Appointments++
+	switch a.Status {
+	case "completed":
+		c.Completed++
+	case "no_show":
+		c.NoShows++
+	case "cancelled":
+		c.Cancellations++
+		if a.LateCancellation {
+			c.LateCancellations++
+		}
+	}
+}
+
+func (c Counts) withRates() Counts {
+	if attended := c.Completed + c.NoShows; attended > 0 {
+		c.NoShowRate = float64(c.NoShows) / float64(attended)
+	}
+	if c.Appointments > 0 {
+		c.LateCancellationRate = float64(c.LateCancellations) / float64(c.Appointments)
+	}
+	return c
+}
+
+// Summarize counts the outcomes of the appointments
+func Summarize(appointments []models.Appointment) Counts {
+	var counts Counts
+	for _, a := range appointments {
+		counts.add(a)
+	}
+	return counts.withRates()
+}
+
+// Group is the counts for one weekday, hour or appointment type
+type Group struct {
+	Key string `json:"key"`
+	Counts
+}
+
+// Breakdown splits a doctor's appointment outcomes by weekday, hour of the
+// day (clinic time) and appointment type
+type Breakdown struct {
+	Total     Counts  `json:"total"`
+	ByWeekday []Group `json:"by_weekday"`
+	ByHour    []Group `json:"by_hour"`
+	ByType    []Group `json:"by_type"`
+}
+
+// Analyze builds the breakdown of the appointments
+func Analyze(appointments []models.Appointment) Breakdown {
+	loc := scheduling.Location()
+	weekdays := make(map[string]*Counts)
+	hours := make(map[string]*Counts)
+	types := make(map[string]*Counts)
+
+	for _, a := range appointments {
+		local := scheduling.AppointmentStart(a).In(loc)
+		counter(weekdays, local.Weekday().String()).add(a)
+		counter(hours, local.Format("15")+":00").add(a)
+		counter(types, scheduling.NormalizeType(a.Type)).add(a)
+	}
+
+	// Weeks start on Monday
+	var weekdayKeys, hourKeys, typeKeys []string
+	for i := 1; i <= 7; i++ {
+		weekdayKeys = append(weekdayKeys, time.Weekday(i%7).String())
+	}
+	for hour := 0; hour < 24; hour++ {
+		hourKeys = append(hourKeys, fmt.Sprintf("%02d:00", hour))
+	}
+	for key := range types {
+		typeKeys = append(typeKeys, key)
+	}
+	sort.Strings(typeKeys)
+
+	return Breakdown{
+		Total:     Summarize(appointments),
+		ByWeekday: groups(weekdays, weekdayKeys),
+		ByHour:    groups(hours, hourKeys),
+		ByType:    groups(types, typeKeys),
+	}
+}
+
+func counter(counts map[string]*Counts, key string) *Counts {
+	if counts[key] == nil {
+		counts[key] = &Counts{}
+	}
+	return counts[key]
+}
+
+// groups lists the counts in the order of keys, leaving out empty ones
+func groups(counts map[string]*Counts, keys []string) []Group {
+	result := []Group{}
+	for _, key := range keys {
+		if c, ok := counts[key]; ok {
+			result = append(result, Group{Key: key, Counts: c.withRates()})
+		}
+	}
+	return result
+}
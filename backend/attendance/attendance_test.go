@@ -0,0 +1,108 @@
+package attendance
+
+import (
+	"dementicare-backend/models"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("CLINIC_TIMEZONE", "UTC")
+	os.Exit(m.Run())
+}
+
+func TestIsLateCancellation(t *testing.T) {
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		start time.Time
+		want  bool
+	}{
+		{now.Add(48 * time.Hour), false},
+		{now.Add(24 * time.Hour), false},
+		{now.Add(23 * time.Hour), true},
+		{now.Add(-time.Hour), true},
+	}
+	for _, tt := range tests {
+		if got := IsLateCancellation(tt.start, now); got != tt.want {
+			t.Errorf("IsLateCancellation(%s) = %v, want %v", tt.start, got, tt.want)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []string
+		late     int
+		want     Counts
+	}{
+		{name: "none", want: Counts{}},
+		{
+			name:     "mixed",
+			statuses: []string{"completed", "completed", "completed", "no_show", "cancelled", "cancelled", "confirmed", "pending"},
+			late:     1,
+			want: Counts{Appointments: 8, Completed: 3, NoShows: 1, Cancellations: 2, LateCancellations: 1,
+				NoShowRate: 0.25, LateCancellationRate: 0.125},
+		},
+		{
+			name:     "only cancellations",
+			statuses: []string{"cancelled", "cancelled"},
+			late:     2,
+			want:     Counts{Appointments: 2, Cancellations: 2, LateCancellations: 2, LateCancellationRate: 1},
+		},
+	}
+	for _, tt := range tests {
+		var appointments []models.Appointment
+		late := tt.late
+		for _, status := range tt.statuses {
+			a := models.Appointment{Status: status}
+			if status == "cancelled" && late > 0 {
+				a.LateCancellation = true
+				late--
+			}
+			appointments = append(appointments, a)
+		}
+		if got := Summarize(appointments); got != tt.want {
+			t.Errorf("%s: Summarize() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	appointment := func(start time.Time, appointmentType, status string) models.Appointment {
+		return models.Appointment{StartAt: start, Type: appointmentType, Status: status}
+	}
+	// 2026-03-02 is a Monday
+	breakdown := Analyze([]models.Appointment{
+		appointment(time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC), "Consultation", "completed"),
+		appointment(time.Date(2026, 3, 2, 9, 30, 0, 0, time.UTC), "follow-up", "no_show"),
+		appointment(time.Date(2026, 3, 8, 14, 0, 0, 0, time.UTC), "consultation ", "no_show"),
+	})
+
+	if breakdown.Total.Appointments != 3 || breakdown.Total.NoShows != 2 {
+		t.Errorf("total = %+v, want 3 appointments and 2 no-shows", breakdown.Total)
+	}
+
+	tests := []struct {
+		name   string
+		groups []Group
+		keys   []string
+		counts []int
+	}{
+		{"by weekday, Monday first", breakdown.ByWeekday, []string{"Monday", "Sunday"}, []int{2, 1}},
+		{"by hour", breakdown.ByHour, []string{"09:00", "14:00"}, []int{2, 1}},
+		{"by normalized type", breakdown.ByType, []string{"consultation", "follow-up"}, []int{2, 1}},
+	}
+	for _, tt := range tests {
+		if len(tt.groups) != len(tt.keys) {
+			t.Errorf("%s: got %d groups %+v, want keys %v", tt.name, len(tt.groups), tt.groups, tt.keys)
+			continue
+		}
+		for i, group := range tt.groups {
+			if group.Key != tt.keys[i] || group.Appointments != tt.counts[i] {
+				t.Errorf("%s: group %d = %s with %d, want %s with %d", tt.name, i, group.Key, group.Appointments, tt.keys[i], tt.counts[i])
+			}
+		}
+	}
+}
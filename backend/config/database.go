@@ -12,11 +12,57 @@ import (
 
 var DB *gorm.DB
 
+// Models lists every table the API uses, in migration order
+var Models = []interface{}{
+	&models.User{},
+	&models.Patient{},
+	&models.Appointment{},
+	&models.Prescription{},
+	&models.QuizResult{},
+	&models.Contact{},
+	&models.Job{},
+	&models.Attachment{},
+	&models.SafeZone{},
+	&models.LocationPing{},
+	&models.LocationAlert{},
+	&models.Observation{},
+	&models.ObservationThreshold{},
+	&models.ObservationAlert{},
+	&models.Diagnosis{},
+	&models.PatientMerge{},
+	&models.Allergy{},
+	&models.CareLogEntry{},
+	&models.SymptomEpisode{},
+	&models.CarePlan{},
+	&models.CarePlanGoal{},
+	&models.CarePlanTask{},
+	&models.CarePlanRevision{},
+	&models.DoctorSchedule{},
+	&models.ScheduleException{},
+	&models.AppointmentTypeDuration{},
+	&models.AppointmentStatusChange{},
+	&models.AppointmentSeries{},
+	&models.Notification{},
+	&models.CalendarFeed{},
+	&models.WaitlistEntry{},
+	&models.SlotOffer{},
+	&models.CareTeamMember{},
+	&models.EncounterNote{},
+	&models.EncounterAddendum{},
+	&models.MedicationSchedule{},
+	&models.MedicationAdministration{},
+	&models.DrugInteraction{},
+	&models.DataMigration{},
+}
+
 func ConnectDB() {
 	var err error
 
+	// Timestamps are stored and read as UTC, also by the session, so that
+	// TIMESTAMP columns and CURRENT_TIMESTAMP agree; display time zones are
+	// applied in the API. Rows written before are converted once on startup
 	dsn := fmt.Sprintf(
-		"%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		"%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=UTC&time_zone=%%27%%2B00%%3A00%%27",
 		os.Getenv("DB_USER"),
 		os.Getenv("DB_PASSWORD"),
 		os.Getenv("DB_HOST"),
@@ -31,19 +77,12 @@ func ConnectDB() {
 
 	log.Println("Database connected successfully")
 
-	// Auto migrate models
-	err = DB.AutoMigrate(
-		&models.User{},
-		&models.Patient{},
-		&models.Appointment{},
-		&models.Prescription{},
-		&models.QuizResult{},
-		&models.Contact{},
-		&models.Job{},
-	)
+	err = DB.AutoMigrate(Models...)
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
+	runDataMigrations()
+
 	log.Println("Database migration completed")
 }
@@ -0,0 +1,296 @@
+package config
+
+import (
+	"database/sql"
+	"dementicare-backend/interactions"
+	"dementicare-backend/medication"
+	"dementicare-backend/models"
+	"dementicare-backend/scheduling"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const timestampBatchSize = 500
+
+// runDataMigrations backfills data for schema changes that AutoMigrate cannot
+// express. Every step must be safe to run on each startup.
+func runDataMigrations() {
+	if err := migrateTimestampsToUTC(); err != nil {
+		log.Fatal("Failed to convert timestamps to UTC:", err)
+	}
+	if err := migrateLegacyDiagnoses(); err != nil {
+		log.Fatal("Failed to migrate legacy diagnoses:", err)
+	}
+	if err := migrateAppointmentStartTimes(); err != nil {
+		log.Fatal("Failed to migrate appointment start times:", err)
+	}
+	if err := backfillMedicationSchedules(); err != nil {
+		log.Fatal("Failed to derive medication schedules:", err)
+	}
+	if err := loadDrugInteractions(); err != nil {
+		log.Fatal("Failed to load drug interactions:", err)
+	}
+}
+
+// migrateLegacyDiagnoses copies free-text Patient.Diagnosis values into the
+// diagnosis history for patients that have no history yet.
+func migrateLegacyDiagnoses() error {
+	var patients []models.Patient
+	err := DB.Where("diagnosis <> ''").
+		Where("id NOT IN (?)", DB.Model(&models.Diagnosis{}).Unscoped().Select("patient_id")).
+		Find(&patients).Error
+	if err != nil {
+		return err
+	}
+
+	for _, patient := range patients {
+		diagnosis := models.Diagnosis{
+			PatientID:  patient.ID,
+			Condition:  patient.Diagnosis,
+			AssessedAt: patient.UpdatedAt,
+			Source:     "legacy",
+		}
+		if err := DB.Create(&diagnosis).Error; err != nil {
+			return err
+		}
+	}
+
+	if len(patients) > 0 {
+		log.Printf("Migrated %d legacy diagnoses", len(patients))
+	}
+	return nil
+}
+
+// migrateTimestampsToUTC converts every DATETIME column once from the zone
+// the server used to write them in (the connection used loc=Local) to UTC.
+// DB_LEGACY_TIMEZONE names that zone when the server now runs in another
+// one. TIMESTAMP columns are stored as UTC by MySQL and need no conversion.
+func migrateTimestampsToUTC() error {
+	const name = "timestamps_to_utc"
+
+	var applied int64
+	if err := DB.Model(&models.DataMigration{}).Where("name = ?", name).Count(&applied).Error; err != nil {
+		return err
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	legacy := time.Local
+	if zone := os.Getenv("DB_LEGACY_TIMEZONE"); zone != "" {
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			return errors.New("invalid DB_LEGACY_TIMEZONE " + zone)
+		}
+		legacy = loc
+	}
+
+	var columns []struct {
+		TableName  string
+		ColumnName string
+	}
+	err := DB.Raw(`SELECT table_name AS table_name, column_name AS column_name FROM information_schema.columns
+		WHERE table_schema = DATABASE() AND data_type = 'datetime' AND table_name <> ?
+		ORDER BY table_name, ordinal_position`, "data_migrations").Scan(&columns).Error
+	if err != nil {
+		return err
+	}
+	tables := map[string][]string{}
+	var order []string
+	for _, column := range columns {
+		if _, ok := tables[column.TableName]; !ok {
+			order = append(order, column.TableName)
+		}
+		tables[column.TableName] = append(tables[column.TableName], column.ColumnName)
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		for _, table := range order {
+			if !tx.Migrator().HasColumn(table, "id") {
+				log.Printf("Table %s has no id column, its timestamps are left unconverted", table)
+				continue
+			}
+			converted, err := convertTableTimestamps(tx, table, tables[table], legacy)
+			if err != nil {
+				return err
+			}
+			if converted > 0 {
+				log.Printf("Converted timestamps of %d %s rows to UTC", converted, table)
+			}
+		}
+		return tx.Create(&models.DataMigration{Name: name, AppliedAt: time.Now().UTC()}).Error
+	})
+}
+
+// convertTableTimestamps rewrites the wall-clock values in columns, read as
+// UTC, as the same wall clock in legacy converted to UTC
+func convertTableTimestamps(tx *gorm.DB, table string, columns []string, legacy *time.Location) (int, error) {
+	converted := 0
+	var lastID uint64
+	for {
+		rows, err := tx.Table(table).Select(append([]string{"id"}, columns...)).
+			Where("id > ?", lastID).Order("id asc").Limit(timestampBatchSize).Rows()
+		if err != nil {
+			return converted, err
+		}
+
+		type pending struct {
+			id      uint64
+			updates map[string]interface{}
+		}
+		var batch []pending
+		count := 0
+		for rows.Next() {
+			values := make([]sql.NullTime, len(columns))
+			dest := []interface{}{&lastID}
+			for i := range values {
+				dest = append(dest, &values[i])
+			}
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
+				return converted, err
+			}
+			count++
+
+			updates := map[string]interface{}{}
+			for i, value := range values {
+				if !value.Valid || value.Time.IsZero() {
+					continue
+				}
+				t := value.Time
+				utc := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), legacy).UTC()
+				if !utc.Equal(t) {
+					updates[columns[i]] = utc
+				}
+			}
+			if len(updates) > 0 {
+				batch = append(batch, pending{lastID, updates})
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return converted, err
+		}
+
+		for _, row := range batch {
+			if err := tx.Table(table).Where("id = ?", row.id).UpdateColumns(row.updates).Error; err != nil {
+				return converted, err
+			}
+		}
+		converted += len(batch)
+		if count < timestampBatchSize {
+			return converted, nil
+		}
+	}
+}
+
+// migrateAppointmentStartTimes fills StartAt and EndAt for appointments
+// booked before they existed, combining the calendar day of Date with the
+// HH:MM Time in the clinic time zone.
+func migrateAppointmentStartTimes() error {
+	var appointments []models.Appointment
+	if err := DB.Unscoped().Where("start_at IS NULL").Find(&appointments).Error; err != nil {
+		return err
+	}
+
+	for _, appointment := range appointments {
+		start, err := scheduling.StartFromClock(appointment.Date, appointment.Time)
+		if err != nil {
+			log.Printf("Appointment %d has no valid time %q, using its date", appointment.ID, appointment.Time)
+			start = appointment.Date
+		}
+		window := scheduling.AppointmentWindow(models.Appointment{Type: appointment.Type, DurationMinutes: appointment.DurationMinutes, StartAt: start})
+
+		err = DB.Model(&models.Appointment{}).Unscoped().Where("id = ?", appointment.ID).UpdateColumns(map[string]interface{}{
+			"start_at":  window.Start.UTC(),
+			"end_at":    window.End.UTC(),
+			"time_zone": scheduling.ZoneName(),
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(appointments) > 0 {
+		log.Printf("Migrated start times of %d appointments", len(appointments))
+	}
+	return nil
+}
+
+// backfillMedicationSchedules derives schedules, once, for prescriptions
+// written before schedules existed. Each starts on the prescription's own
+// date, so finished courses get a schedule that has already ended.
+func backfillMedicationSchedules() error {
+	const name = "medication_schedules_backfill"
+
+	var applied int64
+	if err := DB.Model(&models.DataMigration{}).Where("name = ?", name).Count(&applied).Error; err != nil {
+		return err
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	var prescriptions []models.Prescription
+	err := DB.Where("id NOT IN (?)", DB.Model(&models.MedicationSchedule{}).Unscoped().Select("prescription_id")).
+		Find(&prescriptions).Error
+	if err != nil {
+		return err
+	}
+	for _, prescription := range prescriptions {
+		if err := medication.SyncDerived(DB, prescription, prescription.CreatedAt, prescription.DoctorID); err != nil {
+			return err
+		}
+	}
+	if len(prescriptions) > 0 {
+		log.Printf("Derived medication schedules for %d prescriptions", len(prescriptions))
+	}
+	return DB.Create(&models.DataMigration{Name: name, AppliedAt: time.Now().UTC()}).Error
+}
+
+// loadDrugInteractions seeds the bundled interaction dataset into an empty
+// table, then imports DRUG_INTERACTIONS_FILE (CSV or JSON) if it is set. A
+// file with any invalid row stops startup.
+func loadDrugInteractions() error {
+	seeded, err := interactions.SeedBundled(DB)
+	if err != nil {
+		return err
+	}
+	if seeded > 0 {
+		log.Printf("Loaded %d bundled drug interactions", seeded)
+	}
+
+	path := os.Getenv("DRUG_INTERACTIONS_FILE")
+	if path == "" {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	records, rowErrors, err := interactions.Parse(file, strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), "."))
+	if err != nil {
+		return err
+	}
+	// Like the upload endpoint, a file with invalid rows is not imported at all
+	for _, e := range rowErrors {
+		log.Printf("Invalid row %d of %s: %s", e.Row, path, e.Error)
+	}
+	if len(rowErrors) > 0 {
+		return fmt.Errorf("%s has %d invalid rows", path, len(rowErrors))
+	}
+	if err := interactions.Import(DB, records, filepath.Base(path)); err != nil {
+		return err
+	}
+	log.Printf("Imported %d drug interactions from %s", len(records), path)
+	return nil
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"dementicare-backend/models"
+	"dementicare-backend/testdb"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// Appointment times are read in the clinic zone; use one with DST
+	os.Setenv("CLINIC_TIMEZONE", "Europe/London")
+	os.Exit(m.Run())
+}
+
+// useTestDB points DB at a fresh database for the duration of the test
+func useTestDB(t *testing.T) {
+	t.Helper()
+	previous := DB
+	DB = testdb.Open(t, Models...)
+	t.Cleanup(func() { DB = previous })
+}
+
+func TestMigrateAppointmentStartTimes(t *testing.T) {
+	useTestDB(t)
+	day := func(month time.Month, d int) time.Time { return time.Date(2026, month, d, 0, 0, 0, 0, time.UTC) }
+	legacy := []models.Appointment{
+		{Date: day(7, 1), Time: "14:30", Type: "follow-up"},
+		{Date: day(1, 15), Time: "09:00", Type: "consultation"},
+		{Date: day(3, 2), Time: "later", Type: "consultation"},
+	}
+	for i := range legacy {
+		DB.Omit("start_at", "end_at").Create(&legacy[i])
+	}
+	migrated := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+	DB.Create(&models.Appointment{StartAt: migrated, EndAt: migrated.Add(time.Hour), Date: day(5, 1), Time: "09:00"})
+
+	if err := migrateAppointmentStartTimes(); err != nil {
+		t.Fatalf("migrateAppointmentStartTimes() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		id         uint
+		start, end time.Time
+	}{
+		{"summer time", legacy[0].ID, time.Date(2026, 7, 1, 13, 30, 0, 0, time.UTC), time.Date(2026, 7, 1, 13, 50, 0, 0, time.UTC)},
+		{"winter time", legacy[1].ID, time.Date(2026, 1, 15, 9, 0, 0, 0, time.UTC), time.Date(2026, 1, 15, 9, 30, 0, 0, time.UTC)},
+		{"invalid time", legacy[2].ID, day(3, 2), day(3, 2).Add(30 * time.Minute)},
+		{"already migrated", legacy[2].ID + 1, migrated, migrated.Add(time.Hour)},
+	}
+	for _, tt := range tests {
+		var appointment models.Appointment
+		DB.First(&appointment, tt.id)
+		if !appointment.StartAt.Equal(tt.start) || !appointment.EndAt.Equal(tt.end) {
+			t.Errorf("%s: start %s end %s, want %s and %s", tt.name, appointment.StartAt, appointment.EndAt, tt.start, tt.end)
+		}
+		if tt.name != "already migrated" && appointment.TimeZone != "Europe/London" {
+			t.Errorf("%s: time zone %q, want the clinic's", tt.name, appointment.TimeZone)
+		}
+	}
+}
+
+func TestConvertTableTimestamps(t *testing.T) {
+	useTestDB(t)
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skip("Europe/London time zone data not available")
+	}
+	// Wall-clock values written by a server in London, read back as UTC
+	summer := time.Date(2026, 7, 1, 14, 30, 0, 0, time.UTC)
+	winter := time.Date(2026, 1, 15, 9, 0, 0, 0, time.UTC)
+	rows := []models.ScheduleException{
+		{DoctorID: 1, StartAt: summer, EndAt: summer.Add(time.Hour)},
+		{DoctorID: 1, StartAt: winter, EndAt: winter.Add(time.Hour)},
+	}
+	DB.Create(&rows)
+
+	converted, err := convertTableTimestamps(DB, "schedule_exceptions", []string{"start_at", "end_at"}, london)
+	if err != nil {
+		t.Fatalf("convertTableTimestamps() error = %v", err)
+	}
+	if converted != 1 {
+		t.Errorf("converted %d rows, want only the one in summer time", converted)
+	}
+
+	var got []models.ScheduleException
+	DB.Order("id").Find(&got)
+	if want := summer.Add(-time.Hour); !got[0].StartAt.Equal(want) || !got[0].EndAt.Equal(want.Add(time.Hour)) {
+		t.Errorf("summer row %s-%s, want to start at %s", got[0].StartAt, got[0].EndAt, want)
+	}
+	if !got[1].StartAt.Equal(winter) {
+		t.Errorf("winter row starts at %s, want it unchanged at %s", got[1].StartAt, winter)
+	}
+}
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"dementicare-backend/notify"
+	"log"
+	"os"
+)
+
+var Notifier notify.Notifier
+
+func ConnectNotifier() {
+	channel := os.Getenv("NOTIFY_CHANNEL")
+	if channel == "" {
+		channel = "log"
+	}
+
+	switch channel {
+	case "log":
+		Notifier = notify.LogNotifier{}
+	default:
+		log.Fatalf("Unsupported NOTIFY_CHANNEL %q", channel)
+	}
+
+	log.Printf("Notifications initialized (%s)", channel)
+}
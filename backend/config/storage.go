@@ -0,0 +1,33 @@
+package config
+
+import (
+	"dementicare-backend/storage"
+	"log"
+	"os"
+)
+
+var Storage storage.Storage
+
+func ConnectStorage() {
+	driver := os.Getenv("STORAGE_DRIVER")
+	if driver == "" {
+		driver = "local"
+	}
+
+	switch driver {
+	case "local":
+		root := os.Getenv("STORAGE_LOCAL_PATH")
+		if root == "" {
+			root = "./uploads"
+		}
+		local, err := storage.NewLocalStorage(root)
+		if err != nil {
+			log.Fatal("Failed to initialize local storage:", err)
+		}
+		Storage = local
+	default:
+		log.Fatalf("Unsupported STORAGE_DRIVER %q", driver)
+	}
+
+	log.Printf("File storage initialized (%s)", driver)
+}
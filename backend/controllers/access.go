@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"dementicare-backend/config"
+	"dementicare-backend/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// canAccessPatient reports whether the authenticated user may see the
+// patient's record. Doctors and admins see everyone, caregivers only the
+// patients assigned to them or whose care team they are on, and patients
+// only their own record.
+func canAccessPatient(c *gin.Context, patient models.Patient) bool {
+	switch c.GetString("user_type") {
+	case "doctor", "admin":
+		return true
+	case "caregiver":
+		return patient.CaregiverID == c.GetUint("user_id") || isCareTeamMember(patient.ID, c.GetUint("user_id"))
+	case "patient":
+		return patient.UserID != 0 && patient.UserID == c.GetUint("user_id")
+	}
+	return false
+}
+
+// findAccessiblePatient loads a patient by ID and writes the error response
+// itself when the patient is missing or not visible to the caller.
+func findAccessiblePatient(c *gin.Context, id interface{}) (models.Patient, bool) {
+	var patient models.Patient
+	if err := config.DB.First(&patient, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
+		return patient, false
+	}
+
+	if !canAccessPatient(c, patient) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this patient"})
+		return patient, false
+	}
+
+	return patient, true
+}
+
+// findManagedPatient is findAccessiblePatient for changes made by the people
+// looking after a patient: caregivers, care-team members, doctors and admins,
+// but not the patient.
+func findManagedPatient(c *gin.Context, id interface{}) (models.Patient, bool) {
+	if c.GetString("user_type") == "patient" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only caregivers and clinicians can do this"})
+		return models.Patient{}, false
+	}
+	return findAccessiblePatient(c, id)
+}
+
+// visiblePatientScope restricts a query on a table with a patient_id column
+// to the patients the caller can see. It writes a 403 for unknown roles.
+func visiblePatientScope(c *gin.Context, query *gorm.DB) (*gorm.DB, bool) {
+	userID := c.GetUint("user_id")
+
+	switch c.GetString("user_type") {
+	case "doctor", "admin":
+		return query, true
+	case "caregiver":
+		return query.Where("patient_id IN (?)", caredForPatients(userID)), true
+	case "patient":
+		return query.Where("patient_id IN (?)", config.DB.Model(&models.Patient{}).Select("id").Where("user_id = ?", userID)), true
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to patient records"})
+	return query, false
+}
+
+func isCareTeamMember(patientID, userID uint) bool {
+	var count int64
+	config.DB.Model(&models.CareTeamMember{}).Where("patient_id = ? AND user_id = ?", patientID, userID).Count(&count)
+	return count > 0
+}
+
+// caredForPatients is a subquery of the IDs of the patients a caregiver is
+// assigned to or on the care team of
+func caredForPatients(userID uint) *gorm.DB {
+	return config.DB.Model(&models.Patient{}).Select("id").
+		Where("caregiver_id = ? OR id IN (?)", userID, config.DB.Model(&models.CareTeamMember{}).Select("patient_id").Where("user_id = ?", userID))
+}
+
+// caredForPatientUsers is a subquery of the user accounts of the patients a
+// caregiver looks after, for tables keyed by the patient's user, such as
+// appointments
+func caredForPatientUsers(userID uint) *gorm.DB {
+	return config.DB.Model(&models.Patient{}).Select("user_id").
+		Where("user_id <> 0 AND id IN (?)", caredForPatients(userID))
+}
+
+// caresForPatientUser reports whether the caregiver looks after the patient
+// with the given user account
+func caresForPatientUser(caregiverID, patientUserID uint) bool {
+	if patientUserID == 0 {
+		return false
+	}
+	var count int64
+	config.DB.Model(&models.Patient{}).Where("user_id = ? AND id IN (?)", patientUserID, caredForPatients(caregiverID)).Count(&count)
+	return count > 0
+}
@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"dementicare-backend/config"
+	"dementicare-backend/models"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var allergySeverities = map[string]bool{
+	"mild":     true,
+	"moderate": true,
+	"severe":   true,
+}
+
+func GetAllergies(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var allergies []models.Allergy
+	if err := config.DB.Where("patient_id = ?", patient.ID).Order("created_at desc").Find(&allergies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch allergies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"allergies": allergies})
+}
+
+func CreateAllergy(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var allergy models.Allergy
+	if err := c.ShouldBindJSON(&allergy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	allergy.ID = 0
+	allergy.PatientID = patient.ID
+	allergy.RecordedBy = c.GetUint("user_id")
+	if msg := validateAllergy(&allergy); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := config.DB.Create(&allergy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create allergy"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, allergy)
+}
+
+func UpdateAllergy(c *gin.Context) {
+	var allergy models.Allergy
+	if err := config.DB.First(&allergy, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Allergy not found"})
+		return
+	}
+
+	if _, ok := findAccessiblePatient(c, allergy.PatientID); !ok {
+		return
+	}
+
+	id, patientID := allergy.ID, allergy.PatientID
+	if err := c.ShouldBindJSON(&allergy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	allergy.ID, allergy.PatientID = id, patientID
+
+	if msg := validateAllergy(&allergy); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := config.DB.Save(&allergy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update allergy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, allergy)
+}
+
+func DeleteAllergy(c *gin.Context) {
+	var allergy models.Allergy
+	if err := config.DB.First(&allergy, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Allergy not found"})
+		return
+	}
+
+	if _, ok := findAccessiblePatient(c, allergy.PatientID); !ok {
+		return
+	}
+
+	if err := config.DB.Delete(&allergy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete allergy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Allergy deleted successfully"})
+}
+
+func validateAllergy(allergy *models.Allergy) string {
+	allergy.Substance = strings.TrimSpace(allergy.Substance)
+	allergy.Severity = strings.ToLower(strings.TrimSpace(allergy.Severity))
+
+	if allergy.Substance == "" {
+		return "Substance is required"
+	}
+	if !allergySeverities[allergy.Severity] {
+		return "Severity must be mild, moderate or severe"
+	}
+	return ""
+}
+
+// findAllergyConflicts returns the patient's allergies whose substance
+// matches the medication name, comparing whole words case-insensitively.
+func findAllergyConflicts(patientID uint, medication string) ([]models.Allergy, error) {
+	var allergies []models.Allergy
+	if err := config.DB.Where("patient_id = ?", patientID).Find(&allergies).Error; err != nil {
+		return nil, err
+	}
+
+	med := normalizeText(medication)
+	conflicts := []models.Allergy{}
+	for _, allergy := range allergies {
+		substance := normalizeText(allergy.Substance)
+		if substance == "" || med == "" {
+			continue
+		}
+		if containsPhrase(med, substance) || containsPhrase(substance, med) {
+			conflicts = append(conflicts, allergy)
+		}
+	}
+	return conflicts, nil
+}
+
+// containsPhrase reports whether the normalized phrase appears in text on
+// word boundaries.
+func containsPhrase(text, phrase string) bool {
+	return strings.Contains(" "+text+" ", " "+phrase+" ")
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"dementicare-backend/models"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestFindAllergyConflicts(t *testing.T) {
+	db := useTestDB(t)
+	db.Create(&models.Allergy{PatientID: 1, Substance: "Penicillin", Severity: "severe"})
+	db.Create(&models.Allergy{PatientID: 1, Substance: "co-codamol", Severity: "mild"})
+	db.Create(&models.Allergy{PatientID: 2, Substance: "Donepezil", Severity: "moderate"})
+
+	tests := []struct {
+		medication string
+		want       int
+	}{
+		{"penicillin", 1},
+		{"PENICILLIN V 250mg", 1},
+		{"Co-Codamol 30/500", 1},
+		{"Amoxicillin", 0},
+		{"penicillinase", 0},
+		{"Donepezil", 0}, // another patient's allergy
+		{"", 0},
+	}
+	for _, tt := range tests {
+		conflicts, err := findAllergyConflicts(1, tt.medication)
+		if err != nil {
+			t.Fatalf("findAllergyConflicts(%q) error = %v", tt.medication, err)
+		}
+		if len(conflicts) != tt.want {
+			t.Errorf("findAllergyConflicts(%q) = %d allergies %+v, want %d", tt.medication, len(conflicts), conflicts, tt.want)
+		}
+	}
+}
+
+func TestCreateAllergy(t *testing.T) {
+	db := useTestDB(t)
+	patient := models.Patient{UserID: 10, Name: "Edith", CaregiverID: 20}
+	db.Create(&patient)
+	target := "/patients/" + strconv.Itoa(int(patient.ID)) + "/allergies"
+	asCaregiver := caller{id: 20, userType: "caregiver"}
+
+	tests := []struct {
+		name   string
+		as     caller
+		body   map[string]string
+		status int
+	}{
+		{"unrelated caregiver", caller{id: 21, userType: "caregiver"}, map[string]string{"substance": "Penicillin", "severity": "severe"}, http.StatusForbidden},
+		{"no substance", asCaregiver, map[string]string{"substance": "  ", "severity": "severe"}, http.StatusBadRequest},
+		{"unknown severity", asCaregiver, map[string]string{"substance": "Penicillin", "severity": "fatal"}, http.StatusBadRequest},
+		{"caregiver", asCaregiver, map[string]string{"substance": " Penicillin ", "severity": "Severe", "reaction": "rash"}, http.StatusCreated},
+		{"doctor", caller{id: 1, userType: "doctor"}, map[string]string{"substance": "Latex", "severity": "mild"}, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		w := serve(CreateAllergy, "POST", "/patients/:id/allergies", target, tt.as, tt.body)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+		}
+	}
+
+	var allergy models.Allergy
+	db.Where("substance = ?", "Penicillin").First(&allergy)
+	if allergy.Severity != "severe" || allergy.PatientID != patient.ID || allergy.RecordedBy != 20 {
+		t.Errorf("stored %+v, want a normalized severe allergy recorded by the caregiver", allergy)
+	}
+}
+
+func TestPrescriptionAllergyCheck(t *testing.T) {
+	db := useTestDB(t)
+	db.Create(&models.Allergy{PatientID: 5, Substance: "Penicillin", Severity: "severe"})
+	asDoctor := caller{id: 1, userType: "doctor"}
+
+	tests := []struct {
+		name   string
+		body   map[string]interface{}
+		status int
+	}{
+		{"no allergy", map[string]interface{}{"patient_id": 5, "medication": "Donepezil", "frequency": "once daily"}, http.StatusCreated},
+		{"allergy", map[string]interface{}{"patient_id": 5, "medication": "Penicillin V", "frequency": "once daily"}, http.StatusConflict},
+		{"blank override", map[string]interface{}{"patient_id": 5, "medication": "Penicillin V", "frequency": "once daily", "allergy_override_reason": " "}, http.StatusConflict},
+		{"override", map[string]interface{}{"patient_id": 5, "medication": "Penicillin V", "frequency": "once daily", "allergy_override_reason": "Desensitised"}, http.StatusCreated},
+		{"another patient", map[string]interface{}{"patient_id": 6, "medication": "Penicillin V", "frequency": "once daily"}, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		w := serve(CreatePrescription, "POST", "/prescriptions", "/prescriptions", asDoctor, tt.body)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+		}
+	}
+
+	// Changing the medication needs its own override; other edits keep the old one
+	var prescription models.Prescription
+	db.Where("patient_id = ? AND medication = ?", 5, "Donepezil").First(&prescription)
+	target := "/prescriptions/" + strconv.Itoa(int(prescription.ID))
+	if w := serve(UpdatePrescription, "PUT", "/prescriptions/:id", target, asDoctor, map[string]string{"medication": "Penicillin"}); w.Code != http.StatusConflict {
+		t.Errorf("update to an allergen status = %d, want %d: %s", w.Code, http.StatusConflict, w.Body)
+	}
+
+	var overridden models.Prescription
+	db.Where("allergy_override_reason = ?", "Desensitised").First(&overridden)
+	target = "/prescriptions/" + strconv.Itoa(int(overridden.ID))
+	if w := serve(UpdatePrescription, "PUT", "/prescriptions/:id", target, asDoctor, map[string]string{"dosage": "500mg"}); w.Code != http.StatusOK {
+		t.Errorf("dosage update of an overridden prescription status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+}
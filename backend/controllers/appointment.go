@@ -3,11 +3,17 @@ package controllers
 import (
 	"dementicare-backend/config"
 	"dementicare-backend/models"
+	"dementicare-backend/scheduling"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // Response struct for appointments with user names
@@ -17,56 +23,112 @@ type AppointmentResponse struct {
 	PatientName string    `json:"patient_name"`
 	DoctorID    uint      `json:"doctor_id"`
 	DoctorName  string    `json:"doctor_name"`
+	StartAt     time.Time `json:"start_at"`
+	EndAt       time.Time `json:"end_at"`
+	TimeZone    string    `json:"time_zone"`
+	LocalStart  string    `json:"local_start" gorm:"-"` // RFC3339 in the viewer's time zone
+	LocalEnd    string    `json:"local_end" gorm:"-"`
 	Date        time.Time `json:"date"`
 	Time        string    `json:"time"`
 	Type        string    `json:"type"`
+	Modality    string    `json:"modality"`
 	Status      string    `json:"status"`
 	Notes       string    `json:"notes"`
 	CreatedAt   time.Time `json:"created_at"`
 }
 
+// Page sizes for GET /api/appointments
+const (
+	defaultAppointmentPageSize = 50
+	maxAppointmentPageSize     = 200
+)
+
+// GetAppointments lists the caller's appointments one page at a time. It
+// takes from/to (RFC3339, or YYYY-MM-DD in the caller's time zone, with a
+// date-only "to" including that day), status (comma separated), type,
+// modality, doctor_id and patient_id filters, sort (-created_at, start_at or
+// -start_at) and page/per_page.
 func GetAppointments(c *gin.Context) {
 	userType := c.GetString("user_type")
 	userID := c.GetUint("user_id")
 
 	log.Printf("GetAppointments - userType: %s, userID: %d", userType, userID)
 
-	var results []AppointmentResponse
+	loc := userLocation(userID)
+	filters, err := appointmentFilters(c, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if value := c.Query("from"); value != "" {
+		from, err := parseDateParamIn(value, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be RFC3339 or YYYY-MM-DD"})
+			return
+		}
+		filters = append(filters, func(db *gorm.DB) *gorm.DB { return db.Where("appointments.start_at >= ?", from) })
+	}
+	if value := c.Query("to"); value != "" {
+		to, err := parseDateParamIn(value, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be RFC3339 or YYYY-MM-DD"})
+			return
+		}
+		if len(value) == len("2006-01-02") {
+			to = to.AddDate(0, 0, 1)
+		}
+		filters = append(filters, func(db *gorm.DB) *gorm.DB { return db.Where("appointments.start_at < ?", to) })
+	}
 
-	query := config.DB.Table("appointments").
-		Select("appointments.*, " +
-			"doctors.name as doctor_name, " +
-			"patients.name as patient_name").
-		Joins("LEFT JOIN users as doctors ON appointments.doctor_id = doctors.id").
-		Joins("LEFT JOIN users as patients ON appointments.patient_id = patients.id").
-		Order("appointments.created_at desc")
+	order, ok := map[string]string{
+		"":            "appointments.created_at desc",
+		"-created_at": "appointments.created_at desc",
+		"start_at":    "appointments.start_at asc",
+		"-start_at":   "appointments.start_at desc",
+	}[c.Query("sort")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be -created_at, start_at or -start_at"})
+		return
+	}
+
+	page, perPage, err := pageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	// Filter based on user type
-	if userType == "doctor" {
-		log.Printf("Doctor viewing their appointments (doctor_id = %d)", userID)
-		query = query.Where("appointments.doctor_id = ?", userID)
-	} else if userType == "patient" {
-		log.Printf("Patient viewing their appointments (patient_id = %d)", userID)
-		query = query.Where("appointments.patient_id = ?", userID)
+	var total int64
+	if err := config.DB.Table("appointments").Scopes(filters...).Count(&total).Error; err != nil {
+		log.Printf("Error counting appointments: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch appointments"})
+		return
 	}
-	// Admin/caregiver can see all appointments
 
-	if err := query.Scan(&results).Error; err != nil {
+	results, err := findAppointmentResponses(loc, append(filters, func(db *gorm.DB) *gorm.DB {
+		// id breaks ties so pages do not overlap
+		return db.Order(order).Order("appointments.id desc").Limit(perPage).Offset((page - 1) * perPage)
+	})...)
+	if err != nil {
 		log.Printf("Error fetching appointments: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch appointments"})
 		return
 	}
 
 	log.Printf("Found %d appointments", len(results))
-	c.JSON(http.StatusOK, gin.H{"appointments": results})
+	c.JSON(http.StatusOK, gin.H{
+		"appointments": results,
+		"pagination": gin.H{
+			"page":        page,
+			"per_page":    perPage,
+			"total":       total,
+			"total_pages": (total + int64(perPage) - 1) / int64(perPage),
+		},
+	})
 }
 
 func GetAppointment(c *gin.Context) {
-	id := c.Param("id")
-	var appointment models.Appointment
-
-	if err := config.DB.First(&appointment, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
+	appointment, _, ok := findAppointmentForAction(c, "doctor", "patient", "caregiver", "admin")
+	if !ok {
 		return
 	}
 
@@ -74,23 +136,41 @@ func GetAppointment(c *gin.Context) {
 }
 
 func CreateAppointment(c *gin.Context) {
-	userType := c.GetString("user_type")
 	userID := c.GetUint("user_id")
 
-	// Only patients can book appointments
-	if userType != "patient" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only patients can book appointments"})
-		return
-	}
-
 	var appointment models.Appointment
 	if err := c.ShouldBindJSON(&appointment); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Override patient_id with authenticated user's ID
-	appointment.PatientID = userID
+	// Patients book for themselves; caregivers for a patient they look after
+	patientID, ok := bookingPatient(c, appointment.PatientID)
+	if !ok {
+		return
+	}
+	appointment.PatientID = patientID
+	appointment.BookedBy = userID
+
+	// New bookings always start as pending; status changes go through the
+	// action endpoints
+	appointment.Status = "pending"
+	appointment.CancellationReason = ""
+	appointment.LateCancellation = false
+	appointment.NeedsCaregiver = false
+	appointment.CaregiverConfirmBy = nil
+	appointment.CaregiverConfirmAt = nil
+	appointment.RescheduledFromID = nil
+	appointment.SeriesID = nil
+	appointment.SeriesIndex = 0
+	appointment.Sequence = 0
+
+	modality, ok := normalizeModality(appointment.Modality)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "modality must be in_person or virtual"})
+		return
+	}
+	appointment.Modality = modality
 
 	// Validate doctor_id is provided
 	if appointment.DoctorID == 0 {
@@ -98,44 +178,254 @@ func CreateAppointment(c *gin.Context) {
 		return
 	}
 
-	if err := config.DB.Create(&appointment).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create appointment"})
+	// start_at (RFC3339) is preferred; date + time are read in clinic time
+	if appointment.StartAt.IsZero() {
+		if _, err := requestedStart(nil, appointment.Date, appointment.Time); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := scheduling.Reserve(tx, &appointment, 0); err != nil {
+			return err
+		}
+		if err := applyNoShowPolicy(tx, &appointment, userID); err != nil {
+			return err
+		}
+		if err := tx.Create(&appointment).Error; err != nil {
+			return err
+		}
+		return recordStatusChange(tx, appointment.ID, "", appointment.Status, userID, "")
+	})
+	if err != nil {
+		respondBookingError(c, err, appointment, "Failed to create appointment")
 		return
 	}
 
-	log.Printf("Appointment created - ID: %d, Patient: %d, Doctor: %d", appointment.ID, appointment.PatientID, appointment.DoctorID)
+	notifyPatient(appointment.PatientID, userID, fmt.Sprintf("booked:%d", appointment.ID),
+		"booked an appointment for you on "+patientTime(appointment.PatientID, appointment.StartAt))
+	if appointment.NeedsCaregiver && appointment.CaregiverConfirmBy == nil {
+		requestCaregiverConfirmation(appointment.PatientID, fmt.Sprintf("appointment:%d", appointment.ID),
+			"an appointment on "+patientTime(appointment.PatientID, appointment.StartAt))
+	}
+
+	log.Printf("Appointment created - ID: %d, Patient: %d, Doctor: %d, Booked by: %d", appointment.ID, appointment.PatientID, appointment.DoctorID, userID)
 	c.JSON(http.StatusCreated, appointment)
 }
 
+// UpdateAppointment edits the notes and modality of an appointment. Status,
+// time and participants change only through the action endpoints.
 func UpdateAppointment(c *gin.Context) {
-	id := c.Param("id")
-	var appointment models.Appointment
-
-	if err := config.DB.First(&appointment, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
+	appointment, _, ok := findAppointmentForAction(c, "doctor", "patient", "caregiver", "admin")
+	if !ok {
 		return
 	}
 
-	if err := c.ShouldBindJSON(&appointment); err != nil {
+	var req struct {
+		Notes     *string    `json:"notes"`
+		Status    *string    `json:"status"`
+		PatientID *uint      `json:"patient_id"`
+		DoctorID  *uint      `json:"doctor_id"`
+		StartAt   *time.Time `json:"start_at"`
+		Date      *time.Time `json:"date"`
+		Time      *string    `json:"time"`
+		Type      *string    `json:"type"`
+		Modality  *string    `json:"modality"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := config.DB.Save(&appointment).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update appointment"})
+	if req.Status != nil && *req.Status != appointment.Status {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Use the confirm, complete, cancel or no-show actions to change status"})
 		return
 	}
+	if (req.PatientID != nil && *req.PatientID != appointment.PatientID) || (req.DoctorID != nil && *req.DoctorID != appointment.DoctorID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Patient and doctor cannot be changed"})
+		return
+	}
+	if (req.StartAt != nil && !req.StartAt.Equal(appointment.StartAt)) ||
+		(req.Date != nil && !req.Date.Equal(appointment.Date)) || (req.Time != nil && *req.Time != appointment.Time) ||
+		(req.Type != nil && *req.Type != appointment.Type) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Use the reschedule action to change the date, time or type"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Notes != nil {
+		appointment.Notes = *req.Notes
+		updates["notes"] = appointment.Notes
+	}
+	if req.Modality != nil {
+		modality, ok := normalizeModality(*req.Modality)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "modality must be in_person or virtual"})
+			return
+		}
+		appointment.Modality = modality
+		updates["modality"] = modality
+	}
+
+	if len(updates) > 0 {
+		appointment.Sequence++
+		updates["sequence"] = appointment.Sequence
+		if err := config.DB.Model(&appointment).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update appointment"})
+			return
+		}
+	}
 
 	c.JSON(http.StatusOK, appointment)
 }
 
+// DeleteAppointment removes an appointment outright, e.g. one entered by
+// mistake. It skips the status history and waitlist hand-off, so it is for
+// admins only; everyone else cancels through the status endpoints.
 func DeleteAppointment(c *gin.Context) {
-	id := c.Param("id")
+	appointment, _, ok := findAppointmentForAction(c, "admin")
+	if !ok {
+		return
+	}
 
-	if err := config.DB.Delete(&models.Appointment{}, id).Error; err != nil {
+	if err := config.DB.Delete(&appointment).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete appointment"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Appointment deleted successfully"})
 }
+
+// bookingPatient returns the patient user an appointment is booked for.
+// Patients always book for themselves. Caregivers and care-team members
+// give the patient's user ID (patients.user_id) of someone they look after.
+func bookingPatient(c *gin.Context, requested uint) (uint, bool) {
+	userID := c.GetUint("user_id")
+	switch c.GetString("user_type") {
+	case "patient":
+		return userID, true
+	case "caregiver":
+		if requested == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "patient_id (the patient's user ID) is required"})
+			return 0, false
+		}
+		if !caresForPatientUser(userID, requested) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only book for patients you look after"})
+			return 0, false
+		}
+		return requested, true
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "Only patients and their caregivers can book appointments"})
+	return 0, false
+}
+
+// respondBookingError turns an error from scheduling.Reserve into a response.
+// A clash with another booking is a 409 that suggests the nearest free slots.
+func respondBookingError(c *gin.Context, err error, appointment models.Appointment, fallback string) {
+	switch {
+	case errors.Is(err, scheduling.ErrSlotTaken):
+		suggestions, suggestErr := scheduling.NearestSlots(config.DB, appointment.DoctorID, appointment.Type, scheduling.AppointmentStart(appointment), 3)
+		if suggestErr != nil {
+			log.Printf("Error suggesting slots for doctor %d: %v", appointment.DoctorID, suggestErr)
+		}
+		c.JSON(http.StatusConflict, gin.H{
+			"error":       "This slot has just been booked by someone else",
+			"suggestions": suggestions,
+		})
+	case errors.Is(err, scheduling.ErrSlotUnavailable):
+		c.JSON(http.StatusConflict, gin.H{"error": "The doctor is not available at this time; pick a slot from /api/doctors/:id/slots"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Doctor not found"})
+	default:
+		log.Printf("Error booking appointment with doctor %d: %v", appointment.DoctorID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// appointmentFilters limits appointments by the caller's role and applies the
+// status, type, modality, doctor_id and patient_id query filters. Doctors see
+// their own appointments and patients theirs; admins and caregivers see all.
+func appointmentFilters(c *gin.Context, loc *time.Location) ([]func(*gorm.DB) *gorm.DB, error) {
+	userType, userID := c.GetString("user_type"), c.GetUint("user_id")
+	filters := []func(*gorm.DB) *gorm.DB{func(db *gorm.DB) *gorm.DB {
+		db = db.Where("appointments.deleted_at IS NULL")
+		switch userType {
+		case "doctor":
+			return db.Where("appointments.doctor_id = ?", userID)
+		case "patient":
+			return db.Where("appointments.patient_id = ?", userID)
+		}
+		return db
+	}}
+
+	if status := c.Query("status"); status != "" {
+		statuses := strings.Split(status, ",")
+		for i := range statuses {
+			statuses[i] = strings.TrimSpace(statuses[i])
+		}
+		filters = append(filters, func(db *gorm.DB) *gorm.DB { return db.Where("appointments.status IN ?", statuses) })
+	}
+	if appointmentType := c.Query("type"); appointmentType != "" {
+		filters = append(filters, func(db *gorm.DB) *gorm.DB { return db.Where("appointments.type = ?", appointmentType) })
+	}
+	if modality := c.Query("modality"); modality != "" {
+		filters = append(filters, func(db *gorm.DB) *gorm.DB { return db.Where("appointments.modality = ?", modality) })
+	}
+	for _, column := range []string{"doctor_id", "patient_id"} {
+		value := c.Query(column)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a user ID", column)
+		}
+		condition := "appointments." + column + " = ?"
+		filters = append(filters, func(db *gorm.DB) *gorm.DB { return db.Where(condition, id) })
+	}
+	return filters, nil
+}
+
+// pageParams reads page (from 1) and per_page
+func pageParams(c *gin.Context) (int, int, error) {
+	page, perPage := 1, defaultAppointmentPageSize
+	if value := c.Query("page"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return 0, 0, errors.New("page must be a positive number")
+		}
+		page = n
+	}
+	if value := c.Query("per_page"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxAppointmentPageSize {
+			return 0, 0, fmt.Errorf("per_page must be between 1 and %d", maxAppointmentPageSize)
+		}
+		perPage = n
+	}
+	return page, perPage, nil
+}
+
+// findAppointmentResponses loads appointments with the doctor's and patient's
+// names, with local times in loc
+func findAppointmentResponses(loc *time.Location, scopes ...func(*gorm.DB) *gorm.DB) ([]AppointmentResponse, error) {
+	results := []AppointmentResponse{}
+	err := config.DB.Table("appointments").
+		Select("appointments.*, " +
+			"doctors.name as doctor_name, " +
+			"patients.name as patient_name").
+		Joins("LEFT JOIN users as doctors ON appointments.doctor_id = doctors.id").
+		Joins("LEFT JOIN users as patients ON appointments.patient_id = patients.id").
+		Scopes(scopes...).
+		Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		results[i].LocalStart = results[i].StartAt.In(loc).Format(time.RFC3339)
+		results[i].LocalEnd = results[i].EndAt.In(loc).Format(time.RFC3339)
+	}
+	return results, nil
+}
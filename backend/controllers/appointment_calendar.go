@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CalendarDay struct {
+	Date         string                `json:"date"` // YYYY-MM-DD in the caller's time zone
+	InMonth      bool                  `json:"in_month"`
+	Count        int                   `json:"count"`
+	Appointments []AppointmentResponse `json:"appointments"`
+}
+
+type CalendarWeek struct {
+	Start string        `json:"start"`
+	End   string        `json:"end"`
+	Count int           `json:"count"`
+	Days  []CalendarDay `json:"days"`
+}
+
+// GetAppointmentCalendar returns a month grid of the caller's appointments:
+// whole weeks covering the month (month=YYYY-MM, default the current month),
+// starting on Monday or on Sunday with week_start=sunday. Days are in the
+// caller's time zone and take the status, type, modality, doctor_id and
+// patient_id filters of GetAppointments.
+func GetAppointmentCalendar(c *gin.Context) {
+	loc := userLocation(c.GetUint("user_id"))
+
+	month := time.Now().In(loc)
+	if value := c.Query("month"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01", value, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "month must be YYYY-MM"})
+			return
+		}
+		month = parsed
+	}
+	first := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, loc)
+
+	weekStart := time.Monday
+	switch c.DefaultQuery("week_start", "monday") {
+	case "monday":
+	case "sunday":
+		weekStart = time.Sunday
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "week_start must be monday or sunday"})
+		return
+	}
+
+	// The grid runs from the start of the first week to the end of the last
+	gridStart := first.AddDate(0, 0, -((int(first.Weekday()) - int(weekStart) + 7) % 7))
+	last := first.AddDate(0, 1, -1)
+	gridEnd := last.AddDate(0, 0, 7-(int(last.Weekday())-int(weekStart)+7)%7)
+
+	filters, err := appointmentFilters(c, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	results, err := findAppointmentResponses(loc, append(filters, func(db *gorm.DB) *gorm.DB {
+		return db.Where("appointments.start_at >= ? AND appointments.start_at < ?", gridStart, gridEnd).
+			Order("appointments.start_at asc")
+	})...)
+	if err != nil {
+		log.Printf("Error fetching appointment calendar: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch appointments"})
+		return
+	}
+
+	byDay := make(map[string][]AppointmentResponse)
+	for _, appointment := range results {
+		day := appointment.StartAt.In(loc).Format("2006-01-02")
+		byDay[day] = append(byDay[day], appointment)
+	}
+
+	weeks := []CalendarWeek{}
+	for start := gridStart; start.Before(gridEnd); start = start.AddDate(0, 0, 7) {
+		week := CalendarWeek{
+			Start: start.Format("2006-01-02"),
+			End:   start.AddDate(0, 0, 6).Format("2006-01-02"),
+		}
+		for i := 0; i < 7; i++ {
+			date := start.AddDate(0, 0, i)
+			key := date.Format("2006-01-02")
+			day := CalendarDay{
+				Date:         key,
+				InMonth:      date.Month() == first.Month(),
+				Count:        len(byDay[key]),
+				Appointments: byDay[key],
+			}
+			if day.Appointments == nil {
+				day.Appointments = []AppointmentResponse{}
+			}
+			week.Count += day.Count
+			week.Days = append(week.Days, day)
+		}
+		weeks = append(weeks, week)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"month":      first.Format("2006-01"),
+		"time_zone":  loc.String(),
+		"week_start": map[time.Weekday]string{time.Monday: "monday", time.Sunday: "sunday"}[weekStart],
+		"total":      len(results),
+		"weeks":      weeks,
+	})
+}
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"dementicare-backend/models"
+	"dementicare-backend/scheduling"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestGetAppointmentCalendar(t *testing.T) {
+	db := useTestDB(t)
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2026, month, day, hour, 0, 0, 0, scheduling.Location())
+	}
+	for _, a := range []models.Appointment{
+		{PatientID: 10, StartAt: at(2, 27, 10), Status: "confirmed"},
+		{PatientID: 10, StartAt: at(3, 2, 10), Status: "confirmed"},
+		{PatientID: 10, StartAt: at(3, 2, 15), Status: "cancelled"},
+		{PatientID: 10, StartAt: at(3, 31, 9), Status: "pending"},
+		{PatientID: 10, StartAt: at(4, 10, 9), Status: "pending"},
+		{PatientID: 11, StartAt: at(3, 2, 11), Status: "confirmed"},
+	} {
+		a.DoctorID, a.EndAt = 1, a.StartAt.Add(30*time.Minute)
+		db.Create(&a)
+	}
+	asPatient := caller{id: 10, userType: "patient"}
+
+	// 1 March 2026 is a Sunday
+	tests := []struct {
+		query     string
+		weeks     int
+		firstWeek string
+		total     int
+		march2    int
+	}{
+		{"?month=2026-03", 6, "2026-02-23", 4, 2},
+		{"?month=2026-03&status=confirmed", 6, "2026-02-23", 2, 1},
+		{"?month=2026-03&week_start=sunday", 5, "2026-03-01", 3, 2},
+	}
+	for _, tt := range tests {
+		w := serve(GetAppointmentCalendar, "GET", "/appointments/calendar", "/appointments/calendar"+tt.query, asPatient, nil)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want %d: %s", tt.query, w.Code, http.StatusOK, w.Body)
+			continue
+		}
+		var resp struct {
+			Total int            `json:"total"`
+			Weeks []CalendarWeek `json:"weeks"`
+		}
+		decode(t, w, &resp)
+		if len(resp.Weeks) != tt.weeks || resp.Weeks[0].Start != tt.firstWeek || resp.Total != tt.total {
+			t.Errorf("%s: %d weeks from %s with %d appointments, want %d from %s with %d",
+				tt.query, len(resp.Weeks), resp.Weeks[0].Start, resp.Total, tt.weeks, tt.firstWeek, tt.total)
+			continue
+		}
+		for _, week := range resp.Weeks {
+			for _, day := range week.Days {
+				if day.Date == "2026-03-02" && day.Count != tt.march2 {
+					t.Errorf("%s: %d appointments on 2 March, want %d", tt.query, day.Count, tt.march2)
+				}
+				if day.InMonth != (day.Date[:7] == "2026-03") {
+					t.Errorf("%s: %s in month = %v", tt.query, day.Date, day.InMonth)
+				}
+			}
+		}
+	}
+
+	// Days outside the month still show their appointments
+	w := serve(GetAppointmentCalendar, "GET", "/appointments/calendar", "/appointments/calendar?month=2026-03", asPatient, nil)
+	var resp struct {
+		Weeks []CalendarWeek `json:"weeks"`
+	}
+	decode(t, w, &resp)
+	if friday := resp.Weeks[0].Days[4]; friday.Date != "2026-02-27" || friday.Count != 1 || resp.Weeks[0].Count != 1 {
+		t.Errorf("first week %+v, want the appointment on 27 February", resp.Weeks[0])
+	}
+
+	for _, query := range []string{"?month=2026-13", "?month=March", "?week_start=friday"} {
+		if w := serve(GetAppointmentCalendar, "GET", "/appointments/calendar", "/appointments/calendar"+query, asPatient, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+	}
+}
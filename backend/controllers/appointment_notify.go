@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"dementicare-backend/config"
+	"dementicare-backend/models"
+	"dementicare-backend/notify"
+	"fmt"
+	"log"
+	"time"
+)
+
+// notifyPatient tells the patient that someone else changed their
+// appointments, e.g. "Jane Smith booked an appointment for you on ...".
+// Nothing is sent when the patient made the change themselves. dedupeKey
+// keeps a retried request from notifying twice.
+func notifyPatient(patientID, actorID uint, dedupeKey, change string) {
+	if patientID == actorID {
+		return
+	}
+
+	var actor models.User
+	config.DB.Select("name").First(&actor, actorID)
+
+	err := notify.Enqueue(config.DB, models.Notification{
+		RecipientID: patientID,
+		Kind:        "appointment_update",
+		Subject:     "Your appointments have changed",
+		Body:        fmt.Sprintf("%s %s.", actor.Name, change),
+		DedupeKey:   "appointment-update:" + dedupeKey,
+	})
+	if err != nil {
+		log.Printf("Error queueing notification for patient %d: %v", patientID, err)
+	}
+}
+
+// patientTime formats t in the patient's time zone for messages
+func patientTime(patientID uint, t time.Time) string {
+	return t.In(userLocation(patientID)).Format("Monday 2 January at 15:04")
+}
@@ -0,0 +1,484 @@
+package controllers
+
+import (
+	"dementicare-backend/config"
+	"dementicare-backend/models"
+	"dementicare-backend/scheduling"
+	"dementicare-backend/waitlist"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errSeriesConflicts = errors.New("series occurrences conflict with existing bookings")
+
+type AppointmentSeriesRequest struct {
+	PatientID     uint       `json:"patient_id"` // the patient's user ID, when a caregiver books
+	DoctorID      uint       `json:"doctor_id" binding:"required"`
+	StartAt       *time.Time `json:"start_at"` // first occurrence; or date + time in clinic time
+	Date          time.Time  `json:"date"`
+	Time          string     `json:"time"`
+	Type          string     `json:"type"`
+	Modality      string     `json:"modality"`
+	Frequency     string     `json:"frequency" binding:"required"`
+	Interval      int        `json:"interval"`
+	Count         int        `json:"count"`
+	Until         *time.Time `json:"until"`
+	Notes         string     `json:"notes"`
+	SkipConflicts bool       `json:"skip_conflicts"`
+}
+
+// SeriesScopeRequest picks which occurrences an edit or cancellation applies
+// to: "one" (only appointment_id), "this_and_following" (appointment_id and
+// every later occurrence) or "all".
+type SeriesScopeRequest struct {
+	Scope         string  `json:"scope" binding:"required"`
+	AppointmentID uint    `json:"appointment_id"`
+	Time          *string `json:"time"`
+	Type          *string `json:"type"`
+	Notes         *string `json:"notes"`
+	Reason        string  `json:"reason"`
+	SkipConflicts bool    `json:"skip_conflicts"`
+}
+
+// SeriesConflict describes an occurrence that could not be booked
+type SeriesConflict struct {
+	StartAt time.Time `json:"start_at"`
+	Error   string    `json:"error"`
+}
+
+// CreateAppointmentSeries books every occurrence of a recurrence rule. Each
+// occurrence goes through the same conflict checks as a single booking; any
+// clash rejects the whole series unless skip_conflicts is set.
+func CreateAppointmentSeries(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var req AppointmentSeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	patientID, ok := bookingPatient(c, req.PatientID)
+	if !ok {
+		return
+	}
+	if req.Interval == 0 {
+		req.Interval = 1
+	}
+	modality, ok := normalizeModality(req.Modality)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "modality must be in_person or virtual"})
+		return
+	}
+
+	first, err := requestedStart(req.StartAt, req.Date, req.Time)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Occurrences keep the same clinic wall-clock time across DST changes
+	loc := scheduling.Location()
+	first = first.In(loc)
+
+	rule := scheduling.Rule{Frequency: strings.ToLower(req.Frequency), Interval: req.Interval, Count: req.Count}
+	if req.Until != nil {
+		// until is inclusive of the whole day
+		until := *req.Until
+		until = time.Date(until.Year(), until.Month(), until.Day(), 23, 59, 59, 0, loc)
+		rule.Until = &until
+	}
+	if err := rule.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	starts := rule.Occurrences(first)
+	if len(starts) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The rule produces no occurrences"})
+		return
+	}
+
+	series := models.AppointmentSeries{
+		PatientID: patientID,
+		DoctorID:  req.DoctorID,
+		Type:      req.Type,
+		Modality:  modality,
+		StartDate: first.UTC(),
+		Time:      first.Format("15:04"),
+		TimeZone:  scheduling.ZoneName(),
+		Frequency: rule.Frequency,
+		Interval:  rule.Interval,
+		Count:     rule.Count,
+		Until:     rule.Until,
+		Notes:     req.Notes,
+		Status:    "active",
+		CreatedBy: userID,
+	}
+
+	var booked []models.Appointment
+	var conflicts []SeriesConflict
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&series).Error; err != nil {
+			return err
+		}
+
+		for i, start := range starts {
+			appointment := models.Appointment{
+				PatientID:   patientID,
+				BookedBy:    userID,
+				DoctorID:    req.DoctorID,
+				StartAt:     start,
+				Type:        req.Type,
+				Modality:    modality,
+				Status:      "pending",
+				Notes:       req.Notes,
+				SeriesID:    &series.ID,
+				SeriesIndex: i,
+			}
+			if err := scheduling.Reserve(tx, &appointment, 0); err != nil {
+				if !isSlotConflict(err) {
+					return err
+				}
+				conflicts = append(conflicts, SeriesConflict{StartAt: start.UTC(), Error: err.Error()})
+				continue
+			}
+			if err := applyNoShowPolicy(tx, &appointment, userID); err != nil {
+				return err
+			}
+			if err := tx.Create(&appointment).Error; err != nil {
+				return err
+			}
+			if err := recordStatusChange(tx, appointment.ID, "", "pending", userID, ""); err != nil {
+				return err
+			}
+			booked = append(booked, appointment)
+		}
+
+		if len(booked) == 0 || (len(conflicts) > 0 && !req.SkipConflicts) {
+			return errSeriesConflicts
+		}
+		return nil
+	})
+	if errors.Is(err, errSeriesConflicts) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":     "Some occurrences clash with existing bookings; choose another time or set skip_conflicts",
+			"conflicts": conflicts,
+		})
+		return
+	}
+	if err != nil {
+		respondBookingError(c, err, models.Appointment{DoctorID: req.DoctorID}, "Failed to create appointment series")
+		return
+	}
+
+	notifyPatient(patientID, userID, fmt.Sprintf("series-booked:%d", series.ID),
+		fmt.Sprintf("booked %d appointments for you, starting %s", len(booked), patientTime(patientID, booked[0].StartAt)))
+	if booked[0].NeedsCaregiver && booked[0].CaregiverConfirmBy == nil {
+		requestCaregiverConfirmation(patientID, fmt.Sprintf("series:%d", series.ID),
+			fmt.Sprintf("%d appointments starting %s", len(booked), patientTime(patientID, booked[0].StartAt)))
+	}
+
+	log.Printf("Appointment series created - ID: %d, Patient: %d, Doctor: %d, Occurrences: %d, Skipped: %d, Booked by: %d", series.ID, patientID, req.DoctorID, len(booked), len(conflicts), userID)
+	c.JSON(http.StatusCreated, gin.H{"series": series, "appointments": booked, "skipped": conflicts})
+}
+
+func GetAppointmentSeries(c *gin.Context) {
+	series, _, ok := findSeriesForAction(c, "doctor", "patient", "caregiver", "admin")
+	if !ok {
+		return
+	}
+
+	var appointments []models.Appointment
+	if err := config.DB.Where("series_id = ?", series.ID).Order("series_index asc, id asc").Find(&appointments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch series appointments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"series": series, "appointments": appointments})
+}
+
+// UpdateAppointmentSeries edits occurrences in the chosen scope. Notes are
+// changed in place; a new time or type reschedules each occurrence, so every
+// replacement is conflict-checked like a single reschedule.
+func UpdateAppointmentSeries(c *gin.Context) {
+	series, role, ok := findSeriesForAction(c, "doctor", "patient", "caregiver")
+	if !ok {
+		return
+	}
+
+	var req SeriesScopeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Time == nil && req.Type == nil && req.Notes == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update; give time, type or notes"})
+		return
+	}
+	if req.Time != nil {
+		if _, err := scheduling.ParseClock(*req.Time); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Time must be HH:MM"})
+			return
+		}
+	}
+
+	userID := c.GetUint("user_id")
+	var updated, freed []models.Appointment
+	var conflicts []SeriesConflict
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		occurrences, err := seriesOccurrencesInScope(tx, series, req.Scope, req.AppointmentID)
+		if err != nil {
+			return err
+		}
+
+		for _, original := range occurrences {
+			if (req.Time == nil || *req.Time == original.Time) && (req.Type == nil || *req.Type == original.Type) {
+				if req.Notes != nil {
+					original.Notes = *req.Notes
+					original.Sequence++
+					if err := tx.Model(&original).Updates(map[string]interface{}{"notes": original.Notes, "sequence": original.Sequence}).Error; err != nil {
+						return err
+					}
+				}
+				updated = append(updated, original)
+				continue
+			}
+
+			replacement := models.Appointment{
+				PatientID:         original.PatientID,
+				DoctorID:          original.DoctorID,
+				StartAt:           scheduling.AppointmentStart(original),
+				Type:              original.Type,
+				Modality:          original.Modality,
+				Status:            "pending",
+				Notes:             original.Notes,
+				RescheduledFromID: &original.ID,
+				BookedBy:          userID,
+				SeriesID:          original.SeriesID,
+				SeriesIndex:       original.SeriesIndex,
+			}
+			if req.Time != nil {
+				// Same clinic-time day, new clock time
+				replacement.StartAt, _ = scheduling.StartFromClock(replacement.StartAt.In(scheduling.Location()), *req.Time)
+			}
+			if req.Type != nil {
+				replacement.Type = *req.Type
+			}
+			if req.Notes != nil {
+				replacement.Notes = *req.Notes
+			}
+
+			if err := scheduling.Reserve(tx, &replacement, original.ID); err != nil {
+				if !isSlotConflict(err) {
+					return err
+				}
+				conflicts = append(conflicts, SeriesConflict{StartAt: original.StartAt, Error: err.Error()})
+				continue
+			}
+			if err := transitionAppointment(tx, &original, "rescheduled", userID, role, req.Reason); err != nil {
+				return err
+			}
+			freed = append(freed, original)
+			if err := applyNoShowPolicy(tx, &replacement, userID); err != nil {
+				return err
+			}
+			if err := tx.Create(&replacement).Error; err != nil {
+				return err
+			}
+			if err := recordStatusChange(tx, replacement.ID, "", "pending", userID, "Rescheduled from appointment "+uintToString(original.ID)); err != nil {
+				return err
+			}
+			updated = append(updated, replacement)
+		}
+
+		if len(conflicts) > 0 && !req.SkipConflicts {
+			return errSeriesConflicts
+		}
+
+		// Editing the whole series changes its template too
+		if req.Scope == "all" {
+			seriesUpdates := map[string]interface{}{}
+			if req.Time != nil {
+				seriesUpdates["time"] = *req.Time
+			}
+			if req.Type != nil {
+				seriesUpdates["type"] = *req.Type
+			}
+			if req.Notes != nil {
+				seriesUpdates["notes"] = *req.Notes
+			}
+			if err := tx.Model(&series).Updates(seriesUpdates).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if !respondSeriesError(c, err, conflicts, "Failed to update appointment series") {
+		return
+	}
+	if req.Scope == "all" {
+		// Show the new template
+		if err := config.DB.First(&series, series.ID).Error; err != nil {
+			log.Printf("Error reloading series %d: %v", series.ID, err)
+		}
+	}
+
+	for _, original := range freed {
+		waitlist.OfferFreedSlot(config.DB, original)
+	}
+
+	if len(freed) > 0 {
+		notifyPatient(series.PatientID, userID, fmt.Sprintf("series-rescheduled:%d:%d", series.ID, freed[0].ID),
+			fmt.Sprintf("moved %d appointments in your series, starting %s", len(freed), patientTime(series.PatientID, updated[0].StartAt)))
+		if updated[0].NeedsCaregiver && updated[0].CaregiverConfirmBy == nil {
+			requestCaregiverConfirmation(series.PatientID, fmt.Sprintf("series:%d:%d", series.ID, updated[0].ID),
+				fmt.Sprintf("%d rescheduled appointments starting %s", len(updated), patientTime(series.PatientID, updated[0].StartAt)))
+		}
+	}
+
+	log.Printf("Appointment series updated - ID: %d, Scope: %s, Occurrences: %d, By: %d", series.ID, req.Scope, len(updated), userID)
+	c.JSON(http.StatusOK, gin.H{"series": series, "appointments": updated, "skipped": conflicts})
+}
+
+// CancelAppointmentSeries cancels occurrences in the chosen scope. A reason
+// is required, as for single cancellations.
+func CancelAppointmentSeries(c *gin.Context) {
+	series, role, ok := findSeriesForAction(c, "doctor", "patient", "caregiver")
+	if !ok {
+		return
+	}
+
+	var req SeriesScopeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required"})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	var cancelled []models.Appointment
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		occurrences, err := seriesOccurrencesInScope(tx, series, req.Scope, req.AppointmentID)
+		if err != nil {
+			return err
+		}
+		for _, appointment := range occurrences {
+			if err := transitionAppointment(tx, &appointment, "cancelled", userID, role, req.Reason); err != nil {
+				return err
+			}
+			cancelled = append(cancelled, appointment)
+		}
+
+		if req.Scope == "all" {
+			series.Status = "cancelled"
+			return tx.Model(&series).Update("status", series.Status).Error
+		}
+		return nil
+	})
+	if !respondSeriesError(c, err, nil, "Failed to cancel appointment series") {
+		return
+	}
+
+	for _, appointment := range cancelled {
+		waitlist.OfferFreedSlot(config.DB, appointment)
+	}
+
+	notifyPatient(series.PatientID, userID, fmt.Sprintf("series-cancelled:%d:%d", series.ID, cancelled[0].ID),
+		fmt.Sprintf("cancelled %d appointments in your series, starting %s", len(cancelled), patientTime(series.PatientID, cancelled[0].StartAt)))
+
+	log.Printf("Appointment series cancelled - ID: %d, Scope: %s, Occurrences: %d, By: %d", series.ID, req.Scope, len(cancelled), userID)
+	c.JSON(http.StatusOK, gin.H{"series": series, "appointments": cancelled})
+}
+
+// seriesOccurrencesInScope returns the pending or confirmed occurrences an
+// edit applies to, locked for update.
+func seriesOccurrencesInScope(tx *gorm.DB, series models.AppointmentSeries, scope string, appointmentID uint) ([]models.Appointment, error) {
+	query := tx.Where("series_id = ? AND status IN ?", series.ID, []string{"pending", "confirmed"})
+
+	switch scope {
+	case "all":
+	case "one", "this_and_following":
+		var anchor models.Appointment
+		if err := tx.Where("id = ? AND series_id = ?", appointmentID, series.ID).First(&anchor).Error; err != nil {
+			return nil, errSeriesAnchor
+		}
+		if scope == "one" {
+			query = query.Where("id = ?", anchor.ID)
+		} else {
+			query = query.Where("series_index >= ?", anchor.SeriesIndex)
+		}
+	default:
+		return nil, errSeriesScope
+	}
+
+	var occurrences []models.Appointment
+	if err := query.Clauses(clause.Locking{Strength: "UPDATE"}).Order("series_index asc").Find(&occurrences).Error; err != nil {
+		return nil, err
+	}
+	if len(occurrences) == 0 {
+		return nil, errInvalidTransition
+	}
+	return occurrences, nil
+}
+
+var (
+	errSeriesScope  = errors.New("scope must be one, this_and_following or all")
+	errSeriesAnchor = errors.New("appointment_id must be an occurrence of this series")
+)
+
+// respondSeriesError writes the response for a failed series edit and
+// reports whether the edit succeeded.
+func respondSeriesError(c *gin.Context, err error, conflicts []SeriesConflict, fallback string) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, errSeriesScope), errors.Is(err, errSeriesAnchor):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, errInvalidTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": "No pending or confirmed occurrences in this scope"})
+	case errors.Is(err, errSeriesConflicts):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":     "Some occurrences clash with existing bookings; choose another time or set skip_conflicts",
+			"conflicts": conflicts,
+		})
+	default:
+		log.Printf("Error updating appointment series: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+	return false
+}
+
+func isSlotConflict(err error) bool {
+	return errors.Is(err, scheduling.ErrSlotTaken) || errors.Is(err, scheduling.ErrSlotUnavailable)
+}
+
+// findSeriesForAction loads the series named by :id and checks the caller
+// takes part in it in one of the given roles, like findAppointmentForAction.
+func findSeriesForAction(c *gin.Context, roles ...string) (models.AppointmentSeries, string, bool) {
+	var series models.AppointmentSeries
+	if err := config.DB.First(&series, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Appointment series not found"})
+		return series, "", false
+	}
+
+	role := appointmentRole(c, models.Appointment{PatientID: series.PatientID, DoctorID: series.DoctorID})
+	for _, allowed := range roles {
+		if role == allowed {
+			return series, role, true
+		}
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to perform this action on the series"})
+	return series, role, false
+}
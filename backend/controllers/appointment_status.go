@@ -0,0 +1,322 @@
+package controllers
+
+import (
+	"dementicare-backend/attendance"
+	"dementicare-backend/config"
+	"dementicare-backend/models"
+	"dementicare-backend/scheduling"
+	"dementicare-backend/waitlist"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	errInvalidTransition = errors.New("invalid appointment status transition")
+	// errNoShowTooEarly is returned when a no-show is recorded before the
+	// appointment has started
+	errNoShowTooEarly = errors.New("appointment has not started yet")
+	// errAwaitingCaregiver is returned when the doctor confirms a booking
+	// that still needs a caregiver's confirmation
+	errAwaitingCaregiver = errors.New("appointment awaits caregiver confirmation")
+)
+
+// Allowed status transitions. completed, cancelled, no_show and rescheduled
+// are final.
+var appointmentTransitions = map[string]map[string]bool{
+	"pending":   {"confirmed": true, "cancelled": true, "rescheduled": true},
+	"confirmed": {"completed": true, "cancelled": true, "no_show": true, "rescheduled": true},
+}
+
+type AppointmentReasonRequest struct {
+	Reason string `json:"reason"`
+}
+
+// RescheduleRequest takes the new start as start_at (RFC3339), or as date
+// and an HH:MM time in clinic time
+type RescheduleRequest struct {
+	StartAt *time.Time `json:"start_at"`
+	Date    time.Time  `json:"date"`
+	Time    string     `json:"time"`
+	Type    string     `json:"type"`
+	Reason  string     `json:"reason"`
+}
+
+// ConfirmAppointment moves a pending appointment to confirmed (doctor only)
+func ConfirmAppointment(c *gin.Context) {
+	changeAppointmentStatus(c, "confirmed", false, "doctor")
+}
+
+// CompleteAppointment marks a confirmed appointment as completed (doctor only)
+func CompleteAppointment(c *gin.Context) {
+	changeAppointmentStatus(c, "completed", false, "doctor")
+}
+
+// MarkAppointmentNoShow records that the patient did not attend (doctor only)
+func MarkAppointmentNoShow(c *gin.Context) {
+	changeAppointmentStatus(c, "no_show", false, "doctor")
+}
+
+// CancelAppointment cancels a pending or confirmed appointment. The doctor,
+// the patient or a caregiver looking after them may cancel and must give a
+// reason.
+func CancelAppointment(c *gin.Context) {
+	changeAppointmentStatus(c, "cancelled", true, "doctor", "patient", "caregiver")
+}
+
+// RescheduleAppointment books a replacement at a new time, linked to the
+// original, and marks the original as rescheduled.
+func RescheduleAppointment(c *gin.Context) {
+	original, role, ok := findAppointmentForAction(c, "doctor", "patient", "caregiver")
+	if !ok {
+		return
+	}
+
+	var req RescheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	start, err := requestedStart(req.StartAt, req.Date, req.Time)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	replacement := models.Appointment{
+		PatientID:         original.PatientID,
+		DoctorID:          original.DoctorID,
+		StartAt:           start,
+		Type:              original.Type,
+		Modality:          original.Modality,
+		Status:            "pending",
+		Notes:             original.Notes,
+		RescheduledFromID: &original.ID,
+		BookedBy:          c.GetUint("user_id"),
+	}
+	if req.Type != "" {
+		replacement.Type = req.Type
+	}
+
+	userID := c.GetUint("user_id")
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// The original's own slot may be reused by the replacement
+		if err := scheduling.Reserve(tx, &replacement, original.ID); err != nil {
+			return err
+		}
+		if err := applyNoShowPolicy(tx, &replacement, userID); err != nil {
+			return err
+		}
+		if err := transitionAppointment(tx, &original, "rescheduled", userID, role, req.Reason); err != nil {
+			return err
+		}
+		if err := tx.Create(&replacement).Error; err != nil {
+			return err
+		}
+		return recordStatusChange(tx, replacement.ID, "", "pending", userID, "Rescheduled from appointment "+uintToString(original.ID))
+	})
+	if errors.Is(err, errInvalidTransition) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only pending or confirmed appointments can be rescheduled"})
+		return
+	}
+	if err != nil {
+		respondBookingError(c, err, replacement, "Failed to reschedule appointment")
+		return
+	}
+
+	waitlist.OfferFreedSlot(config.DB, original)
+	notifyPatient(original.PatientID, userID, "rescheduled:"+uintToString(original.ID),
+		"moved your appointment on "+patientTime(original.PatientID, scheduling.AppointmentStart(original))+
+			" to "+patientTime(original.PatientID, replacement.StartAt))
+	if replacement.NeedsCaregiver && replacement.CaregiverConfirmBy == nil {
+		requestCaregiverConfirmation(replacement.PatientID, "appointment:"+uintToString(replacement.ID),
+			"an appointment on "+patientTime(replacement.PatientID, replacement.StartAt))
+	}
+
+	log.Printf("Appointment rescheduled - Original: %d, New: %d, By: %s %d", original.ID, replacement.ID, role, userID)
+	c.JSON(http.StatusCreated, gin.H{"appointment": replacement, "original": original})
+}
+
+func GetAppointmentHistory(c *gin.Context) {
+	appointment, _, ok := findAppointmentForAction(c, "doctor", "patient", "caregiver", "admin")
+	if !ok {
+		return
+	}
+
+	var history []models.AppointmentStatusChange
+	if err := config.DB.Where("appointment_id = ?", appointment.ID).Order("created_at asc, id asc").Find(&history).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch appointment history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"history": history})
+}
+
+func changeAppointmentStatus(c *gin.Context, to string, reasonRequired bool, roles ...string) {
+	appointment, role, ok := findAppointmentForAction(c, roles...)
+	if !ok {
+		return
+	}
+
+	// The body is optional; a chunked request has no content length, so an
+	// empty one only shows up as EOF
+	var req AppointmentReasonRequest
+	if c.Request.Body != http.NoBody {
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if reasonRequired && req.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		return transitionAppointment(tx, &appointment, to, c.GetUint("user_id"), role, req.Reason)
+	})
+	if errors.Is(err, errInvalidTransition) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot change appointment from " + appointment.Status + " to " + to})
+		return
+	}
+	if errors.Is(err, errNoShowTooEarly) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A no-show can only be recorded once the appointment has started"})
+		return
+	}
+	if errors.Is(err, errAwaitingCaregiver) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A caregiver must confirm this appointment first, as the patient has missed several recent appointments"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error changing appointment %d to %s: %v", appointment.ID, to, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update appointment status"})
+		return
+	}
+
+	if to == "cancelled" {
+		waitlist.OfferFreedSlot(config.DB, appointment)
+		notifyPatient(appointment.PatientID, c.GetUint("user_id"), "cancelled:"+uintToString(appointment.ID),
+			"cancelled your appointment on "+patientTime(appointment.PatientID, scheduling.AppointmentStart(appointment)))
+	}
+
+	c.JSON(http.StatusOK, appointment)
+}
+
+// transitionAppointment moves the appointment to a new status if the state
+// machine allows it and records the change in the status history. role is
+// how the user relates to the appointment (see appointmentRole).
+func transitionAppointment(tx *gorm.DB, appointment *models.Appointment, to string, userID uint, role, reason string) error {
+	from := appointment.Status
+	if !appointmentTransitions[from][to] {
+		return errInvalidTransition
+	}
+	now := time.Now()
+	if to == "no_show" && scheduling.AppointmentStart(*appointment).After(now) {
+		return errNoShowTooEarly
+	}
+	if to == "confirmed" && appointment.NeedsCaregiver && appointment.CaregiverConfirmBy == nil {
+		return errAwaitingCaregiver
+	}
+
+	updates := map[string]interface{}{"status": to, "sequence": gorm.Expr("sequence + 1")}
+	if to == "cancelled" {
+		updates["cancellation_reason"] = reason
+		// Only cancellations by the patient's side count against them
+		patientSide := role == "patient" || role == "caregiver"
+		if patientSide && attendance.IsLateCancellation(scheduling.AppointmentStart(*appointment), now) {
+			updates["late_cancellation"] = true
+		}
+	}
+	// Guard on the old status so a concurrent transition cannot be overwritten
+	result := tx.Model(&models.Appointment{}).Where("id = ? AND status = ?", appointment.ID, from).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errInvalidTransition
+	}
+
+	appointment.Status = to
+	appointment.Sequence++
+	if to == "cancelled" {
+		appointment.CancellationReason = reason
+		_, appointment.LateCancellation = updates["late_cancellation"]
+	}
+	return recordStatusChange(tx, appointment.ID, from, to, userID, reason)
+}
+
+func recordStatusChange(tx *gorm.DB, appointmentID uint, from, to string, userID uint, reason string) error {
+	return tx.Create(&models.AppointmentStatusChange{
+		AppointmentID: appointmentID,
+		FromStatus:    from,
+		ToStatus:      to,
+		ChangedBy:     userID,
+		Reason:        reason,
+	}).Error
+}
+
+// findAppointmentForAction loads the appointment named by :id and checks that
+// the caller takes part in it in one of the given roles: "doctor" for the
+// appointment's doctor, "patient" for its patient, "caregiver" for a
+// caregiver or care-team member of the patient and "admin" for admins.
+func findAppointmentForAction(c *gin.Context, roles ...string) (models.Appointment, string, bool) {
+	var appointment models.Appointment
+	if err := config.DB.First(&appointment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
+		return appointment, "", false
+	}
+
+	role := appointmentRole(c, appointment)
+	for _, allowed := range roles {
+		if role == allowed {
+			return appointment, role, true
+		}
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to perform this action on the appointment"})
+	return appointment, role, false
+}
+
+// appointmentRole returns how the caller relates to the appointment
+func appointmentRole(c *gin.Context, appointment models.Appointment) string {
+	userID := c.GetUint("user_id")
+	switch {
+	case c.GetString("user_type") == "doctor" && appointment.DoctorID == userID:
+		return "doctor"
+	case c.GetString("user_type") == "patient" && appointment.PatientID == userID:
+		return "patient"
+	case c.GetString("user_type") == "caregiver" && caresForPatientUser(userID, appointment.PatientID):
+		return "caregiver"
+	case c.GetString("user_type") == "admin":
+		return "admin"
+	}
+	return ""
+}
+
+// requestedStart reads a start given either as an RFC3339 start_at or as a
+// date with an HH:MM time in clinic time
+func requestedStart(startAt *time.Time, date time.Time, clock string) (time.Time, error) {
+	if startAt != nil && !startAt.IsZero() {
+		return *startAt, nil
+	}
+	if date.IsZero() {
+		return time.Time{}, errors.New("Give start_at, or date with time as HH:MM")
+	}
+	start, err := scheduling.StartFromClock(date, clock)
+	if err != nil {
+		return time.Time{}, errors.New("Give start_at, or date with time as HH:MM")
+	}
+	return start, nil
+}
+
+func uintToString(n uint) string {
+	return strconv.FormatUint(uint64(n), 10)
+}
@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"dementicare-backend/models"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// chunked streams s without a content length
+func chunked(s string) io.Reader {
+	return io.MultiReader(strings.NewReader(s))
+}
+
+func TestCancelAppointmentBody(t *testing.T) {
+	db := useTestDB(t)
+	doctor := caller{id: 1, userType: "doctor"}
+
+	tests := []struct {
+		name   string
+		body   interface{}
+		status int
+		reason string
+	}{
+		{"JSON body", AppointmentReasonRequest{Reason: "Unwell"}, http.StatusOK, "Unwell"},
+		{"chunked body", chunked(`{"reason": "Hospital admission"}`), http.StatusOK, "Hospital admission"},
+		{"empty chunked body", chunked(""), http.StatusBadRequest, ""},
+		{"no body", nil, http.StatusBadRequest, ""},
+		{"malformed body", chunked(`{"reason":`), http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		start := time.Now().Add(72 * time.Hour).UTC()
+		appointment := models.Appointment{PatientID: 10, DoctorID: 1, StartAt: start, EndAt: start.Add(30 * time.Minute), Status: "confirmed"}
+		db.Create(&appointment)
+		target := "/appointments/" + strconv.Itoa(int(appointment.ID)) + "/cancel"
+		w := serve(CancelAppointment, "POST", "/appointments/:id/cancel", target, doctor, tt.body)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+			continue
+		}
+		db.First(&appointment, appointment.ID)
+		if tt.status == http.StatusOK && (appointment.Status != "cancelled" || appointment.CancellationReason != tt.reason) {
+			t.Errorf("%s: appointment %s with reason %q, want cancelled with %q", tt.name, appointment.Status, appointment.CancellationReason, tt.reason)
+		}
+	}
+
+	// Reasons are optional when confirming
+	start := time.Now().Add(72 * time.Hour).UTC()
+	appointment := models.Appointment{PatientID: 10, DoctorID: 1, StartAt: start, EndAt: start.Add(30 * time.Minute), Status: "pending"}
+	db.Create(&appointment)
+	target := "/appointments/" + strconv.Itoa(int(appointment.ID)) + "/confirm"
+	if w := serve(ConfirmAppointment, "POST", "/appointments/:id/confirm", target, doctor, chunked("")); w.Code != http.StatusOK {
+		t.Errorf("confirm with an empty chunked body: status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+}
+
+func TestAppointmentTransitions(t *testing.T) {
+	db := useTestDB(t)
+	db.Create(&models.Patient{UserID: 10, Name: "Edith", CaregiverID: 20})
+	doctor := caller{id: 1, userType: "doctor"}
+	patient := caller{id: 10, userType: "patient"}
+	caregiver := caller{id: 20, userType: "caregiver"}
+	reason := AppointmentReasonRequest{Reason: "Unwell"}
+	later, soon, past := 72*time.Hour, 2*time.Hour, -time.Hour
+
+	tests := []struct {
+		name   string
+		from   string
+		in     time.Duration
+		action gin.HandlerFunc
+		path   string
+		as     caller
+		body   interface{}
+		status int
+		want   string
+		late   bool
+	}{
+		{"doctor confirms", "pending", later, ConfirmAppointment, "confirm", doctor, nil, http.StatusOK, "confirmed", false},
+		{"patient cannot confirm", "pending", later, ConfirmAppointment, "confirm", patient, nil, http.StatusForbidden, "pending", false},
+		{"other doctor cannot confirm", "pending", later, ConfirmAppointment, "confirm", caller{id: 2, userType: "doctor"}, nil, http.StatusForbidden, "pending", false},
+		{"confirm twice", "confirmed", later, ConfirmAppointment, "confirm", doctor, nil, http.StatusConflict, "confirmed", false},
+		{"doctor completes", "confirmed", past, CompleteAppointment, "complete", doctor, nil, http.StatusOK, "completed", false},
+		{"complete a pending appointment", "pending", past, CompleteAppointment, "complete", doctor, nil, http.StatusConflict, "pending", false},
+		{"caregiver cannot complete", "confirmed", past, CompleteAppointment, "complete", caregiver, nil, http.StatusForbidden, "confirmed", false},
+		{"no-show after the start", "confirmed", past, MarkAppointmentNoShow, "no-show", doctor, nil, http.StatusOK, "no_show", false},
+		{"no-show before the start", "confirmed", later, MarkAppointmentNoShow, "no-show", doctor, nil, http.StatusConflict, "confirmed", false},
+		{"patient cancels early", "confirmed", later, CancelAppointment, "cancel", patient, reason, http.StatusOK, "cancelled", false},
+		{"caregiver cancels late", "pending", soon, CancelAppointment, "cancel", caregiver, reason, http.StatusOK, "cancelled", true},
+		{"doctor cancels late", "confirmed", soon, CancelAppointment, "cancel", doctor, reason, http.StatusOK, "cancelled", false},
+		{"unrelated caregiver cannot cancel", "confirmed", later, CancelAppointment, "cancel", caller{id: 21, userType: "caregiver"}, reason, http.StatusForbidden, "confirmed", false},
+		{"cancel a completed appointment", "completed", past, CancelAppointment, "cancel", doctor, reason, http.StatusConflict, "completed", false},
+	}
+	for _, tt := range tests {
+		start := time.Now().Add(tt.in).UTC()
+		appointment := models.Appointment{PatientID: 10, DoctorID: 1, StartAt: start, EndAt: start.Add(30 * time.Minute), Status: tt.from}
+		db.Create(&appointment)
+		target := "/appointments/" + strconv.Itoa(int(appointment.ID)) + "/" + tt.path
+		w := serve(tt.action, "POST", "/appointments/:id/"+tt.path, target, tt.as, tt.body)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+			continue
+		}
+
+		db.First(&appointment, appointment.ID)
+		if appointment.Status != tt.want || appointment.LateCancellation != tt.late {
+			t.Errorf("%s: appointment %s, late %v, want %s, late %v", tt.name, appointment.Status, appointment.LateCancellation, tt.want, tt.late)
+		}
+		var history []models.AppointmentStatusChange
+		db.Where("appointment_id = ?", appointment.ID).Find(&history)
+		switch {
+		case tt.status != http.StatusOK && len(history) != 0:
+			t.Errorf("%s: refused change recorded history %+v", tt.name, history)
+		case tt.status == http.StatusOK && (len(history) != 1 || history[0].FromStatus != tt.from || history[0].ToStatus != tt.want || history[0].ChangedBy != tt.as.id):
+			t.Errorf("%s: history %+v, want one change from %s to %s by %d", tt.name, history, tt.from, tt.want, tt.as.id)
+		}
+	}
+}
+
+func TestConfirmAwaitingCaregiver(t *testing.T) {
+	db := useTestDB(t)
+	start := time.Now().Add(72 * time.Hour).UTC()
+	appointment := models.Appointment{PatientID: 10, DoctorID: 1, StartAt: start, EndAt: start.Add(30 * time.Minute), Status: "pending", NeedsCaregiver: true}
+	db.Create(&appointment)
+	target := "/appointments/" + strconv.Itoa(int(appointment.ID)) + "/confirm"
+
+	if w := serve(ConfirmAppointment, "POST", "/appointments/:id/confirm", target, caller{id: 1, userType: "doctor"}, nil); w.Code != http.StatusConflict {
+		t.Errorf("confirm before the caregiver: status = %d, want %d: %s", w.Code, http.StatusConflict, w.Body)
+	}
+	caregiverID := uint(20)
+	db.Model(&appointment).Update("caregiver_confirm_by", caregiverID)
+	if w := serve(ConfirmAppointment, "POST", "/appointments/:id/confirm", target, caller{id: 1, userType: "doctor"}, nil); w.Code != http.StatusOK {
+		t.Errorf("confirm after the caregiver: status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+}
+
+func TestRescheduleAppointment(t *testing.T) {
+	db := useTestDB(t)
+	start := openClinic(t, db)
+	db.Create(&models.Patient{UserID: 10, Name: "Edith", CaregiverID: 20})
+	newSlot := func(at time.Time) RescheduleRequest {
+		return RescheduleRequest{StartAt: &at, Reason: "Clash with hospital visit"}
+	}
+
+	tests := []struct {
+		name   string
+		from   string
+		as     caller
+		body   RescheduleRequest
+		status int
+	}{
+		{"caregiver moves it", "confirmed", caller{id: 20, userType: "caregiver"}, newSlot(start.Add(time.Hour)), http.StatusCreated},
+		{"within its own slot", "pending", caller{id: 10, userType: "patient"}, newSlot(start.Add(30 * time.Minute)), http.StatusCreated},
+		{"onto a booked slot", "pending", caller{id: 1, userType: "doctor"}, newSlot(start.Add(-time.Hour)), http.StatusConflict},
+		{"outside working hours", "pending", caller{id: 1, userType: "doctor"}, newSlot(start.Add(-2 * time.Hour)), http.StatusConflict},
+		{"a cancelled appointment", "cancelled", caller{id: 1, userType: "doctor"}, newSlot(start.Add(2 * time.Hour)), http.StatusConflict},
+		{"by an admin", "pending", caller{id: 99, userType: "admin"}, newSlot(start.Add(2 * time.Hour)), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		// Each case starts from a fresh calendar with one other booking at 09:00
+		db.Where("1 = 1").Delete(&models.Appointment{})
+		db.Create(&models.Appointment{PatientID: 11, DoctorID: 1, StartAt: start.Add(-time.Hour), EndAt: start.Add(-30 * time.Minute), Status: "confirmed"})
+		original := models.Appointment{PatientID: 10, DoctorID: 1, StartAt: start, EndAt: start.Add(30 * time.Minute), Type: "consultation", Status: tt.from}
+		db.Create(&original)
+
+		target := "/appointments/" + strconv.Itoa(int(original.ID)) + "/reschedule"
+		w := serve(RescheduleAppointment, "POST", "/appointments/:id/reschedule", target, tt.as, tt.body)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+			continue
+		}
+
+		db.First(&original, original.ID)
+		var replacement models.Appointment
+		found := db.Where("rescheduled_from_id = ?", original.ID).Limit(1).Find(&replacement).RowsAffected > 0
+		if tt.status != http.StatusCreated {
+			if found || original.Status != tt.from {
+				t.Errorf("%s: refused reschedule left the original %s and a replacement %v", tt.name, original.Status, found)
+			}
+			continue
+		}
+		if original.Status != "rescheduled" || !found || replacement.Status != "pending" || !replacement.StartAt.Equal(*tt.body.StartAt) ||
+			replacement.PatientID != 10 || replacement.BookedBy != tt.as.id {
+			t.Errorf("%s: original %s, replacement %+v, want the original rescheduled and a pending replacement", tt.name, original.Status, replacement)
+		}
+		var history int64
+		db.Model(&models.AppointmentStatusChange{}).Where("appointment_id = ? AND to_status = ? AND reason = ?", original.ID, "rescheduled", tt.body.Reason).Count(&history)
+		if history != 1 {
+			t.Errorf("%s: got %d history rows for the original, want 1", tt.name, history)
+		}
+	}
+}
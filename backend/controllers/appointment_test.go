@@ -0,0 +1,284 @@
+package controllers
+
+import (
+	"dementicare-backend/models"
+	"dementicare-backend/scheduling"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// openClinic creates doctor 1 working 09:00-17:00 every day and returns
+// 10:00 clinic time a week from now
+func openClinic(t *testing.T, db *gorm.DB) time.Time {
+	t.Helper()
+	db.Create(&models.User{ID: 1, Email: "doctor@example.com", Password: "x", UserType: "doctor", Name: "Smith"})
+	for weekday := 0; weekday < 7; weekday++ {
+		db.Create(&models.DoctorSchedule{DoctorID: 1, Weekday: weekday, StartTime: "09:00", EndTime: "17:00"})
+	}
+	day := time.Now().In(scheduling.Location()).AddDate(0, 0, 7)
+	return time.Date(day.Year(), day.Month(), day.Day(), 10, 0, 0, 0, scheduling.Location())
+}
+
+func TestCreateAppointmentConflicts(t *testing.T) {
+	db := useTestDB(t)
+	start := openClinic(t, db)
+	booking := func(doctorID uint, at time.Time) map[string]interface{} {
+		return map[string]interface{}{"doctor_id": doctorID, "start_at": at, "type": "consultation"}
+	}
+	db.Create(&models.ScheduleException{DoctorID: 1, StartAt: start.Add(4 * time.Hour), EndAt: start.Add(6 * time.Hour), Reason: "leave"})
+	db.Create(&models.Appointment{PatientID: 12, DoctorID: 1, StartAt: start.Add(2 * time.Hour), EndAt: start.Add(150 * time.Minute), Status: "cancelled"})
+
+	tests := []struct {
+		name   string
+		as     caller
+		body   map[string]interface{}
+		status int
+	}{
+		{"free slot", caller{id: 10, userType: "patient"}, booking(1, start), http.StatusCreated},
+		{"same slot", caller{id: 11, userType: "patient"}, booking(1, start), http.StatusConflict},
+		{"overlapping slot", caller{id: 11, userType: "patient"}, booking(1, start.Add(15*time.Minute)), http.StatusConflict},
+		{"slot freed by a cancellation", caller{id: 11, userType: "patient"}, booking(1, start.Add(2*time.Hour)), http.StatusCreated},
+		{"outside working hours", caller{id: 11, userType: "patient"}, booking(1, start.Add(-3*time.Hour)), http.StatusConflict},
+		{"time off", caller{id: 11, userType: "patient"}, booking(1, start.Add(5*time.Hour)), http.StatusConflict},
+		{"in the past", caller{id: 11, userType: "patient"}, booking(1, start.AddDate(0, 0, -14)), http.StatusConflict},
+		{"not a doctor", caller{id: 11, userType: "patient"}, booking(10, start), http.StatusBadRequest},
+		{"no doctor", caller{id: 11, userType: "patient"}, booking(0, start), http.StatusBadRequest},
+		{"doctor booking", caller{id: 1, userType: "doctor"}, booking(1, start.Add(time.Hour)), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		w := serve(CreateAppointment, "POST", "/appointments", "/appointments", tt.as, tt.body)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+		}
+	}
+
+	var appointment models.Appointment
+	db.Where("patient_id = ?", 10).First(&appointment)
+	if appointment.Status != "pending" || !appointment.StartAt.Equal(start) || !appointment.EndAt.Equal(start.Add(30*time.Minute)) || appointment.BookedBy != 10 {
+		t.Errorf("booked %+v, want a pending 30 minute appointment from %s booked by the patient", appointment, start)
+	}
+	var history int64
+	db.Model(&models.AppointmentStatusChange{}).Where("appointment_id = ? AND to_status = ?", appointment.ID, "pending").Count(&history)
+	if history != 1 {
+		t.Errorf("got %d history rows for the booking, want 1", history)
+	}
+}
+
+func TestCreateAppointmentSuggestions(t *testing.T) {
+	db := useTestDB(t)
+	start := openClinic(t, db)
+	db.Create(&models.Appointment{PatientID: 10, DoctorID: 1, StartAt: start, EndAt: start.Add(30 * time.Minute), Status: "confirmed"})
+
+	body := map[string]interface{}{"doctor_id": 1, "start_at": start, "type": "consultation"}
+	w := serve(CreateAppointment, "POST", "/appointments", "/appointments", caller{id: 11, userType: "patient"}, body)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusConflict, w.Body)
+	}
+	var resp struct {
+		Suggestions []scheduling.Slot `json:"suggestions"`
+	}
+	decode(t, w, &resp)
+	want := []time.Time{start.Add(-time.Hour), start.Add(-30 * time.Minute), start.Add(30 * time.Minute)}
+	if len(resp.Suggestions) != len(want) {
+		t.Fatalf("got suggestions %+v, want %v", resp.Suggestions, want)
+	}
+	for i, slot := range resp.Suggestions {
+		if !slot.Start.Equal(want[i]) {
+			t.Errorf("suggestion %d starts at %s, want %s", i, slot.Start, want[i])
+		}
+	}
+}
+
+func TestCaregiverBooking(t *testing.T) {
+	db := useTestDB(t)
+	start := openClinic(t, db)
+	db.Create(&models.User{ID: 20, Email: "jane@example.com", Password: "x", UserType: "caregiver", Name: "Jane"})
+	patient := models.Patient{UserID: 10, Name: "Edith", CaregiverID: 20}
+	db.Create(&patient)
+	db.Create(&models.CareTeamMember{PatientID: patient.ID, UserID: 30})
+	booking := func(patientID uint, at time.Time) map[string]interface{} {
+		return map[string]interface{}{"doctor_id": 1, "patient_id": patientID, "start_at": at, "type": "consultation"}
+	}
+
+	tests := []struct {
+		name    string
+		as      caller
+		body    map[string]interface{}
+		status  int
+		patient uint
+	}{
+		{"caregiver without a patient", caller{id: 20, userType: "caregiver"}, booking(0, start), http.StatusBadRequest, 0},
+		{"caregiver for someone else", caller{id: 20, userType: "caregiver"}, booking(11, start), http.StatusForbidden, 0},
+		{"unlinked caregiver", caller{id: 21, userType: "caregiver"}, booking(10, start), http.StatusForbidden, 0},
+		{"caregiver", caller{id: 20, userType: "caregiver"}, booking(10, start), http.StatusCreated, 10},
+		{"care-team member", caller{id: 30, userType: "caregiver"}, booking(10, start.Add(time.Hour)), http.StatusCreated, 10},
+		{"patient naming another patient", caller{id: 11, userType: "patient"}, booking(10, start.Add(2*time.Hour)), http.StatusCreated, 11},
+	}
+	for _, tt := range tests {
+		w := serve(CreateAppointment, "POST", "/appointments", "/appointments", tt.as, tt.body)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+			continue
+		}
+		if tt.status != http.StatusCreated {
+			continue
+		}
+		var appointment models.Appointment
+		decode(t, w, &appointment)
+		if appointment.PatientID != tt.patient || appointment.BookedBy != tt.as.id {
+			t.Errorf("%s: booked for %d by %d, want for %d by %d", tt.name, appointment.PatientID, appointment.BookedBy, tt.patient, tt.as.id)
+		}
+	}
+
+	// The patient hears about bookings made for them, not their own
+	var notices []models.Notification
+	db.Where("kind = ?", "appointment_update").Order("id").Find(&notices)
+	if len(notices) != 2 || notices[0].RecipientID != 10 || !strings.HasPrefix(notices[0].Body, "Jane booked an appointment for you") {
+		t.Fatalf("notifications %+v, want two for patient 10, the first from Jane", notices)
+	}
+
+	// The caregiver may cancel on the patient's behalf, which also notifies them
+	var appointment models.Appointment
+	db.Where("booked_by = ?", 20).First(&appointment)
+	target := "/appointments/" + strconv.Itoa(int(appointment.ID)) + "/cancel"
+	if w := serve(CancelAppointment, "POST", "/appointments/:id/cancel", target, caller{id: 21, userType: "caregiver"}, AppointmentReasonRequest{Reason: "Unwell"}); w.Code != http.StatusForbidden {
+		t.Errorf("unlinked caregiver cancel status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := serve(CancelAppointment, "POST", "/appointments/:id/cancel", target, caller{id: 20, userType: "caregiver"}, AppointmentReasonRequest{Reason: "Unwell"}); w.Code != http.StatusOK {
+		t.Fatalf("caregiver cancel status = %d: %s", w.Code, w.Body)
+	}
+	var cancelNotices int64
+	db.Model(&models.Notification{}).Where("recipient_id = ? AND body LIKE ?", 10, "Jane cancelled your appointment%").Count(&cancelNotices)
+	if cancelNotices != 1 {
+		t.Errorf("got %d cancellation notices for the patient, want 1", cancelNotices)
+	}
+}
+
+func TestGetAppointments(t *testing.T) {
+	db := useTestDB(t)
+	db.Create(&[]models.User{
+		{ID: 1, Email: "smith@example.com", Password: "x", UserType: "doctor", Name: "Smith"},
+		{ID: 2, Email: "jones@example.com", Password: "x", UserType: "doctor", Name: "Jones"},
+		{ID: 10, Email: "edith@example.com", Password: "x", UserType: "patient", Name: "Edith"},
+		{ID: 11, Email: "frank@example.com", Password: "x", UserType: "patient", Name: "Frank"},
+	})
+	at := func(day int) time.Time { return time.Date(2026, 3, day, 10, 0, 0, 0, scheduling.Location()) }
+	appointments := []models.Appointment{
+		{PatientID: 10, DoctorID: 1, StartAt: at(2), Type: "consultation", Modality: "in_person", Status: "confirmed"},
+		{PatientID: 10, DoctorID: 2, StartAt: at(3), Type: "follow-up", Modality: "virtual", Status: "pending"},
+		{PatientID: 11, DoctorID: 1, StartAt: at(4), Type: "consultation", Modality: "in_person", Status: "cancelled"},
+		{PatientID: 11, DoctorID: 1, StartAt: at(5), Type: "follow-up", Modality: "in_person", Status: "completed"},
+	}
+	for i := range appointments {
+		appointments[i].EndAt = appointments[i].StartAt.Add(30 * time.Minute)
+		db.Create(&appointments[i])
+	}
+	asAdmin := caller{id: 99, userType: "admin"}
+
+	tests := []struct {
+		as    caller
+		query string
+		want  []int // indexes into appointments, in order
+	}{
+		{caller{id: 1, userType: "doctor"}, "", []int{3, 2, 0}},
+		{caller{id: 10, userType: "patient"}, "?sort=start_at", []int{0, 1}},
+		{caller{id: 10, userType: "patient"}, "?patient_id=11", []int{}},
+		{asAdmin, "?sort=start_at", []int{0, 1, 2, 3}},
+		{asAdmin, "?status=confirmed,%20pending&sort=start_at", []int{0, 1}},
+		{asAdmin, "?type=follow-up&sort=-start_at", []int{3, 1}},
+		{asAdmin, "?modality=virtual", []int{1}},
+		{asAdmin, "?doctor_id=1&patient_id=11&sort=start_at", []int{2, 3}},
+		{asAdmin, "?from=2026-03-03&to=2026-03-04&sort=start_at", []int{1, 2}},
+		{asAdmin, "?sort=start_at&per_page=3&page=2", []int{3}},
+	}
+	for _, tt := range tests {
+		w := serve(GetAppointments, "GET", "/appointments", "/appointments"+tt.query, tt.as, nil)
+		var resp struct {
+			Appointments []AppointmentResponse `json:"appointments"`
+			Pagination   struct {
+				Total      int `json:"total"`
+				TotalPages int `json:"total_pages"`
+			} `json:"pagination"`
+		}
+		decode(t, w, &resp)
+		got := make([]uint, len(resp.Appointments))
+		for i, a := range resp.Appointments {
+			got[i] = a.ID
+		}
+		want := make([]uint, len(tt.want))
+		for i, index := range tt.want {
+			want[i] = appointments[index].ID
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s %s: got appointments %v, want %v", tt.as.userType, tt.query, got, want)
+		}
+		if tt.query == "?sort=start_at&per_page=3&page=2" && (resp.Pagination.Total != 4 || resp.Pagination.TotalPages != 2) {
+			t.Errorf("pagination = %+v, want 4 appointments on 2 pages", resp.Pagination)
+		}
+	}
+
+	w := serve(GetAppointments, "GET", "/appointments", "/appointments?modality=virtual", asAdmin, nil)
+	var resp struct {
+		Appointments []AppointmentResponse `json:"appointments"`
+	}
+	decode(t, w, &resp)
+	if len(resp.Appointments) != 1 || resp.Appointments[0].DoctorName != "Jones" || resp.Appointments[0].PatientName != "Edith" {
+		t.Errorf("got %+v, want the names of the doctor and patient", resp.Appointments)
+	}
+
+	for _, query := range []string{"?from=yesterday", "?to=03/04/2026", "?sort=name", "?page=0", "?per_page=201", "?doctor_id=smith"} {
+		if w := serve(GetAppointments, "GET", "/appointments", "/appointments"+query, asAdmin, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestAppointmentLocalTimes(t *testing.T) {
+	db := useTestDB(t)
+	if _, err := time.LoadLocation("America/New_York"); err != nil {
+		t.Skip("America/New_York time zone data not available")
+	}
+	db.Create(&[]models.User{
+		{ID: 1, Email: "doctor@example.com", Password: "x", UserType: "doctor", Name: "Smith"},
+		{ID: 10, Email: "edith@example.com", Password: "x", UserType: "patient", Name: "Edith", TimeZone: "America/New_York"},
+	})
+	// 22:00 on 1 July in New York
+	late := time.Date(2026, 7, 2, 2, 0, 0, 0, time.UTC)
+	db.Create(&[]models.Appointment{
+		{PatientID: 10, DoctorID: 1, StartAt: late, EndAt: late.Add(30 * time.Minute), Status: "confirmed"},
+		{PatientID: 10, DoctorID: 1, StartAt: late.Add(24 * time.Hour), EndAt: late.Add(24*time.Hour + 30*time.Minute), Status: "confirmed"},
+	})
+
+	tests := []struct {
+		query      string
+		localStart []string
+	}{
+		{"?sort=start_at", []string{"2026-07-01T22:00:00-04:00", "2026-07-02T22:00:00-04:00"}},
+		// Plain dates are days in the user's time zone
+		{"?from=2026-07-02", []string{"2026-07-02T22:00:00-04:00"}},
+		{"?to=2026-07-01", []string{"2026-07-01T22:00:00-04:00"}},
+		{"?from=2026-07-02T00:00:00Z&to=2026-07-02T23:59:59Z", []string{"2026-07-01T22:00:00-04:00"}},
+	}
+	for _, tt := range tests {
+		w := serve(GetAppointments, "GET", "/appointments", "/appointments"+tt.query, caller{id: 10, userType: "patient"}, nil)
+		var resp struct {
+			Appointments []AppointmentResponse `json:"appointments"`
+		}
+		decode(t, w, &resp)
+		if len(resp.Appointments) != len(tt.localStart) {
+			t.Errorf("%s: got %d appointments, want %d", tt.query, len(resp.Appointments), len(tt.localStart))
+			continue
+		}
+		for i, a := range resp.Appointments {
+			if a.LocalStart != tt.localStart[i] {
+				t.Errorf("%s: appointment %d local start %s, want %s", tt.query, i, a.LocalStart, tt.localStart[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"dementicare-backend/config"
+	"dementicare-backend/models"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultMaxAttachmentSize = 10 << 20 // 10 MB
+
+// Content types are sniffed from the file itself, not taken from the client
+var allowedAttachmentTypes = map[string]bool{
+	"application/pdf": true,
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/webp":      true,
+}
+
+var attachmentCategories = map[string]bool{
+	"scan":             true,
+	"discharge_letter": true,
+	"mri_report":       true,
+	"photo":            true,
+	"other":            true,
+}
+
+func maxAttachmentSize() int64 {
+	if v, err := strconv.ParseInt(os.Getenv("MAX_ATTACHMENT_SIZE"), 10, 64); err == nil && v > 0 {
+		return v
+	}
+	return defaultMaxAttachmentSize
+}
+
+func GetAttachments(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var attachments []models.Attachment
+	query := config.DB.Where("patient_id = ?", patient.ID)
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+
+	if err := query.Order("created_at desc").Find(&attachments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"attachments": attachments})
+}
+
+func UploadAttachment(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	category := c.DefaultPostForm("category", "other")
+	if !attachmentCategories[category] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment category"})
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required"})
+		return
+	}
+
+	limit := maxAttachmentSize()
+	if header.Size > limit {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File exceeds the %d byte limit", limit)})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	contentType := http.DetectContentType(sniff[:n])
+	if !allowedAttachmentTypes[contentType] {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Unsupported file type: " + contentType})
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+
+	key, err := newStorageKey(patient.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return
+	}
+
+	hash := sha256.New()
+	size, err := config.Storage.Save(key, io.TeeReader(io.LimitReader(file, limit), hash))
+	if err != nil {
+		log.Printf("Error storing attachment for patient %d: %v", patient.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return
+	}
+
+	attachment := models.Attachment{
+		PatientID:   patient.ID,
+		UploadedBy:  c.GetUint("user_id"),
+		Category:    category,
+		FileName:    filepath.Base(header.Filename),
+		ContentType: contentType,
+		Size:        size,
+		Checksum:    hex.EncodeToString(hash.Sum(nil)),
+		StorageKey:  key,
+	}
+
+	if err := config.DB.Create(&attachment).Error; err != nil {
+		config.Storage.Delete(key)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save attachment"})
+		return
+	}
+
+	log.Printf("Attachment uploaded - ID: %d, Patient: %d, Size: %d", attachment.ID, attachment.PatientID, attachment.Size)
+	c.JSON(http.StatusCreated, attachment)
+}
+
+func DownloadAttachment(c *gin.Context) {
+	attachment, ok := findAccessibleAttachment(c)
+	if !ok {
+		return
+	}
+
+	reader, err := config.Storage.Open(attachment.StorageKey)
+	if err != nil {
+		log.Printf("Error opening attachment %d: %v", attachment.ID, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment content not found"})
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, reader, map[string]string{
+		"Content-Disposition": fmt.Sprintf("attachment; filename=%q", attachment.FileName),
+		"ETag":                `"` + attachment.Checksum + `"`,
+	})
+}
+
+func DeleteAttachment(c *gin.Context) {
+	attachment, ok := findAccessibleAttachment(c)
+	if !ok {
+		return
+	}
+
+	if err := config.DB.Delete(&attachment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attachment"})
+		return
+	}
+
+	if err := config.Storage.Delete(attachment.StorageKey); err != nil {
+		log.Printf("Error removing stored file for attachment %d: %v", attachment.ID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Attachment deleted successfully"})
+}
+
+// findAccessibleAttachment loads the attachment named by the :id parameter and
+// checks access through the patient it belongs to.
+func findAccessibleAttachment(c *gin.Context) (models.Attachment, bool) {
+	var attachment models.Attachment
+	if err := config.DB.First(&attachment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return attachment, false
+	}
+
+	if _, ok := findAccessiblePatient(c, attachment.PatientID); !ok {
+		return attachment, false
+	}
+
+	return attachment, true
+}
+
+func newStorageKey(patientID uint) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("patients/%d/%s", patientID, hex.EncodeToString(buf)), nil
+}
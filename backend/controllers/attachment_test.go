@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"dementicare-backend/config"
+	"dementicare-backend/models"
+	"dementicare-backend/storage"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestAttachments(t *testing.T) {
+	db := useTestDB(t)
+	local, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := config.Storage
+	config.Storage = local
+	t.Cleanup(func() { config.Storage = previous })
+	t.Setenv("MAX_ATTACHMENT_SIZE", "1024")
+
+	patient := models.Patient{UserID: 10, Name: "Edith", CaregiverID: 20}
+	db.Create(&patient)
+	target := "/patients/" + strconv.Itoa(int(patient.ID)) + "/attachments"
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 100)...)
+	caregiver := caller{id: 20, userType: "caregiver"}
+
+	tests := []struct {
+		name   string
+		as     caller
+		body   upload
+		status int
+	}{
+		{"photo", caregiver, upload{field: "file", filename: "edith.png", content: png, values: map[string]string{"category": "photo"}}, http.StatusCreated},
+		{"unknown category", caregiver, upload{field: "file", filename: "edith.png", content: png, values: map[string]string{"category": "selfie"}}, http.StatusBadRequest},
+		{"type sniffed, not taken from the name", caregiver, upload{field: "file", filename: "letter.pdf", content: []byte("plain text")}, http.StatusUnsupportedMediaType},
+		{"too large", caregiver, upload{field: "file", filename: "scan.png", content: append(png, make([]byte, 1024)...)}, http.StatusRequestEntityTooLarge},
+		{"unrelated caregiver", caller{id: 21, userType: "caregiver"}, upload{field: "file", filename: "edith.png", content: png}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if w := serve(UploadAttachment, "POST", "/patients/:id/attachments", target, tt.as, tt.body); w.Code != tt.status {
+			t.Errorf("%s: upload status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+		}
+	}
+
+	var attachments []models.Attachment
+	db.Find(&attachments)
+	if len(attachments) != 1 {
+		t.Fatalf("got %d attachments, want 1", len(attachments))
+	}
+	attachment := attachments[0]
+	sum := sha256.Sum256(png)
+	if attachment.ContentType != "image/png" || attachment.Size != int64(len(png)) || attachment.Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("attachment = %s, %d bytes, checksum %s; want image/png, %d bytes, %x", attachment.ContentType, attachment.Size, attachment.Checksum, len(png), sum)
+	}
+
+	download := "/attachments/" + strconv.Itoa(int(attachment.ID))
+	w := serve(DownloadAttachment, "GET", "/attachments/:id", download, caller{id: 10, userType: "patient"}, nil)
+	if w.Code != http.StatusOK || w.Body.String() != string(png) {
+		t.Errorf("patient download: status = %d, %d bytes, want %d and the uploaded file", w.Code, w.Body.Len(), http.StatusOK)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename="edith.png"` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	if w := serve(DownloadAttachment, "GET", "/attachments/:id", download, caller{id: 11, userType: "patient"}, nil); w.Code != http.StatusForbidden {
+		t.Errorf("other patient's download: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	if w := serve(DeleteAttachment, "DELETE", "/attachments/:id", download, caregiver, nil); w.Code != http.StatusOK {
+		t.Errorf("delete: status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if _, err := local.Open(attachment.StorageKey); err != storage.ErrNotFound {
+		t.Errorf("stored file after delete: error = %v, want ErrNotFound", err)
+	}
+	if w := serve(DownloadAttachment, "GET", "/attachments/:id", download, caregiver, nil); w.Code != http.StatusNotFound {
+		t.Errorf("download after delete: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
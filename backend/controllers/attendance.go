@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"dementicare-backend/attendance"
+	"dementicare-backend/config"
+	"dementicare-backend/models"
+	"dementicare-backend/notify"
+	"dementicare-backend/scheduling"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// How far back doctor statistics reach when no range is given
+const defaultAttendanceRange = 90 * 24 * time.Hour
+
+// GetPatientAttendance returns the outcomes of the patient's past
+// appointments and whether the no-show policy applies to them
+func GetPatientAttendance(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	policy := attendance.CurrentPolicy()
+	history := []models.Appointment{}
+	var recentNoShows int64
+	if patient.UserID != 0 {
+		if err := config.DB.Where("patient_id = ? AND status IN ?", patient.UserID, attendance.OutcomeStatuses).
+			Order("start_at desc").Find(&history).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attendance"})
+			return
+		}
+		count, err := policy.RecentNoShows(config.DB, patient.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attendance"})
+			return
+		}
+		recentNoShows = count
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"patient_id": patient.ID,
+		"summary":    attendance.Summarize(history),
+		"policy": gin.H{
+			"threshold":                       policy.Threshold,
+			"window_days":                     int(policy.Window / (24 * time.Hour)),
+			"recent_no_shows":                 recentNoShows,
+			"requires_caregiver_confirmation": policy.Threshold > 0 && recentNoShows >= int64(policy.Threshold),
+		},
+		"history": history,
+	})
+}
+
+// GetDoctorAttendance returns the doctor's no-show and late cancellation
+// rates by weekday, hour and appointment type, for the doctor and admins.
+// from and to (RFC3339 or YYYY-MM-DD) default to the last 90 days.
+func GetDoctorAttendance(c *gin.Context) {
+	doctor, ok := findDoctor(c)
+	if !ok {
+		return
+	}
+	if c.GetString("user_type") != "admin" && c.GetUint("user_id") != doctor.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the doctor or an admin can view these statistics"})
+		return
+	}
+
+	loc := scheduling.Location()
+	to := time.Now()
+	from := to.Add(-defaultAttendanceRange)
+	if value := c.Query("from"); value != "" {
+		parsed, err := parseDateParamIn(value, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be RFC3339 or YYYY-MM-DD"})
+			return
+		}
+		from = parsed
+	}
+	if value := c.Query("to"); value != "" {
+		parsed, err := parseDateParamIn(value, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be RFC3339 or YYYY-MM-DD"})
+			return
+		}
+		if len(value) == len("2006-01-02") {
+			parsed = parsed.AddDate(0, 0, 1)
+		}
+		to = parsed
+	}
+	if !to.After(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be after from"})
+		return
+	}
+
+	var appointments []models.Appointment
+	if err := config.DB.Where("doctor_id = ? AND status IN ? AND start_at >= ? AND start_at < ?",
+		doctor.ID, attendance.OutcomeStatuses, from, to).Find(&appointments).Error; err != nil {
+		log.Printf("Error fetching attendance of doctor %d: %v", doctor.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute attendance statistics"})
+		return
+	}
+
+	breakdown := attendance.Analyze(appointments)
+	c.JSON(http.StatusOK, gin.H{
+		"doctor_id":  doctor.ID,
+		"from":       from,
+		"to":         to,
+		"time_zone":  loc.String(),
+		"total":      breakdown.Total,
+		"by_weekday": breakdown.ByWeekday,
+		"by_hour":    breakdown.ByHour,
+		"by_type":    breakdown.ByType,
+	})
+}
+
+// CaregiverConfirmAppointment lets a caregiver or care-team member confirm
+// a booking made under the no-show policy, after which the doctor can
+// confirm it as usual
+func CaregiverConfirmAppointment(c *gin.Context) {
+	appointment, _, ok := findAppointmentForAction(c, "caregiver")
+	if !ok {
+		return
+	}
+	if !appointment.NeedsCaregiver {
+		c.JSON(http.StatusConflict, gin.H{"error": "This appointment does not need a caregiver's confirmation"})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	now := time.Now()
+	result := config.DB.Model(&models.Appointment{}).
+		Where("id = ? AND status = ? AND caregiver_confirm_by IS NULL", appointment.ID, "pending").
+		Updates(map[string]interface{}{"caregiver_confirm_by": userID, "caregiver_confirm_at": now})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm appointment"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only pending appointments that no caregiver has confirmed yet can be confirmed"})
+		return
+	}
+	appointment.CaregiverConfirmBy = &userID
+	appointment.CaregiverConfirmAt = &now
+
+	log.Printf("Appointment confirmed by caregiver - ID: %d, Caregiver: %d", appointment.ID, userID)
+	c.JSON(http.StatusOK, appointment)
+}
+
+// applyNoShowPolicy flags a new booking for caregiver confirmation when the
+// patient has missed too many recent appointments. A booking made by one of
+// the patient's caregivers counts as confirmed by them. Patients without a
+// caregiver are not held back.
+func applyNoShowPolicy(tx *gorm.DB, appointment *models.Appointment, actorID uint) error {
+	applies, err := attendance.CurrentPolicy().Applies(tx, appointment.PatientID)
+	if err != nil || !applies {
+		return err
+	}
+
+	recipients, err := notify.PatientRecipients(tx, appointment.PatientID)
+	if err != nil {
+		return err
+	}
+	if len(recipients) < 2 {
+		log.Printf("No-show policy applies to patient %d, but they have no caregiver to confirm", appointment.PatientID)
+		return nil
+	}
+
+	appointment.NeedsCaregiver = true
+	for _, id := range recipients[1:] {
+		if id == actorID {
+			now := time.Now()
+			appointment.CaregiverConfirmBy = &actorID
+			appointment.CaregiverConfirmAt = &now
+			break
+		}
+	}
+	return nil
+}
+
+// requestCaregiverConfirmation asks the patient's caregivers and care team
+// to confirm bookings made under the no-show policy
+func requestCaregiverConfirmation(patientID uint, dedupeKey, what string) {
+	recipients, err := notify.PatientRecipients(config.DB, patientID)
+	if err != nil {
+		log.Printf("Error finding caregivers of patient %d: %v", patientID, err)
+		return
+	}
+
+	var patient models.User
+	config.DB.Select("name").First(&patient, patientID)
+
+	for _, recipientID := range recipients[1:] {
+		err := notify.Enqueue(config.DB, models.Notification{
+			RecipientID: recipientID,
+			Kind:        "caregiver_confirmation",
+			Subject:     "Please confirm " + patient.Name + "'s appointment",
+			Body: fmt.Sprintf("%s has %s that needs your confirmation, as they have missed several recent appointments.",
+				patient.Name, what),
+			DedupeKey: fmt.Sprintf("caregiver-confirmation:%s:%d", dedupeKey, recipientID),
+		})
+		if err != nil {
+			log.Printf("Error queueing confirmation request for user %d: %v", recipientID, err)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package controllers
 import (
 	"dementicare-backend/config"
 	"dementicare-backend/models"
+	"dementicare-backend/scheduling"
 	"log"
 	"net/http"
 	"os"
@@ -20,6 +21,13 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	if req.TimeZone != "" {
+		if _, err := time.LoadLocation(req.TimeZone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "time_zone must be an IANA time zone such as Europe/London"})
+			return
+		}
+	}
+
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -34,6 +42,7 @@ func Register(c *gin.Context) {
 		UserType: req.UserType,
 		Name:     req.Name,
 		Phone:    req.Phone,
+		TimeZone: req.TimeZone,
 	}
 
 	if err := config.DB.Create(&user).Error; err != nil {
@@ -136,6 +145,41 @@ func ChangePassword(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
 }
 
+// UpdateTimeZone sets the IANA time zone the user's times are shown in
+func UpdateTimeZone(c *gin.Context) {
+	var req struct {
+		TimeZone string `json:"time_zone" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := time.LoadLocation(req.TimeZone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "time_zone must be an IANA time zone such as Europe/London"})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	if err := config.DB.Model(&models.User{}).Where("id = ?", userID).Update("time_zone", req.TimeZone).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update time zone"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Time zone updated", "time_zone": req.TimeZone})
+}
+
+// userLocation returns the user's own time zone, or the clinic's when they
+// have not set one
+func userLocation(userID uint) *time.Location {
+	var user models.User
+	if config.DB.Select("time_zone").Limit(1).Find(&user, userID).RowsAffected > 0 && user.TimeZone != "" {
+		if loc, err := time.LoadLocation(user.TimeZone); err == nil {
+			return loc
+		}
+	}
+	return scheduling.Location()
+}
+
 func generateToken(user models.User) string {
 	claims := jwt.MapClaims{
 		"user_id":   user.ID,
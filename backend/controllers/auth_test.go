@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"dementicare-backend/models"
+	"net/http"
+	"testing"
+)
+
+func TestUpdateTimeZone(t *testing.T) {
+	db := useTestDB(t)
+	db.Create(&models.User{ID: 10, Email: "edith@example.com", Password: "x", UserType: "patient", Name: "Edith"})
+	asPatient := caller{id: 10, userType: "patient"}
+
+	tests := []struct {
+		name   string
+		body   map[string]string
+		status int
+		want   string
+	}{
+		{"missing", map[string]string{}, http.StatusBadRequest, ""},
+		{"not an IANA name", map[string]string{"time_zone": "Mars/Olympus"}, http.StatusBadRequest, ""},
+		{"valid", map[string]string{"time_zone": "America/New_York"}, http.StatusOK, "America/New_York"},
+	}
+	for _, tt := range tests {
+		w := serve(UpdateTimeZone, "PUT", "/auth/time-zone", "/auth/time-zone", asPatient, tt.body)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+			continue
+		}
+		var user models.User
+		db.First(&user, 10)
+		if tt.status == http.StatusOK && user.TimeZone != tt.want {
+			t.Errorf("%s: time zone %q, want %q", tt.name, user.TimeZone, tt.want)
+		}
+	}
+
+	if got := userLocation(10).String(); got != "America/New_York" {
+		t.Errorf("userLocation() = %s, want the user's own zone", got)
+	}
+}
@@ -0,0 +1,241 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"dementicare-backend/config"
+	"dementicare-backend/ical"
+	"dementicare-backend/models"
+	"dementicare-backend/scheduling"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// How far back the subscription feed reaches; later appointments are all
+// included
+const calendarFeedHistory = 90 * 24 * time.Hour
+
+// GetCalendarFeed returns the caller's secret subscription URL, creating it
+// on first use
+func GetCalendarFeed(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var feed models.CalendarFeed
+	result := config.DB.Where("user_id = ?", userID).Limit(1).Find(&feed)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch calendar feed"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		token, err := newCalendarToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create calendar feed"})
+			return
+		}
+		feed = models.CalendarFeed{UserID: userID, Token: token}
+		if err := config.DB.Create(&feed).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create calendar feed"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"url": calendarFeedURL(c, feed.Token)})
+}
+
+// RotateCalendarFeed replaces the caller's token, so the old URL stops working
+func RotateCalendarFeed(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	token, err := newCalendarToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate calendar feed"})
+		return
+	}
+
+	feed := models.CalendarFeed{UserID: userID}
+	if err := config.DB.Where("user_id = ?", userID).Assign(models.CalendarFeed{Token: token}).FirstOrCreate(&feed).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate calendar feed"})
+		return
+	}
+
+	log.Printf("Calendar feed rotated - User: %d", userID)
+	c.JSON(http.StatusOK, gin.H{"url": calendarFeedURL(c, feed.Token)})
+}
+
+// ServeCalendarFeed is the public subscription URL. The token in the path is
+// the only credential, since calendar apps cannot send a bearer token.
+func ServeCalendarFeed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+
+	var feed models.CalendarFeed
+	if token == "" || config.DB.Where("token = ?", token).First(&feed).Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, feed.UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+		return
+	}
+
+	// Deleted appointments stay in the feed as cancelled, so subscribed
+	// calendars drop them
+	query, ok := calendarScope(config.DB.Model(&models.Appointment{}).Unscoped(), user)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+		return
+	}
+
+	var appointments []models.Appointment
+	if err := query.Where("start_at >= ?", time.Now().Add(-calendarFeedHistory)).Order("start_at asc").Find(&appointments).Error; err != nil {
+		log.Printf("Error building calendar feed for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build calendar"})
+		return
+	}
+
+	writeCalendar(c, "DementiCare - "+user.Name, appointments, "")
+}
+
+// GetAppointmentICS downloads a single appointment as an .ics file
+func GetAppointmentICS(c *gin.Context) {
+	appointment, _, ok := findAppointmentForAction(c, "doctor", "patient", "caregiver", "admin")
+	if !ok {
+		return
+	}
+
+	writeCalendar(c, "DementiCare appointment", []models.Appointment{appointment}, fmt.Sprintf("appointment-%d.ics", appointment.ID))
+}
+
+// calendarScope limits appointments to the ones the user takes part in.
+// Caregivers get the appointments of the patients they look after.
+func calendarScope(query *gorm.DB, user models.User) (*gorm.DB, bool) {
+	switch user.UserType {
+	case "doctor":
+		return query.Where("appointments.doctor_id = ?", user.ID), true
+	case "patient":
+		return query.Where("appointments.patient_id = ?", user.ID), true
+	case "caregiver":
+		return query.Where("appointments.patient_id IN (?)", caredForPatientUsers(user.ID)), true
+	case "admin":
+		return query, true
+	}
+	return nil, false
+}
+
+func writeCalendar(c *gin.Context, name string, appointments []models.Appointment, filename string) {
+	cal := ical.Calendar{Name: name, Location: scheduling.Location()}
+	names := calendarNames(appointments)
+	for _, appointment := range appointments {
+		cal.Events = append(cal.Events, appointmentEvent(appointment, names))
+	}
+
+	c.Header("Content-Type", "text/calendar; charset=utf-8")
+	if filename != "" {
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	}
+	c.Status(http.StatusOK)
+	if err := cal.Write(c.Writer); err != nil {
+		log.Printf("Error writing calendar: %v", err)
+	}
+}
+
+// appointmentEvent maps an appointment to a VEVENT, leaving out its notes.
+// Cancelled and rescheduled appointments stay in the feed as CANCELLED so
+// subscribed calendars remove them; the replacement of a rescheduled
+// appointment is a new event.
+func appointmentEvent(a models.Appointment, names map[uint]string) ical.Event {
+	window := scheduling.AppointmentWindow(a)
+
+	status := ical.StatusConfirmed
+	switch a.Status {
+	case "pending":
+		status = ical.StatusTentative
+	case "cancelled", "rescheduled":
+		status = ical.StatusCancelled
+	}
+	sequence, modified := a.Sequence, a.UpdatedAt
+	if a.DeletedAt.Valid {
+		// Deleting does not bump the sequence, so the event does here
+		status, sequence, modified = ical.StatusCancelled, a.Sequence+1, a.DeletedAt.Time
+	}
+
+	summary := "Appointment"
+	if a.Type != "" {
+		summary = a.Type
+	}
+	summary += " - Dr. " + names[a.DoctorID] + " with " + names[a.PatientID]
+
+	// Notes can hold clinical detail, and the feed URL is only as private as
+	// the calendar app it is pasted into
+	description := "Status: " + a.Status
+
+	return ical.Event{
+		UID:          fmt.Sprintf("appointment-%d@%s", a.ID, calendarUIDDomain()),
+		Sequence:     sequence,
+		Start:        window.Start,
+		End:          window.End,
+		Summary:      summary,
+		Description:  description,
+		Status:       status,
+		Created:      a.CreatedAt,
+		LastModified: modified,
+	}
+}
+
+// calendarNames loads the names of everyone taking part in the appointments
+func calendarNames(appointments []models.Appointment) map[uint]string {
+	ids := make([]uint, 0, len(appointments)*2)
+	for _, a := range appointments {
+		ids = append(ids, a.DoctorID, a.PatientID)
+	}
+
+	var users []models.User
+	config.DB.Select("id, name").Where("id IN ?", ids).Find(&users)
+
+	names := make(map[uint]string, len(users))
+	for _, u := range users {
+		names[u.ID] = u.Name
+	}
+	return names
+}
+
+// calendarUIDDomain makes event UIDs globally unique (CALENDAR_UID_DOMAIN)
+func calendarUIDDomain() string {
+	if domain := os.Getenv("CALENDAR_UID_DOMAIN"); domain != "" {
+		return domain
+	}
+	return "dementicare"
+}
+
+func calendarFeedURL(c *gin.Context, token string) string {
+	return publicBaseURL(c) + "/calendar/" + token + ".ics"
+}
+
+// publicBaseURL is the server's external address, PUBLIC_BASE_URL when the
+// server sits behind a proxy
+func publicBaseURL(c *gin.Context) string {
+	if base := strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/"); base != "" {
+		return base
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+func newCalendarToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"dementicare-backend/models"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeCalendarFeed(t *testing.T) {
+	db := useTestDB(t)
+	db.Create(&[]models.User{
+		{ID: 1, Email: "doctor@example.com", Password: "x", UserType: "doctor", Name: "Smith"},
+		{ID: 10, Email: "edith@example.com", Password: "x", UserType: "patient", Name: "Edith"},
+		{ID: 11, Email: "arthur@example.com", Password: "x", UserType: "patient", Name: "Arthur"},
+	})
+	db.Create(&models.CalendarFeed{UserID: 10, Token: "edith-token"})
+	start := time.Now().Add(48 * time.Hour).UTC()
+	db.Create(&[]models.Appointment{
+		{PatientID: 10, DoctorID: 1, StartAt: start, EndAt: start.Add(30 * time.Minute), Type: "follow-up", Status: "confirmed", Notes: "Discuss MRI findings"},
+		{PatientID: 11, DoctorID: 1, StartAt: start, EndAt: start.Add(30 * time.Minute), Type: "consultation", Status: "confirmed"},
+	})
+
+	w := serve(ServeCalendarFeed, "GET", "/calendar/:token", "/calendar/edith-token.ics", caller{}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	out := w.Body.String()
+	for _, want := range []string{"SUMMARY:follow-up - Dr. Smith with Edith", `DESCRIPTION:Status: confirmed` + "\r\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("feed is missing %q:\n%s", want, out)
+		}
+	}
+	for _, notWant := range []string{"MRI", "Arthur"} {
+		if strings.Contains(out, notWant) {
+			t.Errorf("feed should not contain %q:\n%s", notWant, out)
+		}
+	}
+
+	if w := serve(ServeCalendarFeed, "GET", "/calendar/:token", "/calendar/guess.ics", caller{}, nil); w.Code != http.StatusNotFound {
+		t.Errorf("unknown token: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,348 @@
+package controllers
+
+import (
+	"dementicare-backend/config"
+	"dementicare-backend/models"
+	"dementicare-backend/scheduling"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultCareLogSummaryDays = 14
+	maxCareLogSummaryDays     = 90
+)
+
+var careLogCategories = map[string]bool{
+	"meal":     true,
+	"mood":     true,
+	"sleep":    true,
+	"bathing":  true,
+	"incident": true,
+	"other":    true,
+}
+
+// Fraction of the meal eaten for each portion value
+var mealPortions = map[string]float64{
+	"none": 0,
+	"some": 0.25,
+	"half": 0.5,
+	"most": 0.75,
+	"all":  1,
+}
+
+type CareLogDaySummary struct {
+	Date               time.Time `json:"date"`
+	Entries            int       `json:"entries"`
+	AverageMood        *float64  `json:"average_mood"`
+	AverageSleep       *float64  `json:"average_sleep_quality"`
+	SleepHours         *float64  `json:"sleep_hours"`
+	Meals              int       `json:"meals"`
+	AverageMealPortion *float64  `json:"average_meal_portion"`
+	Baths              int       `json:"baths"`
+	Incidents          int       `json:"incidents"`
+}
+
+func GetCareLog(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	query := config.DB.Where("patient_id = ?", patient.ID)
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+	if from, err := parseDateParam(c.Query("from")); err == nil {
+		query = query.Where("logged_at >= ?", from)
+	}
+	if to, err := parseDateParam(c.Query("to")); err == nil {
+		// Date-only bounds include the whole day
+		if len(c.Query("to")) == len("2006-01-02") {
+			to = to.AddDate(0, 0, 1)
+		}
+		query = query.Where("logged_at < ?", to)
+	}
+	if q := c.Query("q"); q != "" {
+		query = query.Where("notes LIKE ?", "%"+q+"%")
+	}
+
+	var entries []models.CareLogEntry
+	if err := query.Order("logged_at desc").Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch care log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+func CreateCareLogEntry(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var entry models.CareLogEntry
+	if err := c.ShouldBindJSON(&entry); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry.ID = 0
+	entry.PatientID = patient.ID
+	entry.AuthorID = c.GetUint("user_id")
+	if entry.LoggedAt.IsZero() {
+		entry.LoggedAt = time.Now()
+	}
+	if msg := validateCareLogEntry(entry); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := config.DB.Create(&entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save care log entry"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+func UpdateCareLogEntry(c *gin.Context) {
+	var entry models.CareLogEntry
+	if err := config.DB.First(&entry, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Care log entry not found"})
+		return
+	}
+
+	if _, ok := findAccessiblePatient(c, entry.PatientID); !ok {
+		return
+	}
+	if entry.AuthorID != c.GetUint("user_id") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author can edit a care log entry"})
+		return
+	}
+
+	id, patientID, authorID := entry.ID, entry.PatientID, entry.AuthorID
+	if err := c.ShouldBindJSON(&entry); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry.ID, entry.PatientID, entry.AuthorID = id, patientID, authorID
+
+	if msg := validateCareLogEntry(entry); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := config.DB.Save(&entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update care log entry"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+func DeleteCareLogEntry(c *gin.Context) {
+	var entry models.CareLogEntry
+	if err := config.DB.First(&entry, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Care log entry not found"})
+		return
+	}
+
+	if _, ok := findAccessiblePatient(c, entry.PatientID); !ok {
+		return
+	}
+	if entry.AuthorID != c.GetUint("user_id") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author can delete a care log entry"})
+		return
+	}
+
+	if err := config.DB.Delete(&entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete care log entry"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Care log entry deleted successfully"})
+}
+
+// GetCareLogSummary returns per-day figures for the last N days so doctors can
+// spot trends in mood, sleep, appetite and incidents before a visit. Days are
+// calendar days in the clinic time zone.
+func GetCareLogSummary(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(defaultCareLogSummaryDays)))
+	if err != nil || days <= 0 || days > maxCareLogSummaryDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 90"})
+		return
+	}
+
+	since := periodStart(time.Now(), "day").AddDate(0, 0, -(days - 1))
+
+	var entries []models.CareLogEntry
+	if err := config.DB.Where("patient_id = ? AND logged_at >= ?", patient.ID, since).
+		Order("logged_at asc").Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch care log"})
+		return
+	}
+
+	summaries := make([]CareLogDaySummary, days)
+	accs := make([]careLogAccumulator, days)
+	for i := range summaries {
+		summaries[i].Date = since.AddDate(0, 0, i)
+	}
+
+	for _, entry := range entries {
+		// Round so that days shortened or lengthened by DST still line up
+		i := int(math.Round(periodStart(entry.LoggedAt, "day").Sub(since).Hours() / 24))
+		if i < 0 || i >= days {
+			continue
+		}
+		day, acc := &summaries[i], &accs[i]
+		day.Entries++
+
+		if entry.Mood != nil {
+			acc.mood.add(float64(*entry.Mood))
+		}
+		if entry.SleepQuality != nil {
+			acc.sleep.add(float64(*entry.SleepQuality))
+		}
+		if entry.SleepHours != nil {
+			acc.hours.add(*entry.SleepHours)
+		}
+		switch entry.Category {
+		case "meal":
+			day.Meals++
+			if portion, known := mealPortions[entry.MealPortion]; known {
+				acc.portion.add(portion)
+			}
+		case "bathing":
+			day.Baths++
+		case "incident":
+			day.Incidents++
+		}
+	}
+
+	var mood, sleep averager
+	incidents := 0
+	for i := range summaries {
+		summaries[i].AverageMood = accs[i].mood.mean()
+		summaries[i].AverageSleep = accs[i].sleep.mean()
+		summaries[i].SleepHours = accs[i].hours.mean()
+		summaries[i].AverageMealPortion = accs[i].portion.mean()
+		mood.merge(accs[i].mood)
+		sleep.merge(accs[i].sleep)
+		incidents += summaries[i].Incidents
+	}
+
+	half := days / 2
+	c.JSON(http.StatusOK, gin.H{
+		"days":  summaries,
+		"since": since,
+		"totals": gin.H{
+			"entries":               len(entries),
+			"incidents":             incidents,
+			"average_mood":          mood.mean(),
+			"average_sleep_quality": sleep.mean(),
+		},
+		"trend": gin.H{
+			"mood":          careLogTrend(accs[:half], accs[half:], func(a careLogAccumulator) averager { return a.mood }),
+			"sleep_quality": careLogTrend(accs[:half], accs[half:], func(a careLogAccumulator) averager { return a.sleep }),
+			"meal_portion":  careLogTrend(accs[:half], accs[half:], func(a careLogAccumulator) averager { return a.portion }),
+		},
+	})
+}
+
+func validateCareLogEntry(entry models.CareLogEntry) string {
+	if !careLogCategories[entry.Category] {
+		return "Category must be one of meal, mood, sleep, bathing, incident, other"
+	}
+	if entry.Mood != nil && (*entry.Mood < 1 || *entry.Mood > 5) {
+		return "Mood must be between 1 and 5"
+	}
+	if entry.SleepQuality != nil && (*entry.SleepQuality < 1 || *entry.SleepQuality > 5) {
+		return "Sleep quality must be between 1 and 5"
+	}
+	if entry.SleepHours != nil && (*entry.SleepHours < 0 || *entry.SleepHours > 24) {
+		return "Sleep hours must be between 0 and 24"
+	}
+	if _, known := mealPortions[entry.MealPortion]; entry.MealPortion != "" && !known {
+		return "Meal portion must be one of none, some, half, most, all"
+	}
+	if entry.Category == "incident" && entry.IncidentType == "" {
+		return "Incident entries need an incident_type"
+	}
+	return ""
+}
+
+// careLogTrend compares the mean of the later half of the window with the
+// earlier half: "improving", "declining" or "stable".
+func careLogTrend(earlier, later []careLogAccumulator, field func(careLogAccumulator) averager) string {
+	var a, b averager
+	for _, v := range earlier {
+		a.merge(field(v))
+	}
+	for _, v := range later {
+		b.merge(field(v))
+	}
+	if a.count == 0 || b.count == 0 {
+		return "insufficient_data"
+	}
+
+	diff := *b.mean() - *a.mean()
+	switch {
+	case diff > 0.25:
+		return "improving"
+	case diff < -0.25:
+		return "declining"
+	}
+	return "stable"
+}
+
+type careLogAccumulator struct {
+	mood, sleep, portion, hours averager
+}
+
+type averager struct {
+	sum   float64
+	count int
+}
+
+func (a *averager) add(v float64) {
+	a.sum += v
+	a.count++
+}
+
+func (a *averager) merge(o averager) {
+	a.sum += o.sum
+	a.count += o.count
+}
+
+func (a averager) mean() *float64 {
+	if a.count == 0 {
+		return nil
+	}
+	m := a.sum / float64(a.count)
+	return &m
+}
+
+// parseDateParam accepts either RFC3339 or a plain YYYY-MM-DD date, read as
+// midnight in the clinic time zone
+func parseDateParam(value string) (time.Time, error) {
+	return parseDateParamIn(value, scheduling.Location())
+}
+
+// parseDateParamIn is parseDateParam with plain dates read as midnight in loc
+func parseDateParamIn(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, loc)
+}
@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"dementicare-backend/models"
+	"dementicare-backend/scheduling"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCareLogEntryAccess(t *testing.T) {
+	db := useTestDB(t)
+	patient := models.Patient{UserID: 10, Name: "Edith", CaregiverID: 20}
+	db.Create(&patient)
+	db.Create(&models.CareTeamMember{PatientID: patient.ID, UserID: 30})
+	target := "/patients/" + strconv.Itoa(int(patient.ID)) + "/care-log"
+	asCaregiver := caller{id: 20, userType: "caregiver"}
+
+	tests := []struct {
+		name   string
+		as     caller
+		body   map[string]interface{}
+		status int
+	}{
+		{"unrelated caregiver", caller{id: 21, userType: "caregiver"}, map[string]interface{}{"category": "mood", "mood": 3}, http.StatusForbidden},
+		{"unknown category", asCaregiver, map[string]interface{}{"category": "exercise"}, http.StatusBadRequest},
+		{"mood out of range", asCaregiver, map[string]interface{}{"category": "mood", "mood": 6}, http.StatusBadRequest},
+		{"sleep hours out of range", asCaregiver, map[string]interface{}{"category": "sleep", "sleep_hours": 25}, http.StatusBadRequest},
+		{"unknown meal portion", asCaregiver, map[string]interface{}{"category": "meal", "meal_portion": "lots"}, http.StatusBadRequest},
+		{"incident without a type", asCaregiver, map[string]interface{}{"category": "incident"}, http.StatusBadRequest},
+		{"caregiver", asCaregiver, map[string]interface{}{"category": "mood", "mood": 3}, http.StatusCreated},
+		{"care-team member", caller{id: 30, userType: "caregiver"}, map[string]interface{}{"category": "incident", "incident_type": "fall"}, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		w := serve(CreateCareLogEntry, "POST", "/patients/:id/care-log", target, tt.as, tt.body)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+		}
+	}
+
+	// Only the author may change or remove an entry
+	var entry models.CareLogEntry
+	db.Where("author_id = ?", 20).First(&entry)
+	if entry.LoggedAt.IsZero() || entry.PatientID != patient.ID {
+		t.Fatalf("stored %+v, want a logged time and the patient", entry)
+	}
+	entryTarget := "/care-log/" + strconv.Itoa(int(entry.ID))
+	asTeam := caller{id: 30, userType: "caregiver"}
+	if w := serve(UpdateCareLogEntry, "PUT", "/care-log/:id", entryTarget, asTeam, map[string]interface{}{"category": "mood", "mood": 1}); w.Code != http.StatusForbidden {
+		t.Errorf("other author update status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := serve(DeleteCareLogEntry, "DELETE", "/care-log/:id", entryTarget, asTeam, nil); w.Code != http.StatusForbidden {
+		t.Errorf("other author delete status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	w := serve(UpdateCareLogEntry, "PUT", "/care-log/:id", entryTarget, asCaregiver, map[string]interface{}{"category": "mood", "mood": 4, "patient_id": 99, "author_id": 99})
+	if w.Code != http.StatusOK {
+		t.Fatalf("author update status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	db.First(&entry, entry.ID)
+	if *entry.Mood != 4 || entry.PatientID != patient.ID || entry.AuthorID != 20 {
+		t.Errorf("updated entry %+v, want mood 4 with the patient and author kept", entry)
+	}
+	if w := serve(DeleteCareLogEntry, "DELETE", "/care-log/:id", entryTarget, asCaregiver, nil); w.Code != http.StatusOK {
+		t.Errorf("author delete status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestGetCareLog(t *testing.T) {
+	db := useTestDB(t)
+	patient := models.Patient{UserID: 10, Name: "Edith", CaregiverID: 20}
+	db.Create(&patient)
+	at := func(day, hour int) time.Time { return time.Date(2026, 3, day, hour, 0, 0, 0, scheduling.Location()) }
+	for _, entry := range []models.CareLogEntry{
+		{Category: "meal", MealPortion: "half", LoggedAt: at(2, 8)},
+		{Category: "incident", IncidentType: "fall", Notes: "Slipped in the bathroom", LoggedAt: at(2, 20)},
+		{Category: "meal", MealPortion: "all", LoggedAt: at(3, 8)},
+		{Category: "sleep", Notes: "Woke twice", LoggedAt: at(4, 7)},
+	} {
+		entry.PatientID = patient.ID
+		db.Create(&entry)
+	}
+	db.Create(&models.CareLogEntry{PatientID: patient.ID + 1, Category: "meal", LoggedAt: at(2, 8)})
+	target := "/patients/" + strconv.Itoa(int(patient.ID)) + "/care-log"
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", 4},
+		{"?category=meal", 2},
+		{"?from=2026-03-03", 2},
+		{"?to=2026-03-03", 3}, // a plain date includes the whole day
+		{"?to=" + url.QueryEscape(at(3, 0).Format(time.RFC3339)), 2},
+		{"?from=2026-03-02&to=2026-03-02&category=meal", 1},
+		{"?q=bathroom", 1},
+	}
+	for _, tt := range tests {
+		w := serve(GetCareLog, "GET", "/patients/:id/care-log", target+tt.query, caller{id: 1, userType: "doctor"}, nil)
+		var resp struct {
+			Entries []models.CareLogEntry `json:"entries"`
+		}
+		decode(t, w, &resp)
+		if len(resp.Entries) != tt.want {
+			t.Errorf("%q: got %d entries, want %d", tt.query, len(resp.Entries), tt.want)
+		}
+	}
+}
+
+func TestCareLogSummary(t *testing.T) {
+	db := useTestDB(t)
+	patient := models.Patient{UserID: 10, Name: "Edith", CaregiverID: 20}
+	db.Create(&patient)
+	today := periodStart(time.Now(), "day")
+	day := func(i int) time.Time { return today.AddDate(0, 0, i-3).Add(time.Hour) }
+	mood := func(v int) *int { return &v }
+	for _, entry := range []models.CareLogEntry{
+		{Category: "mood", Mood: mood(2), LoggedAt: day(0)},
+		{Category: "meal", MealPortion: "half", LoggedAt: day(0)},
+		{Category: "meal", MealPortion: "all", LoggedAt: day(0)},
+		{Category: "incident", IncidentType: "fall", LoggedAt: day(1)},
+		{Category: "mood", Mood: mood(4), LoggedAt: day(3)},
+		{Category: "mood", Mood: mood(1), LoggedAt: day(-2)}, // before the window
+	} {
+		entry.PatientID = patient.ID
+		db.Create(&entry)
+	}
+	target := "/patients/" + strconv.Itoa(int(patient.ID)) + "/care-log/summary"
+	asDoctor := caller{id: 1, userType: "doctor"}
+
+	for _, query := range []string{"?days=0", "?days=91", "?days=week"} {
+		if w := serve(GetCareLogSummary, "GET", "/patients/:id/care-log/summary", target+query, asDoctor, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+	}
+
+	w := serve(GetCareLogSummary, "GET", "/patients/:id/care-log/summary", target+"?days=4", asDoctor, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var resp struct {
+		Days   []CareLogDaySummary `json:"days"`
+		Totals struct {
+			Entries   int `json:"entries"`
+			Incidents int `json:"incidents"`
+		} `json:"totals"`
+		Trend map[string]string `json:"trend"`
+	}
+	decode(t, w, &resp)
+	if len(resp.Days) != 4 {
+		t.Fatalf("got %d days, want 4", len(resp.Days))
+	}
+	first := resp.Days[0]
+	if first.Entries != 3 || first.Meals != 2 || first.AverageMealPortion == nil || *first.AverageMealPortion != 0.75 ||
+		first.AverageMood == nil || *first.AverageMood != 2 {
+		t.Errorf("first day = %+v, want 3 entries, 2 meals averaging 0.75 and mood 2", first)
+	}
+	if resp.Days[1].Incidents != 1 || resp.Days[2].Entries != 0 || resp.Days[2].AverageMood != nil {
+		t.Errorf("days = %+v, want an incident on the second day and nothing on the third", resp.Days)
+	}
+	if resp.Totals.Entries != 5 || resp.Totals.Incidents != 1 {
+		t.Errorf("totals = %+v, want 5 entries and 1 incident", resp.Totals)
+	}
+	if resp.Trend["mood"] != "improving" || resp.Trend["sleep_quality"] != "insufficient_data" {
+		t.Errorf("trend = %v, want improving mood and no sleep data", resp.Trend)
+	}
+}
@@ -0,0 +1,541 @@
+package controllers
+
+import (
+	"dementicare-backend/config"
+	"dementicare-backend/models"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var carePlanStatuses = map[string]bool{"active": true, "completed": true, "archived": true}
+var carePlanGoalStatuses = map[string]bool{"open": true, "achieved": true, "dropped": true}
+var carePlanTaskStatuses = map[string]bool{"pending": true, "in_progress": true, "done": true, "cancelled": true}
+
+type CarePlanUpdateRequest struct {
+	Title      *string    `json:"title"`
+	Status     *string    `json:"status"`
+	ReviewDate *time.Time `json:"review_date"`
+	ChangeNote string     `json:"change_note"`
+}
+
+// CarePlanTaskProgressRequest is all an assignee without access to the plan
+// may change on a task
+type CarePlanTaskProgressRequest struct {
+	Status     string `json:"status" binding:"required"`
+	ChangeNote string `json:"change_note"`
+}
+
+type MyTask struct {
+	models.CarePlanTask
+	PatientID uint   `json:"patient_id"`
+	PlanTitle string `json:"plan_title"`
+	Overdue   bool   `json:"overdue"`
+}
+
+func GetCarePlans(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	query := config.DB.Preload("Goals").Preload("Tasks").Where("patient_id = ?", patient.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var plans []models.CarePlan
+	if err := query.Order("created_at desc").Find(&plans).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch care plans"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"care_plans": plans})
+}
+
+func GetCarePlan(c *gin.Context) {
+	plan, ok := findAccessibleCarePlan(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// CreateCarePlan creates a plan together with any goals and tasks in the
+// request body and records it as version 1. Goal IDs do not exist yet, so
+// nested tasks name their goal by goal_index, its position in goals.
+func CreateCarePlan(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var plan models.CarePlan
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plan.ID = 0
+	plan.PatientID = patient.ID
+	plan.CreatedBy = c.GetUint("user_id")
+	plan.Version = 0
+	if plan.Status == "" {
+		plan.Status = "active"
+	}
+	if plan.Title == "" || !carePlanStatuses[plan.Status] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Title is required and status must be active, completed or archived"})
+		return
+	}
+	for i := range plan.Goals {
+		plan.Goals[i].ID = 0
+		if msg := validateCarePlanGoal(&plan.Goals[i]); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+	}
+	for i := range plan.Tasks {
+		plan.Tasks[i].ID = 0
+		plan.Tasks[i].GoalID = nil
+		if index := plan.Tasks[i].GoalIndex; index != nil && (*index < 0 || *index >= len(plan.Goals)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "goal_index must refer to one of the plan's goals"})
+			return
+		}
+		if msg := validateCarePlanTask(&plan.Tasks[i]); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+	}
+
+	// Tasks are created after the goals so they can be linked to them
+	tasks := plan.Tasks
+	plan.Tasks = nil
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&plan).Error; err != nil {
+			return err
+		}
+		for i := range tasks {
+			tasks[i].CarePlanID = plan.ID
+			if index := tasks[i].GoalIndex; index != nil {
+				tasks[i].GoalID = &plan.Goals[*index].ID
+			}
+		}
+		if len(tasks) > 0 {
+			if err := tx.Create(&tasks).Error; err != nil {
+				return err
+			}
+		}
+		return saveCarePlanRevision(tx, plan.ID, plan.CreatedBy, "Plan created")
+	})
+	if err != nil {
+		log.Printf("Error creating care plan for patient %d: %v", patient.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create care plan"})
+		return
+	}
+
+	respondWithCarePlan(c, http.StatusCreated, plan.ID)
+}
+
+func UpdateCarePlan(c *gin.Context) {
+	plan, ok := findAccessibleCarePlan(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req CarePlanUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Title != nil {
+		if *req.Title == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Title cannot be empty"})
+			return
+		}
+		updates["title"] = *req.Title
+	}
+	if req.Status != nil {
+		if !carePlanStatuses[*req.Status] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be active, completed or archived"})
+			return
+		}
+		updates["status"] = *req.Status
+	}
+	if req.ReviewDate != nil {
+		updates["review_date"] = *req.ReviewDate
+	}
+	if len(updates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.CarePlan{ID: plan.ID}).Updates(updates).Error; err != nil {
+			return err
+		}
+		return saveCarePlanRevision(tx, plan.ID, c.GetUint("user_id"), changeNote(req.ChangeNote, "Plan updated"))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update care plan"})
+		return
+	}
+
+	respondWithCarePlan(c, http.StatusOK, plan.ID)
+}
+
+func GetCarePlanRevisions(c *gin.Context) {
+	plan, ok := findAccessibleCarePlan(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var revisions []models.CarePlanRevision
+	if err := config.DB.Where("care_plan_id = ?", plan.ID).Order("version desc").Find(&revisions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch revisions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revisions": revisions})
+}
+
+func CreateCarePlanGoal(c *gin.Context) {
+	plan, ok := findAccessibleCarePlan(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var goal models.CarePlanGoal
+	if err := c.ShouldBindJSON(&goal); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	goal.ID = 0
+	goal.CarePlanID = plan.ID
+	if msg := validateCarePlanGoal(&goal); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&goal).Error; err != nil {
+			return err
+		}
+		return saveCarePlanRevision(tx, plan.ID, c.GetUint("user_id"), "Goal added")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add goal"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, goal)
+}
+
+func UpdateCarePlanGoal(c *gin.Context) {
+	var goal models.CarePlanGoal
+	if err := config.DB.First(&goal, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+		return
+	}
+	if _, ok := findAccessibleCarePlan(c, goal.CarePlanID); !ok {
+		return
+	}
+
+	id, planID := goal.ID, goal.CarePlanID
+	if err := c.ShouldBindJSON(&goal); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	goal.ID, goal.CarePlanID = id, planID
+	if msg := validateCarePlanGoal(&goal); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&goal).Error; err != nil {
+			return err
+		}
+		return saveCarePlanRevision(tx, planID, c.GetUint("user_id"), "Goal updated")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update goal"})
+		return
+	}
+
+	c.JSON(http.StatusOK, goal)
+}
+
+func CreateCarePlanTask(c *gin.Context) {
+	plan, ok := findAccessibleCarePlan(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var task models.CarePlanTask
+	if err := c.ShouldBindJSON(&task); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	task.ID = 0
+	task.CarePlanID = plan.ID
+	if msg := validateCarePlanTask(&task); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if !taskGoalBelongsToPlan(task) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Goal does not belong to this care plan"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&task).Error; err != nil {
+			return err
+		}
+		return saveCarePlanRevision(tx, plan.ID, c.GetUint("user_id"), "Task added")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add task"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, task)
+}
+
+// UpdateCarePlanTask lets anyone with access to the patient update a task.
+// The assigned user may report progress without that access, but then only
+// the status, with an optional change_note.
+func UpdateCarePlanTask(c *gin.Context) {
+	var task models.CarePlanTask
+	if err := config.DB.First(&task, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	id, planID, previousStatus := task.ID, task.CarePlanID, task.Status
+	note := "Task updated"
+	if task.AssignedTo == c.GetUint("user_id") && !canAccessCarePlan(c, planID) {
+		var req CarePlanTaskProgressRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !carePlanTaskStatuses[req.Status] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Task status must be pending, in_progress, done or cancelled"})
+			return
+		}
+		task.Status = req.Status
+		note = changeNote(req.ChangeNote, note)
+	} else {
+		if _, ok := findAccessibleCarePlan(c, planID); !ok {
+			return
+		}
+		if err := c.ShouldBindJSON(&task); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		task.ID, task.CarePlanID = id, planID
+		if msg := validateCarePlanTask(&task); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		if !taskGoalBelongsToPlan(task) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Goal does not belong to this care plan"})
+			return
+		}
+	}
+
+	if task.Status == "done" && previousStatus != "done" {
+		now := time.Now()
+		task.CompletedAt = &now
+	} else if task.Status != "done" {
+		task.CompletedAt = nil
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&task).Error; err != nil {
+			return err
+		}
+		return saveCarePlanRevision(tx, planID, c.GetUint("user_id"), note)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task"})
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+// GetMyTasks lists open care plan tasks assigned to the caller, overdue tasks
+// first, followed by the rest ordered by due date. Plans the caller created
+// that are due for review are included as well.
+func GetMyTasks(c *gin.Context) {
+	query := config.DB.Table("care_plan_tasks").
+		Select("care_plan_tasks.*, care_plans.patient_id, care_plans.title as plan_title").
+		Joins("JOIN care_plans ON care_plans.id = care_plan_tasks.care_plan_id AND care_plans.deleted_at IS NULL").
+		Where("care_plan_tasks.deleted_at IS NULL").
+		Where("care_plan_tasks.assigned_to = ?", c.GetUint("user_id"))
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("care_plan_tasks.status = ?", status)
+	} else {
+		query = query.Where("care_plan_tasks.status IN ?", []string{"pending", "in_progress"})
+	}
+
+	var tasks []MyTask
+	if err := query.Scan(&tasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tasks"})
+		return
+	}
+
+	now := time.Now()
+	overdue := 0
+	for i := range tasks {
+		t := &tasks[i]
+		t.Overdue = t.DueDate != nil && t.DueDate.Before(now) && (t.Status == "pending" || t.Status == "in_progress")
+		if t.Overdue {
+			overdue++
+		}
+	}
+	if c.Query("overdue") == "true" {
+		filtered := []MyTask{}
+		for _, t := range tasks {
+			if t.Overdue {
+				filtered = append(filtered, t)
+			}
+		}
+		tasks = filtered
+	}
+
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if tasks[i].Overdue != tasks[j].Overdue {
+			return tasks[i].Overdue
+		}
+		if tasks[i].DueDate == nil || tasks[j].DueDate == nil {
+			return tasks[j].DueDate == nil && tasks[i].DueDate != nil
+		}
+		return tasks[i].DueDate.Before(*tasks[j].DueDate)
+	})
+
+	// Active plans the caller created that are past their review date
+	var reviewsDue []models.CarePlan
+	if err := config.DB.Where("created_by = ? AND status = ? AND review_date <= ?", c.GetUint("user_id"), "active", now).
+		Order("review_date asc").Find(&reviewsDue).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch care plan reviews"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tasks": tasks, "overdue_count": overdue, "reviews_due": reviewsDue})
+}
+
+// findAccessibleCarePlan loads a care plan with goals and tasks and checks
+// access through its patient.
+func findAccessibleCarePlan(c *gin.Context, id interface{}) (models.CarePlan, bool) {
+	var plan models.CarePlan
+	if err := config.DB.Preload("Goals").Preload("Tasks").First(&plan, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Care plan not found"})
+		return plan, false
+	}
+
+	if _, ok := findAccessiblePatient(c, plan.PatientID); !ok {
+		return plan, false
+	}
+
+	return plan, true
+}
+
+// canAccessCarePlan reports whether the caller may see the plan's patient,
+// without writing a response
+func canAccessCarePlan(c *gin.Context, planID uint) bool {
+	var plan models.CarePlan
+	var patient models.Patient
+	return config.DB.Select("patient_id").First(&plan, planID).Error == nil &&
+		config.DB.First(&patient, plan.PatientID).Error == nil &&
+		canAccessPatient(c, patient)
+}
+
+// saveCarePlanRevision bumps the plan version and stores a snapshot of its
+// current state, including goals and tasks.
+func saveCarePlanRevision(tx *gorm.DB, planID, userID uint, note string) error {
+	if err := tx.Model(&models.CarePlan{}).Where("id = ?", planID).
+		UpdateColumn("version", gorm.Expr("version + 1")).Error; err != nil {
+		return err
+	}
+
+	var plan models.CarePlan
+	if err := tx.Preload("Goals").Preload("Tasks").First(&plan, planID).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(&models.CarePlanRevision{
+		CarePlanID: plan.ID,
+		Version:    plan.Version,
+		Snapshot:   plan,
+		ChangedBy:  userID,
+		ChangeNote: note,
+	}).Error
+}
+
+func respondWithCarePlan(c *gin.Context, status int, planID uint) {
+	var plan models.CarePlan
+	if err := config.DB.Preload("Goals").Preload("Tasks").First(&plan, planID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load care plan"})
+		return
+	}
+	c.JSON(status, plan)
+}
+
+func validateCarePlanGoal(goal *models.CarePlanGoal) string {
+	if goal.Status == "" {
+		goal.Status = "open"
+	}
+	if goal.Description == "" {
+		return "Goal description is required"
+	}
+	if !carePlanGoalStatuses[goal.Status] {
+		return "Goal status must be open, achieved or dropped"
+	}
+	return ""
+}
+
+func validateCarePlanTask(task *models.CarePlanTask) string {
+	if task.Status == "" {
+		task.Status = "pending"
+	}
+	if task.Intervention == "" {
+		return "Task intervention is required"
+	}
+	if !carePlanTaskStatuses[task.Status] {
+		return "Task status must be pending, in_progress, done or cancelled"
+	}
+	if task.AssignedTo != 0 {
+		var assignee models.User
+		if err := config.DB.First(&assignee, task.AssignedTo).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			return "Assigned user not found"
+		}
+	}
+	return ""
+}
+
+func taskGoalBelongsToPlan(task models.CarePlanTask) bool {
+	if task.GoalID == nil {
+		return true
+	}
+	var count int64
+	config.DB.Model(&models.CarePlanGoal{}).Where("id = ? AND care_plan_id = ?", *task.GoalID, task.CarePlanID).Count(&count)
+	return count > 0
+}
+
+func changeNote(note, fallback string) string {
+	if note != "" {
+		return note
+	}
+	return fallback
+}
@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"dementicare-backend/models"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestUpdateCarePlanTask(t *testing.T) {
+	db := useTestDB(t)
+	db.Create(&[]models.User{
+		{ID: 1, Email: "doctor@example.com", Password: "x", UserType: "doctor"},
+		{ID: 40, Email: "nurse@example.com", Password: "x", UserType: "caregiver"},
+		{ID: 41, Email: "other@example.com", Password: "x", UserType: "caregiver"},
+	})
+	patient := models.Patient{Name: "Edith", CaregiverID: 20}
+	db.Create(&patient)
+	plan := models.CarePlan{PatientID: patient.ID, Title: "Wandering"}
+	db.Create(&plan)
+
+	tests := []struct {
+		name      string
+		as        caller
+		body      map[string]interface{}
+		status    int
+		want      models.CarePlanTask
+		wantNote  string
+		completed bool
+	}{
+		{
+			name:      "assignee without access reports progress only",
+			as:        caller{id: 40, userType: "caregiver"},
+			body:      map[string]interface{}{"status": "done", "intervention": "Nothing", "assigned_to": 41, "change_note": "Fitted the door alarm"},
+			status:    http.StatusOK,
+			want:      models.CarePlanTask{Intervention: "Door alarm", AssignedTo: 40, Status: "done"},
+			wantNote:  "Fitted the door alarm",
+			completed: true,
+		},
+		{
+			name:   "assignee with a bad status",
+			as:     caller{id: 40, userType: "caregiver"},
+			body:   map[string]interface{}{"status": "finished"},
+			status: http.StatusBadRequest,
+			want:   models.CarePlanTask{Intervention: "Door alarm", AssignedTo: 40, Status: "pending"},
+		},
+		{
+			name:   "unrelated caregiver",
+			as:     caller{id: 41, userType: "caregiver"},
+			body:   map[string]interface{}{"status": "done"},
+			status: http.StatusForbidden,
+			want:   models.CarePlanTask{Intervention: "Door alarm", AssignedTo: 40, Status: "pending"},
+		},
+		{
+			name:     "patient's caregiver edits anything",
+			as:       caller{id: 20, userType: "caregiver"},
+			body:     map[string]interface{}{"intervention": "GPS insoles", "assigned_to": 41, "status": "in_progress"},
+			status:   http.StatusOK,
+			want:     models.CarePlanTask{Intervention: "GPS insoles", AssignedTo: 41, Status: "in_progress"},
+			wantNote: "Task updated",
+		},
+	}
+	for _, tt := range tests {
+		task := models.CarePlanTask{CarePlanID: plan.ID, Intervention: "Door alarm", AssignedTo: 40, Status: "pending"}
+		db.Create(&task)
+		target := "/care-plan-tasks/" + strconv.Itoa(int(task.ID))
+		w := serve(UpdateCarePlanTask, "PUT", "/care-plan-tasks/:id", target, tt.as, tt.body)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+		}
+
+		var got models.CarePlanTask
+		db.First(&got, task.ID)
+		if got.Intervention != tt.want.Intervention || got.AssignedTo != tt.want.AssignedTo || got.Status != tt.want.Status {
+			t.Errorf("%s: task = %q assigned to %d %s, want %q assigned to %d %s", tt.name,
+				got.Intervention, got.AssignedTo, got.Status, tt.want.Intervention, tt.want.AssignedTo, tt.want.Status)
+		}
+		if (got.CompletedAt != nil) != tt.completed {
+			t.Errorf("%s: completed at %v, want completed %v", tt.name, got.CompletedAt, tt.completed)
+		}
+		if tt.wantNote != "" {
+			var revision models.CarePlanRevision
+			db.Where("care_plan_id = ?", plan.ID).Order("id desc").First(&revision)
+			if revision.ChangeNote != tt.wantNote {
+				t.Errorf("%s: revision note = %q, want %q", tt.name, revision.ChangeNote, tt.wantNote)
+			}
+		}
+	}
+}
+
+func TestCreateCarePlan(t *testing.T) {
+	db := useTestDB(t)
+	db.Create(&models.User{ID: 40, Email: "nurse@example.com", Password: "x", UserType: "caregiver"})
+	patient := models.Patient{Name: "Edith", CaregiverID: 20}
+	db.Create(&patient)
+	target := "/patients/" + strconv.Itoa(int(patient.ID)) + "/care-plans"
+	asCaregiver := caller{id: 20, userType: "caregiver"}
+	goals := []map[string]interface{}{{"description": "Sleep through the night"}, {"description": "Stay safe at home"}}
+	task := func(goalIndex interface{}, assignee uint) map[string]interface{} {
+		return map[string]interface{}{"intervention": "Door alarm", "goal_index": goalIndex, "assigned_to": assignee}
+	}
+
+	tests := []struct {
+		name   string
+		as     caller
+		body   map[string]interface{}
+		status int
+	}{
+		{"unlinked caregiver", caller{id: 21, userType: "caregiver"}, map[string]interface{}{"title": "Wandering"}, http.StatusForbidden},
+		{"no title", asCaregiver, map[string]interface{}{"goals": goals}, http.StatusBadRequest},
+		{"goal index out of range", asCaregiver, map[string]interface{}{"title": "Wandering", "goals": goals, "tasks": []interface{}{task(2, 40)}}, http.StatusBadRequest},
+		{"unknown assignee", asCaregiver, map[string]interface{}{"title": "Wandering", "goals": goals, "tasks": []interface{}{task(1, 41)}}, http.StatusBadRequest},
+		{"goal without a description", asCaregiver, map[string]interface{}{"title": "Wandering", "goals": []interface{}{map[string]interface{}{"status": "open"}}}, http.StatusBadRequest},
+		{"valid", asCaregiver, map[string]interface{}{"title": "Wandering", "goals": goals, "tasks": []interface{}{task(1, 40), task(nil, 0)}}, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		w := serve(CreateCarePlan, "POST", "/patients/:id/care-plans", target, tt.as, tt.body)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+		}
+	}
+
+	var plan models.CarePlan
+	db.Preload("Goals").Preload("Tasks").First(&plan)
+	if plan.Version != 1 || plan.Status != "active" || plan.CreatedBy != 20 || len(plan.Goals) != 2 || len(plan.Tasks) != 2 {
+		t.Fatalf("created %+v, want an active version 1 plan with 2 goals and 2 tasks", plan)
+	}
+	if goalID := plan.Tasks[0].GoalID; goalID == nil || *goalID != plan.Goals[1].ID || plan.Tasks[1].GoalID != nil {
+		t.Errorf("tasks %+v, want the first linked to the second goal and the second to none", plan.Tasks)
+	}
+
+	// Each change bumps the version and keeps a snapshot
+	planTarget := "/care-plans/" + strconv.Itoa(int(plan.ID))
+	if w := serve(UpdateCarePlan, "PUT", "/care-plans/:id", planTarget, asCaregiver, map[string]interface{}{"status": "paused"}); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown status: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := serve(UpdateCarePlan, "PUT", "/care-plans/:id", planTarget, asCaregiver, map[string]interface{}{"title": "Wandering at night", "change_note": "Narrowed"}); w.Code != http.StatusOK {
+		t.Fatalf("update status = %d: %s", w.Code, w.Body)
+	}
+	other := models.CarePlan{PatientID: patient.ID, Title: "Nutrition"}
+	db.Create(&other)
+	otherGoal := models.CarePlanGoal{CarePlanID: other.ID, Description: "Eat three meals"}
+	db.Create(&otherGoal)
+	body := map[string]interface{}{"intervention": "Meal prompts", "goal_id": otherGoal.ID}
+	if w := serve(CreateCarePlanTask, "POST", "/care-plans/:id/tasks", planTarget+"/tasks", asCaregiver, body); w.Code != http.StatusBadRequest {
+		t.Errorf("task for another plan's goal: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	w := serve(GetCarePlanRevisions, "GET", "/care-plans/:id/revisions", planTarget+"/revisions", asCaregiver, nil)
+	var resp struct {
+		Revisions []models.CarePlanRevision `json:"revisions"`
+	}
+	decode(t, w, &resp)
+	if len(resp.Revisions) != 2 || resp.Revisions[0].Version != 2 || resp.Revisions[0].ChangeNote != "Narrowed" ||
+		resp.Revisions[0].Snapshot.Title != "Wandering at night" || resp.Revisions[1].Snapshot.Title != "Wandering" {
+		t.Errorf("revisions %+v, want version 2 then the original", resp.Revisions)
+	}
+}
+
+func TestGetMyTasks(t *testing.T) {
+	db := useTestDB(t)
+	now := time.Now()
+	day := func(offset int) *time.Time { d := now.AddDate(0, 0, offset); return &d }
+	plan := models.CarePlan{PatientID: 1, Title: "Wandering", Status: "active", CreatedBy: 40, ReviewDate: day(-1)}
+	db.Create(&plan)
+	db.Create(&models.CarePlan{PatientID: 1, Title: "Nutrition", Status: "active", CreatedBy: 40, ReviewDate: day(7)})
+	tasks := []models.CarePlanTask{
+		{Intervention: "Later", AssignedTo: 40, DueDate: day(5), Status: "pending"},
+		{Intervention: "No date", AssignedTo: 40, Status: "in_progress"},
+		{Intervention: "Overdue", AssignedTo: 40, DueDate: day(-2), Status: "pending"},
+		{Intervention: "Soon", AssignedTo: 40, DueDate: day(1), Status: "pending"},
+		{Intervention: "Done", AssignedTo: 40, DueDate: day(-3), Status: "done"},
+		{Intervention: "Someone else's", AssignedTo: 41, DueDate: day(-3), Status: "pending"},
+	}
+	for i := range tasks {
+		tasks[i].CarePlanID = plan.ID
+		db.Create(&tasks[i])
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"Overdue", "Soon", "Later", "No date"}},
+		{"?overdue=true", []string{"Overdue"}},
+		{"?status=done", []string{"Done"}},
+	}
+	for _, tt := range tests {
+		w := serve(GetMyTasks, "GET", "/my-tasks", "/my-tasks"+tt.query, caller{id: 40, userType: "caregiver"}, nil)
+		var resp struct {
+			Tasks        []MyTask          `json:"tasks"`
+			OverdueCount int               `json:"overdue_count"`
+			ReviewsDue   []models.CarePlan `json:"reviews_due"`
+		}
+		decode(t, w, &resp)
+		got := make([]string, len(resp.Tasks))
+		for i, task := range resp.Tasks {
+			got[i] = task.Intervention
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%q: tasks %v, want %v", tt.query, got, tt.want)
+		}
+		if tt.query == "" && (resp.OverdueCount != 1 || len(resp.ReviewsDue) != 1 || resp.ReviewsDue[0].ID != plan.ID || resp.Tasks[0].PlanTitle != "Wandering") {
+			t.Errorf("overdue %d, reviews due %+v, want 1 overdue task and the Wandering plan", resp.OverdueCount, resp.ReviewsDue)
+		}
+	}
+}
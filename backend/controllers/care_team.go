@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"dementicare-backend/config"
+	"dementicare-backend/models"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var careTeamRoles = map[string]bool{
+	"family":        true,
+	"nurse":         true,
+	"social_worker": true,
+	"other":         true,
+}
+
+type CareTeamMemberRequest struct {
+	UserID uint   `json:"user_id" binding:"required"`
+	Role   string `json:"role"`
+}
+
+func GetCareTeam(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var members []models.CareTeamMember
+	if err := config.DB.Where("patient_id = ?", patient.ID).Order("created_at asc").Find(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch care team"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"caregiver_id": patient.CaregiverID, "members": members})
+}
+
+// AddCareTeamMember links a caregiver account to the patient. Doctors,
+// admins and the patient's primary caregiver manage the care team.
+func AddCareTeamMember(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if !canManageCareTeam(c, patient) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only doctors, admins and the primary caregiver can manage the care team"})
+		return
+	}
+
+	var req CareTeamMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Role == "" {
+		req.Role = "other"
+	}
+	if !careTeamRoles[req.Role] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be family, nurse, social_worker or other"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.Where("user_type = ?", "caregiver").First(&user, req.UserID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Care-team members must have a caregiver account"})
+		return
+	}
+	if user.ID == patient.CaregiverID || isCareTeamMember(patient.ID, user.ID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This user already looks after the patient"})
+		return
+	}
+
+	member := models.CareTeamMember{
+		PatientID: patient.ID,
+		UserID:    user.ID,
+		Role:      req.Role,
+		AddedBy:   c.GetUint("user_id"),
+	}
+	if err := config.DB.Create(&member).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add care-team member"})
+		return
+	}
+
+	log.Printf("Care-team member added - Patient: %d, User: %d, By: %d", patient.ID, user.ID, member.AddedBy)
+	c.JSON(http.StatusCreated, member)
+}
+
+// RemoveCareTeamMember unlinks a member. Members may also remove themselves.
+func RemoveCareTeamMember(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var member models.CareTeamMember
+	if err := config.DB.Where("patient_id = ?", patient.ID).First(&member, c.Param("memberId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Care-team member not found"})
+		return
+	}
+	if !canManageCareTeam(c, patient) && member.UserID != c.GetUint("user_id") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only doctors, admins and the primary caregiver can manage the care team"})
+		return
+	}
+
+	if err := config.DB.Delete(&member).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove care-team member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Care-team member removed"})
+}
+
+func canManageCareTeam(c *gin.Context, patient models.Patient) bool {
+	switch c.GetString("user_type") {
+	case "doctor", "admin":
+		return true
+	case "caregiver":
+		return patient.CaregiverID == c.GetUint("user_id")
+	}
+	return false
+}
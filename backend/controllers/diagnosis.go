@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"dementicare-backend/config"
+	"dementicare-backend/models"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var icd10Pattern = regexp.MustCompile(`^[A-TV-Z][0-9][0-9A-Z](\.[0-9A-Z]{1,4})?$`)
+
+// Valid stages for each supported staging scale
+var stagingScales = map[string]map[string]bool{
+	"GDS": {"1": true, "2": true, "3": true, "4": true, "5": true, "6": true, "7": true},
+	"CDR": {"0": true, "0.5": true, "1": true, "2": true, "3": true},
+}
+
+// createIntakeDiagnoses starts the diagnosis history of newly created
+// patients with the free-text diagnosis they were entered with, if any
+func createIntakeDiagnoses(tx *gorm.DB, patients []models.Patient) error {
+	var diagnoses []models.Diagnosis
+	for _, patient := range patients {
+		if strings.TrimSpace(patient.Diagnosis) == "" {
+			continue
+		}
+		diagnoses = append(diagnoses, models.Diagnosis{
+			PatientID:  patient.ID,
+			Condition:  patient.Diagnosis,
+			AssessedAt: patient.CreatedAt,
+			Source:     "intake",
+		})
+	}
+	if len(diagnoses) == 0 {
+		return nil
+	}
+	return tx.CreateInBatches(&diagnoses, 200).Error
+}
+
+// GetDiagnoses returns the patient's diagnosis history, oldest first, so that
+// clients can chart progression over time.
+func GetDiagnoses(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	query := config.DB.Where("patient_id = ?", patient.ID)
+	if scale := c.Query("scale"); scale != "" {
+		query = query.Where("stage_scale = ?", strings.ToUpper(scale))
+	}
+
+	var diagnoses []models.Diagnosis
+	if err := query.Order("assessed_at asc, id asc").Find(&diagnoses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch diagnoses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"diagnoses": diagnoses})
+}
+
+// GetCurrentDiagnosis returns the latest diagnosis and the latest stage on
+// each staging scale.
+func GetCurrentDiagnosis(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var latest models.Diagnosis
+	found := config.DB.Where("patient_id = ?", patient.ID).
+		Order("assessed_at desc, id desc").Limit(1).Find(&latest).RowsAffected > 0
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No diagnosis recorded for this patient"})
+		return
+	}
+
+	stages := gin.H{}
+	for scale := range stagingScales {
+		var staged models.Diagnosis
+		if config.DB.Where("patient_id = ? AND stage_scale = ?", patient.ID, scale).
+			Order("assessed_at desc, id desc").Limit(1).Find(&staged).RowsAffected > 0 {
+			stages[scale] = staged
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"diagnosis": latest, "stages": stages})
+}
+
+func CreateDiagnosis(c *gin.Context) {
+	if c.GetString("user_type") != "doctor" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only doctors can record diagnoses"})
+		return
+	}
+
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var diagnosis models.Diagnosis
+	if err := c.ShouldBindJSON(&diagnosis); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	diagnosis.ID = 0
+	diagnosis.PatientID = patient.ID
+	diagnosis.AssessedBy = c.GetUint("user_id")
+	diagnosis.Source = "assessment"
+	diagnosis.ICD10Code = strings.ToUpper(strings.TrimSpace(diagnosis.ICD10Code))
+	diagnosis.StageScale = strings.ToUpper(strings.TrimSpace(diagnosis.StageScale))
+	if diagnosis.AssessedAt.IsZero() {
+		diagnosis.AssessedAt = time.Now()
+	}
+
+	if strings.TrimSpace(diagnosis.Condition) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Condition is required"})
+		return
+	}
+	if diagnosis.ICD10Code != "" && !icd10Pattern.MatchString(diagnosis.ICD10Code) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ICD-10 code"})
+		return
+	}
+	if diagnosis.StageScale != "" || diagnosis.Stage != "" {
+		stages, known := stagingScales[diagnosis.StageScale]
+		if !known {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Stage scale must be GDS or CDR"})
+			return
+		}
+		if !stages[diagnosis.Stage] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stage for " + diagnosis.StageScale})
+			return
+		}
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&diagnosis).Error; err != nil {
+			return err
+		}
+
+		// Keep the legacy free-text field pointing at the newest condition
+		var newer int64
+		if err := tx.Model(&models.Diagnosis{}).
+			Where("patient_id = ? AND assessed_at > ?", patient.ID, diagnosis.AssessedAt).
+			Count(&newer).Error; err != nil {
+			return err
+		}
+		if newer == 0 {
+			return tx.Model(&patient).Update("diagnosis", diagnosis.Condition).Error
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error saving diagnosis for patient %d: %v", patient.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save diagnosis"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, diagnosis)
+}
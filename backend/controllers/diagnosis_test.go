@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"dementicare-backend/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIntakeDiagnosis(t *testing.T) {
+	db := useTestDB(t)
+	doctor := caller{id: 1, userType: "doctor"}
+
+	w := serve(CreatePatient, "POST", "/patients", "/patients", doctor,
+		map[string]interface{}{"name": "Edith", "diagnosis": "Vascular dementia"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create status = %d: %s", w.Code, w.Body)
+	}
+	serve(CreatePatient, "POST", "/patients", "/patients", doctor, map[string]interface{}{"name": "Arthur"})
+
+	w = serve(ImportPatients, "POST", "/patients/import", "/patients/import", doctor,
+		upload{field: "file", filename: "patients.csv", content: []byte("name,diagnosis\nMaud,Alzheimer disease\nWalter,\n")})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("import status = %d: %s", w.Code, w.Body)
+	}
+
+	var patients []models.Patient
+	db.Order("id").Find(&patients)
+	want := map[string]string{"Edith": "Vascular dementia", "Arthur": "", "Maud": "Alzheimer disease", "Walter": ""}
+	for _, patient := range patients {
+		var history []models.Diagnosis
+		db.Where("patient_id = ?", patient.ID).Find(&history)
+		switch {
+		case want[patient.Name] == "" && len(history) != 0:
+			t.Errorf("%s: got %d diagnosis entries, want none", patient.Name, len(history))
+		case want[patient.Name] != "" && (len(history) != 1 || history[0].Condition != want[patient.Name] || history[0].Source != "intake"):
+			t.Errorf("%s: history = %+v, want one intake entry for %q", patient.Name, history, want[patient.Name])
+		}
+	}
+}
+
+func TestCreateDiagnosis(t *testing.T) {
+	db := useTestDB(t)
+	patient := models.Patient{Name: "Edith", CaregiverID: 20, Diagnosis: "Mild cognitive impairment"}
+	db.Create(&patient)
+	target := "/patients/" + strconv.Itoa(int(patient.ID)) + "/diagnoses"
+	at := func(day int) time.Time { return time.Date(2026, 3, day, 10, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name      string
+		as        caller
+		body      map[string]interface{}
+		status    int
+		diagnosis string
+	}{
+		{"caregiver", caller{id: 20, userType: "caregiver"}, map[string]interface{}{"condition": "Alzheimer disease"}, http.StatusForbidden, "Mild cognitive impairment"},
+		{"bad stage", caller{id: 1, userType: "doctor"}, map[string]interface{}{"condition": "Alzheimer disease", "stage_scale": "GDS", "stage": "8"}, http.StatusBadRequest, "Mild cognitive impairment"},
+		{"bad ICD-10 code", caller{id: 1, userType: "doctor"}, map[string]interface{}{"condition": "Alzheimer disease", "icd10_code": "U07.1"}, http.StatusBadRequest, "Mild cognitive impairment"},
+		{"newest", caller{id: 1, userType: "doctor"}, map[string]interface{}{"condition": "Alzheimer disease", "icd10_code": "g30.1", "stage_scale": "gds", "stage": "4", "assessed_at": at(10)}, http.StatusCreated, "Alzheimer disease"},
+		{"back-dated", caller{id: 1, userType: "doctor"}, map[string]interface{}{"condition": "Memory complaints", "assessed_at": at(1)}, http.StatusCreated, "Alzheimer disease"},
+	}
+	for _, tt := range tests {
+		w := serve(CreateDiagnosis, "POST", "/patients/:id/diagnoses", target, tt.as, tt.body)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+		}
+		var got models.Patient
+		db.First(&got, patient.ID)
+		if got.Diagnosis != tt.diagnosis {
+			t.Errorf("%s: patient diagnosis = %q, want %q", tt.name, got.Diagnosis, tt.diagnosis)
+		}
+	}
+
+	var staged models.Diagnosis
+	db.Where("patient_id = ? AND stage_scale = ?", patient.ID, "GDS").First(&staged)
+	if staged.ICD10Code != "G30.1" || staged.Stage != "4" {
+		t.Errorf("staged entry = %s %s %s, want normalized G30.1 GDS 4", staged.ICD10Code, staged.StageScale, staged.Stage)
+	}
+
+	// The free-text field only changes through the history
+	w := serve(UpdatePatient, "PUT", "/patients/:id", "/patients/"+strconv.Itoa(int(patient.ID)), caller{id: 1, userType: "doctor"},
+		map[string]interface{}{"name": "Edith", "diagnosis": "Typed over"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("update status = %d: %s", w.Code, w.Body)
+	}
+	var got models.Patient
+	db.First(&got, patient.ID)
+	if got.Diagnosis != "Alzheimer disease" {
+		t.Errorf("after update: patient diagnosis = %q, want it unchanged", got.Diagnosis)
+	}
+}
+
+func TestDiagnosisHistory(t *testing.T) {
+	db := useTestDB(t)
+	patient := models.Patient{UserID: 10, Name: "Edith", CaregiverID: 20}
+	db.Create(&patient)
+	undiagnosed := models.Patient{Name: "Arthur"}
+	db.Create(&undiagnosed)
+	at := func(day int) time.Time { return time.Date(2026, 3, day, 10, 0, 0, 0, time.UTC) }
+	db.Create(&[]models.Diagnosis{
+		{PatientID: patient.ID, Condition: "Mild cognitive impairment", AssessedAt: at(1), Source: "intake"},
+		{PatientID: patient.ID, Condition: "Alzheimer disease", StageScale: "GDS", Stage: "4", AssessedAt: at(5), Source: "assessment"},
+		{PatientID: patient.ID, Condition: "Alzheimer disease", StageScale: "CDR", Stage: "1", AssessedAt: at(8), Source: "assessment"},
+		{PatientID: patient.ID, Condition: "Alzheimer disease", StageScale: "GDS", Stage: "3", AssessedAt: at(3), Source: "assessment"},
+	})
+	target := "/patients/" + strconv.Itoa(int(patient.ID)) + "/diagnoses"
+
+	tests := []struct {
+		name   string
+		as     caller
+		query  string
+		status int
+		stages []string // stage of each entry, oldest first
+	}{
+		{"doctor", caller{id: 1, userType: "doctor"}, "", http.StatusOK, []string{"", "3", "4", "1"}},
+		{"one scale", caller{id: 1, userType: "doctor"}, "?scale=gds", http.StatusOK, []string{"3", "4"}},
+		{"the patient", caller{id: 10, userType: "patient"}, "", http.StatusOK, []string{"", "3", "4", "1"}},
+		{"their caregiver", caller{id: 20, userType: "caregiver"}, "", http.StatusOK, []string{"", "3", "4", "1"}},
+		{"another patient", caller{id: 11, userType: "patient"}, "", http.StatusForbidden, nil},
+		{"unlinked caregiver", caller{id: 21, userType: "caregiver"}, "", http.StatusForbidden, nil},
+	}
+	for _, tt := range tests {
+		w := serve(GetDiagnoses, "GET", "/patients/:id/diagnoses", target+tt.query, tt.as, nil)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		var resp struct {
+			Diagnoses []models.Diagnosis `json:"diagnoses"`
+		}
+		decode(t, w, &resp)
+		got := make([]string, len(resp.Diagnoses))
+		for i, d := range resp.Diagnoses {
+			got[i] = d.Stage
+		}
+		if strings.Join(got, ",") != strings.Join(tt.stages, ",") {
+			t.Errorf("%s: stages %v, want %v", tt.name, got, tt.stages)
+		}
+	}
+
+	// The current stage on each scale is the latest assessed, not the latest entered
+	w := serve(GetCurrentDiagnosis, "GET", "/patients/:id/diagnoses/current", target+"/current", caller{id: 1, userType: "doctor"}, nil)
+	var current struct {
+		Diagnosis models.Diagnosis            `json:"diagnosis"`
+		Stages    map[string]models.Diagnosis `json:"stages"`
+	}
+	decode(t, w, &current)
+	if current.Diagnosis.StageScale != "CDR" || current.Stages["GDS"].Stage != "4" || current.Stages["CDR"].Stage != "1" {
+		t.Errorf("current = %+v, want the CDR assessment with GDS 4 and CDR 1", current)
+	}
+	undiagnosedTarget := "/patients/" + strconv.Itoa(int(undiagnosed.ID)) + "/diagnoses/current"
+	if w := serve(GetCurrentDiagnosis, "GET", "/patients/:id/diagnoses/current", undiagnosedTarget, caller{id: 1, userType: "doctor"}, nil); w.Code != http.StatusNotFound {
+		t.Errorf("no diagnosis: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"dementicare-backend/config"
+	"dementicare-backend/interactions"
+	"dementicare-backend/models"
+	"dementicare-backend/scheduling"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DrugInteractionImportReport struct {
+	DryRun    bool                    `json:"dry_run"`
+	ValidRows int                     `json:"valid_rows"`
+	Imported  int                     `json:"imported"`
+	Errors    []interactions.RowError `json:"errors"`
+}
+
+// GetDrugInteractions lists the interaction dataset; ?drug= and ?severity=
+func GetDrugInteractions(c *gin.Context) {
+	query := config.DB.Order("drug_a asc, drug_b asc")
+	if drug := interactions.Normalize(c.Query("drug")); drug != "" {
+		query = query.Where("drug_a LIKE ? OR drug_b LIKE ?", "%"+drug+"%", "%"+drug+"%")
+	}
+	if severity := c.Query("severity"); severity != "" {
+		query = query.Where("severity = ?", severity)
+	}
+
+	var entries []models.DrugInteraction
+	if err := query.Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch drug interactions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"interactions": entries})
+}
+
+// ImportDrugInteractions loads an uploaded CSV or JSON dataset (admin only).
+// Pairs already present are updated. With ?dry_run=true the file is only
+// validated; otherwise nothing is imported if any row is invalid.
+func ImportDrugInteractions(c *gin.Context) {
+	if c.GetString("user_type") != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can import drug interactions"})
+		return
+	}
+
+	header, ok := importFile(c)
+	if !ok {
+		return
+	}
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+	if format != "csv" && format != "json" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Only .csv and .json files are supported"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	records, rowErrors, err := interactions.Parse(file, format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse file: " + err.Error()})
+		return
+	}
+
+	report := DrugInteractionImportReport{
+		DryRun:    c.Query("dry_run") == "true",
+		ValidRows: len(records),
+		Errors:    rowErrors,
+	}
+	if report.Errors == nil {
+		report.Errors = []interactions.RowError{}
+	}
+	if report.DryRun {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+	if len(report.Errors) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, report)
+		return
+	}
+
+	if err := interactions.Import(config.DB, records, header.Filename); err != nil {
+		log.Printf("Error importing drug interactions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import drug interactions"})
+		return
+	}
+
+	report.Imported = len(records)
+	log.Printf("Drug interactions imported - Count: %d, File: %s, User: %d", report.Imported, header.Filename, c.GetUint("user_id"))
+	c.JSON(http.StatusCreated, report)
+}
+
+// GetPatientInteractions checks the patient's current prescriptions against
+// each other, or a candidate ?medication= against them
+func GetPatientInteractions(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	current, err := currentPrescriptions(patient.ID, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check interactions"})
+		return
+	}
+
+	if medication := c.Query("medication"); medication != "" {
+		matches, err := findInteractions(medication, current)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check interactions"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"medication": medication, "interactions": matches})
+		return
+	}
+
+	type pairMatch struct {
+		PrescriptionID uint `json:"prescription_id"`
+		models.InteractionMatch
+	}
+	pairs := []pairMatch{}
+	for i, prescription := range current {
+		matches, err := findInteractions(prescription.Medication, current[i+1:])
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check interactions"})
+			return
+		}
+		for _, match := range matches {
+			pairs = append(pairs, pairMatch{PrescriptionID: prescription.ID, InteractionMatch: match})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"interactions": pairs})
+}
+
+// findInteractions returns the interactions of medication with the given
+// prescriptions, most severe first
+func findInteractions(medication string, prescriptions []models.Prescription) ([]models.InteractionMatch, error) {
+	names := make([]string, len(prescriptions))
+	for i, p := range prescriptions {
+		names[i] = p.Medication
+	}
+	found, err := interactions.Between(config.DB, medication, names)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []models.InteractionMatch{}
+	for i, list := range found {
+		for _, interaction := range list {
+			matches = append(matches, models.InteractionMatch{
+				PrescriptionID: prescriptions[i].ID,
+				Medication:     prescriptions[i].Medication,
+				Interaction:    interaction,
+			})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return interactions.Rank(matches[i].Interaction.Severity) > interactions.Rank(matches[j].Interaction.Severity)
+	})
+	return matches, nil
+}
+
+// currentPrescriptions returns the patient's prescriptions other than
+// excludeID, leaving out those whose schedule has ended
+func currentPrescriptions(patientID, excludeID uint) ([]models.Prescription, error) {
+	now := time.Now().In(scheduling.Location())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var prescriptions []models.Prescription
+	err := config.DB.Where("patient_id = ? AND id <> ?", patientID, excludeID).
+		Where("id NOT IN (?)", config.DB.Model(&models.MedicationSchedule{}).Select("prescription_id").Where("end_date < ?", today)).
+		Order("id asc").Find(&prescriptions).Error
+	return prescriptions, err
+}
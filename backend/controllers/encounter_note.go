@@ -0,0 +1,314 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"dementicare-backend/config"
+	"dementicare-backend/models"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type EncounterNoteRequest struct {
+	Subjective *string `json:"subjective"`
+	Objective  *string `json:"objective"`
+	Assessment *string `json:"assessment"`
+	Plan       *string `json:"plan"`
+	Summary    *string `json:"summary"`
+}
+
+// EncounterSummary is what patients see of a signed note
+type EncounterSummary struct {
+	AppointmentID uint       `json:"appointment_id"`
+	DoctorID      uint       `json:"doctor_id"`
+	Summary       string     `json:"summary"`
+	SignedAt      *time.Time `json:"signed_at"`
+}
+
+// GetEncounterNote returns the note of an appointment. Doctors, admins and
+// the patient's caregivers see the full signed note; patients only its
+// summary. Drafts are visible to their author only.
+func GetEncounterNote(c *gin.Context) {
+	appointment, full, ok := findAppointmentForNote(c)
+	if !ok {
+		return
+	}
+
+	var note models.EncounterNote
+	if err := config.DB.Preload("Addenda", func(db *gorm.DB) *gorm.DB { return db.Order("created_at asc") }).
+		Where("appointment_id = ?", appointment.ID).First(&note).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "This appointment has no encounter note"})
+		return
+	}
+	if note.Status != "signed" && note.DoctorID != c.GetUint("user_id") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "This appointment has no encounter note"})
+		return
+	}
+
+	if !full {
+		c.JSON(http.StatusOK, encounterSummary(note))
+		return
+	}
+	c.JSON(http.StatusOK, note)
+}
+
+// GetPatientEncounterNotes lists the signed notes of a patient's
+// appointments, as summaries for the patient themselves
+func GetPatientEncounterNotes(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if patient.UserID == 0 {
+		c.JSON(http.StatusOK, gin.H{"notes": []models.EncounterNote{}})
+		return
+	}
+
+	var notes []models.EncounterNote
+	err := config.DB.Preload("Addenda", func(db *gorm.DB) *gorm.DB { return db.Order("created_at asc") }).
+		Where("status = ? AND appointment_id IN (?)", "signed",
+			config.DB.Model(&models.Appointment{}).Select("id").Where("patient_id = ?", patient.UserID)).
+		Order("signed_at desc").Find(&notes).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch encounter notes"})
+		return
+	}
+
+	if c.GetString("user_type") == "patient" {
+		summaries := make([]EncounterSummary, len(notes))
+		for i, note := range notes {
+			summaries[i] = encounterSummary(note)
+		}
+		c.JSON(http.StatusOK, gin.H{"notes": summaries})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"notes": notes})
+}
+
+// SaveEncounterNote creates or edits the draft note of a completed
+// appointment (the appointment's doctor only)
+func SaveEncounterNote(c *gin.Context) {
+	appointment, _, ok := findAppointmentForAction(c, "doctor")
+	if !ok {
+		return
+	}
+	if appointment.Status != "completed" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Encounter notes can only be written for completed appointments"})
+		return
+	}
+
+	var req EncounterNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var note models.EncounterNote
+	result := config.DB.Where("appointment_id = ?", appointment.ID).Limit(1).Find(&note)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save encounter note"})
+		return
+	}
+	if note.Status == "signed" {
+		c.JSON(http.StatusConflict, gin.H{"error": "The note is signed and locked; add an addendum instead"})
+		return
+	}
+
+	applyEncounterSections(&note, req)
+	status := http.StatusOK
+	if result.RowsAffected == 0 {
+		note.AppointmentID = appointment.ID
+		note.DoctorID = appointment.DoctorID
+		note.Status = "draft"
+		if err := config.DB.Create(&note).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save encounter note"})
+			return
+		}
+		status = http.StatusCreated
+	} else {
+		// Guard on draft so a note signed in the meantime is not changed
+		result := config.DB.Model(&models.EncounterNote{}).Where("id = ? AND status = ?", note.ID, "draft").
+			Updates(map[string]interface{}{
+				"subjective": note.Subjective,
+				"objective":  note.Objective,
+				"assessment": note.Assessment,
+				"plan":       note.Plan,
+				"summary":    note.Summary,
+			})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save encounter note"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "The note is signed and locked; add an addendum instead"})
+			return
+		}
+	}
+
+	c.JSON(status, note)
+}
+
+// SignEncounterNote finalizes the note. It cannot be edited afterwards.
+func SignEncounterNote(c *gin.Context) {
+	appointment, _, ok := findAppointmentForAction(c, "doctor")
+	if !ok {
+		return
+	}
+
+	// The note is read, hashed and signed under a row lock, so an edit saved
+	// concurrently is either in the signed content or rejected
+	now := time.Now()
+	userID := c.GetUint("user_id")
+	var note models.EncounterNote
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("appointment_id = ?", appointment.ID).First(&note).Error; err != nil {
+			return err
+		}
+		if note.Status == "signed" {
+			return errNoteSigned
+		}
+		if strings.TrimSpace(note.Assessment) == "" || strings.TrimSpace(note.Plan) == "" || strings.TrimSpace(note.Summary) == "" {
+			return errNoteIncomplete
+		}
+
+		hash := encounterHash(note)
+		note.Status, note.SignedBy, note.SignedAt, note.ContentHash = "signed", userID, &now, hash
+		return tx.Model(&models.EncounterNote{}).Where("id = ?", note.ID).
+			Updates(map[string]interface{}{"status": note.Status, "signed_by": userID, "signed_at": now, "content_hash": note.ContentHash}).Error
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "This appointment has no encounter note"})
+		return
+	case errors.Is(err, errNoteSigned):
+		c.JSON(http.StatusConflict, gin.H{"error": "The note is already signed"})
+		return
+	case errors.Is(err, errNoteIncomplete):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Assessment, plan and summary are required before signing"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign encounter note"})
+		return
+	}
+
+	log.Printf("Encounter note signed - ID: %d, Appointment: %d, Doctor: %d", note.ID, appointment.ID, userID)
+	c.JSON(http.StatusOK, note)
+}
+
+// AddEncounterAddendum appends a correction or late entry to a signed note
+func AddEncounterAddendum(c *gin.Context) {
+	appointment, _, ok := findAppointmentForAction(c, "doctor")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Content string `json:"content" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Content = strings.TrimSpace(req.Content)
+	if req.Content == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Content is required"})
+		return
+	}
+
+	var note models.EncounterNote
+	if err := config.DB.Where("appointment_id = ?", appointment.ID).First(&note).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "This appointment has no encounter note"})
+		return
+	}
+	if note.Status != "signed" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Edit the draft directly; addenda are for signed notes"})
+		return
+	}
+
+	addendum := models.EncounterAddendum{
+		NoteID:   note.ID,
+		AuthorID: c.GetUint("user_id"),
+		Content:  req.Content,
+	}
+	if err := config.DB.Create(&addendum).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add addendum"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, addendum)
+}
+
+var (
+	errNoteSigned     = errors.New("encounter note already signed")
+	errNoteIncomplete = errors.New("encounter note incomplete")
+)
+
+// findAppointmentForNote loads the appointment and reports whether the
+// caller may read the full note (doctors, admins and the patient's
+// caregivers) or only its summary (the patient).
+func findAppointmentForNote(c *gin.Context) (models.Appointment, bool, bool) {
+	var appointment models.Appointment
+	if err := config.DB.First(&appointment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
+		return appointment, false, false
+	}
+
+	switch c.GetString("user_type") {
+	case "doctor", "admin":
+		return appointment, true, true
+	}
+	switch appointmentRole(c, appointment) {
+	case "caregiver":
+		return appointment, true, true
+	case "patient":
+		return appointment, false, true
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this appointment"})
+	return appointment, false, false
+}
+
+func applyEncounterSections(note *models.EncounterNote, req EncounterNoteRequest) {
+	if req.Subjective != nil {
+		note.Subjective = *req.Subjective
+	}
+	if req.Objective != nil {
+		note.Objective = *req.Objective
+	}
+	if req.Assessment != nil {
+		note.Assessment = *req.Assessment
+	}
+	if req.Plan != nil {
+		note.Plan = *req.Plan
+	}
+	if req.Summary != nil {
+		note.Summary = *req.Summary
+	}
+}
+
+func encounterSummary(note models.EncounterNote) EncounterSummary {
+	return EncounterSummary{
+		AppointmentID: note.AppointmentID,
+		DoctorID:      note.DoctorID,
+		Summary:       note.Summary,
+		SignedAt:      note.SignedAt,
+	}
+}
+
+// encounterHash fingerprints the signed content so later tampering in the
+// database can be detected
+func encounterHash(note models.EncounterNote) string {
+	hash := sha256.New()
+	for _, section := range []string{note.Subjective, note.Objective, note.Assessment, note.Plan, note.Summary} {
+		hash.Write([]byte(section))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"dementicare-backend/models"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEncounterNoteLifecycle(t *testing.T) {
+	db := useTestDB(t)
+	patient := models.Patient{UserID: 10, Name: "Edith", CaregiverID: 20}
+	db.Create(&patient)
+	start := time.Now().Add(-time.Hour)
+	appointment := models.Appointment{PatientID: 10, DoctorID: 1, StartAt: start, EndAt: start.Add(30 * time.Minute), Status: "completed"}
+	db.Create(&appointment)
+	pending := models.Appointment{PatientID: 10, DoctorID: 1, StartAt: start.AddDate(0, 0, 1), EndAt: start.AddDate(0, 0, 1).Add(30 * time.Minute), Status: "pending"}
+	db.Create(&pending)
+	noteTarget := "/appointments/" + strconv.Itoa(int(appointment.ID)) + "/note"
+	asDoctor := caller{id: 1, userType: "doctor"}
+	text := func(s string) *string { return &s }
+	save := func(as caller, target string, req EncounterNoteRequest) int {
+		return serve(SaveEncounterNote, "PUT", "/appointments/:id/note", target, as, req).Code
+	}
+	sign := func() int {
+		return serve(SignEncounterNote, "POST", "/appointments/:id/note/sign", noteTarget+"/sign", asDoctor, nil).Code
+	}
+	addendum := func(content string) int {
+		body := map[string]string{"content": content}
+		return serve(AddEncounterAddendum, "POST", "/appointments/:id/note/addenda", noteTarget+"/addenda", asDoctor, body).Code
+	}
+	get := func(as caller) (int, map[string]interface{}) {
+		w := serve(GetEncounterNote, "GET", "/appointments/:id/note", noteTarget, as, nil)
+		var resp map[string]interface{}
+		if w.Code == http.StatusOK {
+			decode(t, w, &resp)
+		}
+		return w.Code, resp
+	}
+
+	// Only the appointment's doctor writes notes, and only once it is completed
+	pendingTarget := "/appointments/" + strconv.Itoa(int(pending.ID)) + "/note"
+	if status := save(asDoctor, pendingTarget, EncounterNoteRequest{Subjective: text("Confused")}); status != http.StatusConflict {
+		t.Errorf("note on a pending appointment: status = %d, want %d", status, http.StatusConflict)
+	}
+	if status := save(caller{id: 2, userType: "doctor"}, noteTarget, EncounterNoteRequest{Subjective: text("Confused")}); status != http.StatusForbidden {
+		t.Errorf("other doctor's note: status = %d, want %d", status, http.StatusForbidden)
+	}
+	if status := save(asDoctor, noteTarget, EncounterNoteRequest{Subjective: text("Confused in the evenings")}); status != http.StatusCreated {
+		t.Fatalf("new draft: status = %d, want %d", status, http.StatusCreated)
+	}
+
+	// Drafts are hidden from everyone but their author
+	if status, _ := get(caller{id: 10, userType: "patient"}); status != http.StatusNotFound {
+		t.Errorf("patient reading a draft: status = %d, want %d", status, http.StatusNotFound)
+	}
+	if status := addendum("Late entry"); status != http.StatusConflict {
+		t.Errorf("addendum to a draft: status = %d, want %d", status, http.StatusConflict)
+	}
+	if status := sign(); status != http.StatusBadRequest {
+		t.Errorf("signing an incomplete note: status = %d, want %d", status, http.StatusBadRequest)
+	}
+
+	// Sections not sent are kept
+	sections := EncounterNoteRequest{Assessment: text("Moderate dementia"), Plan: text("Review in 3 months"), Summary: text("Your memory is stable")}
+	if status := save(asDoctor, noteTarget, sections); status != http.StatusOK {
+		t.Fatalf("editing the draft: status = %d, want %d", status, http.StatusOK)
+	}
+	if status := sign(); status != http.StatusOK {
+		t.Fatalf("signing: status = %d, want %d", status, http.StatusOK)
+	}
+	var note models.EncounterNote
+	db.Where("appointment_id = ?", appointment.ID).First(&note)
+	if note.Status != "signed" || note.Subjective != "Confused in the evenings" || note.SignedBy != 1 || note.ContentHash != encounterHash(note) {
+		t.Errorf("signed note %+v, want it signed by the doctor with every section and its hash", note)
+	}
+
+	// A signed note is locked; corrections go in addenda
+	if status := save(asDoctor, noteTarget, EncounterNoteRequest{Plan: text("Discharge")}); status != http.StatusConflict {
+		t.Errorf("editing a signed note: status = %d, want %d", status, http.StatusConflict)
+	}
+	if status := sign(); status != http.StatusConflict {
+		t.Errorf("signing twice: status = %d, want %d", status, http.StatusConflict)
+	}
+	if status := addendum("  "); status != http.StatusBadRequest {
+		t.Errorf("blank addendum: status = %d, want %d", status, http.StatusBadRequest)
+	}
+	if status := addendum("Daughter reports better sleep"); status != http.StatusCreated {
+		t.Errorf("addendum: status = %d, want %d", status, http.StatusCreated)
+	}
+
+	// The patient sees only the summary; their caregiver the full note
+	tests := []struct {
+		name   string
+		as     caller
+		status int
+		full   bool
+	}{
+		{"patient", caller{id: 10, userType: "patient"}, http.StatusOK, false},
+		{"caregiver", caller{id: 20, userType: "caregiver"}, http.StatusOK, true},
+		{"other doctor", caller{id: 2, userType: "doctor"}, http.StatusOK, true},
+		{"other patient", caller{id: 11, userType: "patient"}, http.StatusForbidden, false},
+		{"unlinked caregiver", caller{id: 21, userType: "caregiver"}, http.StatusForbidden, false},
+	}
+	for _, tt := range tests {
+		status, resp := get(tt.as)
+		if status != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, status, tt.status)
+			continue
+		}
+		if status != http.StatusOK {
+			continue
+		}
+		_, hasAssessment := resp["assessment"]
+		addenda, _ := resp["addenda"].([]interface{})
+		if hasAssessment != tt.full || resp["summary"] != "Your memory is stable" || (tt.full && len(addenda) != 1) {
+			t.Errorf("%s: got %v, want full note %v", tt.name, resp, tt.full)
+		}
+	}
+
+	w := serve(GetPatientEncounterNotes, "GET", "/patients/:id/encounter-notes", "/patients/"+strconv.Itoa(int(patient.ID))+"/encounter-notes", caller{id: 10, userType: "patient"}, nil)
+	var list struct {
+		Notes []map[string]interface{} `json:"notes"`
+	}
+	decode(t, w, &list)
+	if len(list.Notes) != 1 || list.Notes[0]["summary"] != "Your memory is stable" || list.Notes[0]["plan"] != nil {
+		t.Errorf("patient's notes %v, want the one summary", list.Notes)
+	}
+}
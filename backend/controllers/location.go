@@ -0,0 +1,383 @@
+package controllers
+
+import (
+	"dementicare-backend/config"
+	"dementicare-backend/models"
+	"dementicare-backend/notify"
+	"dementicare-backend/scheduling"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	earthRadiusMeters             = 6371000
+	defaultLocationRetentionDays  = 30
+	maxLocationHistoryResultCount = 1000
+)
+
+type LocationPingRequest struct {
+	Latitude   *float64  `json:"latitude" binding:"required"`
+	Longitude  *float64  `json:"longitude" binding:"required"`
+	Accuracy   float64   `json:"accuracy"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+func locationRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("LOCATION_RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		days = defaultLocationRetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func GetSafeZones(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var zones []models.SafeZone
+	if err := config.DB.Where("patient_id = ?", patient.ID).Find(&zones).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch safe zones"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"safe_zones": zones})
+}
+
+// CreateSafeZone adds a zone for the patient (caregivers, care-team members,
+// doctors and admins)
+func CreateSafeZone(c *gin.Context) {
+	patient, ok := findManagedPatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	zone := models.SafeZone{Active: true}
+	if err := c.ShouldBindJSON(&zone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	zone.ID = 0
+	zone.PatientID = patient.ID
+	zone.CreatedBy = c.GetUint("user_id")
+
+	if msg := validateSafeZone(zone); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := config.DB.Create(&zone).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create safe zone"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, zone)
+}
+
+func UpdateSafeZone(c *gin.Context) {
+	var zone models.SafeZone
+	if err := config.DB.First(&zone, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Safe zone not found"})
+		return
+	}
+
+	if _, ok := findManagedPatient(c, zone.PatientID); !ok {
+		return
+	}
+
+	id, patientID := zone.ID, zone.PatientID
+	if err := c.ShouldBindJSON(&zone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	zone.ID, zone.PatientID = id, patientID
+
+	if msg := validateSafeZone(zone); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := config.DB.Save(&zone).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update safe zone"})
+		return
+	}
+
+	c.JSON(http.StatusOK, zone)
+}
+
+func DeleteSafeZone(c *gin.Context) {
+	var zone models.SafeZone
+	if err := config.DB.First(&zone, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Safe zone not found"})
+		return
+	}
+
+	if _, ok := findManagedPatient(c, zone.PatientID); !ok {
+		return
+	}
+
+	if err := config.DB.Delete(&zone).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete safe zone"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Safe zone deleted successfully"})
+}
+
+// RecordLocation stores a ping from the patient's phone or tracker and checks
+// it against the patient's active safe zones. An alert is raised for every
+// zone the patient was inside at the previous ping and is now outside of, and
+// the patient's caregivers and care team are notified.
+func RecordLocation(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req LocationPingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	point := models.GeoPoint{Latitude: *req.Latitude, Longitude: *req.Longitude}
+	if !validCoordinates(point) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid coordinates"})
+		return
+	}
+	if req.RecordedAt.IsZero() {
+		req.RecordedAt = time.Now()
+	}
+
+	var previous models.LocationPing
+	hasPrevious := config.DB.Where("patient_id = ? AND recorded_at <= ?", patient.ID, req.RecordedAt).
+		Order("recorded_at desc").Limit(1).Find(&previous).RowsAffected > 0
+
+	ping := models.LocationPing{
+		PatientID:  patient.ID,
+		Latitude:   point.Latitude,
+		Longitude:  point.Longitude,
+		Accuracy:   req.Accuracy,
+		RecordedAt: req.RecordedAt,
+	}
+	if err := config.DB.Create(&ping).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save location"})
+		return
+	}
+
+	var zones []models.SafeZone
+	if err := config.DB.Where("patient_id = ? AND active = ?", patient.ID, true).Find(&zones).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to evaluate safe zones"})
+		return
+	}
+
+	alerts := []models.LocationAlert{}
+	zoneNames := map[uint]string{}
+	for _, zone := range zones {
+		if zoneContains(zone, point) {
+			continue
+		}
+		// Without an earlier ping the patient is assumed to have started inside
+		if hasPrevious && !zoneContains(zone, models.GeoPoint{Latitude: previous.Latitude, Longitude: previous.Longitude}) {
+			continue
+		}
+		alerts = append(alerts, models.LocationAlert{
+			PatientID:  patient.ID,
+			SafeZoneID: zone.ID,
+			PingID:     ping.ID,
+			Type:       "left_zone",
+			Latitude:   point.Latitude,
+			Longitude:  point.Longitude,
+			Status:     "open",
+		})
+		zoneNames[zone.ID] = zone.Name
+	}
+
+	if len(alerts) > 0 {
+		if err := config.DB.Create(&alerts).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create location alerts"})
+			return
+		}
+		log.Printf("Patient %d left %d safe zone(s)", patient.ID, len(alerts))
+		notifyLocationAlerts(patient, alerts, zoneNames)
+	}
+
+	cutoff := time.Now().Add(-locationRetention())
+	if err := config.DB.Where("patient_id = ? AND recorded_at < ?", patient.ID, cutoff).Delete(&models.LocationPing{}).Error; err != nil {
+		log.Printf("Error pruning location history for patient %d: %v", patient.ID, err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"ping": ping, "alerts": alerts})
+}
+
+func GetLocationHistory(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	query := config.DB.Where("patient_id = ?", patient.ID)
+	if from, err := time.Parse(time.RFC3339, c.Query("from")); err == nil {
+		query = query.Where("recorded_at >= ?", from)
+	}
+	if to, err := time.Parse(time.RFC3339, c.Query("to")); err == nil {
+		query = query.Where("recorded_at <= ?", to)
+	}
+
+	var pings []models.LocationPing
+	if err := query.Order("recorded_at desc").Limit(maxLocationHistoryResultCount).Find(&pings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch location history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"locations": pings})
+}
+
+// GetLocationAlerts lists alerts for the patients the caller can see
+func GetLocationAlerts(c *gin.Context) {
+	query, ok := visiblePatientScope(c, config.DB.Model(&models.LocationAlert{}).Order("created_at desc"))
+	if !ok {
+		return
+	}
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if patientID := c.Query("patient_id"); patientID != "" {
+		query = query.Where("patient_id = ?", patientID)
+	}
+
+	var alerts []models.LocationAlert
+	if err := query.Find(&alerts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch location alerts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"alerts": alerts})
+}
+
+// AcknowledgeLocationAlert marks an alert handled (caregivers, care-team
+// members, doctors and admins)
+func AcknowledgeLocationAlert(c *gin.Context) {
+	var alert models.LocationAlert
+	if err := config.DB.First(&alert, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return
+	}
+
+	if _, ok := findManagedPatient(c, alert.PatientID); !ok {
+		return
+	}
+
+	if alert.Status != "acknowledged" {
+		now := time.Now()
+		alert.Status = "acknowledged"
+		alert.AcknowledgedBy = c.GetUint("user_id")
+		alert.AcknowledgedAt = &now
+		if err := config.DB.Save(&alert).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to acknowledge alert"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, alert)
+}
+
+func notifyLocationAlerts(patient models.Patient, alerts []models.LocationAlert, zoneNames map[uint]string) {
+	recipients, err := notify.CaregiverRecipients(config.DB, patient.ID)
+	if err != nil {
+		log.Printf("Error finding caregivers of patient %d: %v", patient.ID, err)
+		return
+	}
+
+	for _, alert := range alerts {
+		when := alert.CreatedAt.In(scheduling.Location()).Format("15:04 on Monday 2 January")
+		for _, recipientID := range recipients {
+			err := notify.Enqueue(config.DB, models.Notification{
+				RecipientID: recipientID,
+				Kind:        "location_alert",
+				Subject:     patient.Name + " has left a safe zone",
+				Body: fmt.Sprintf("%s left the safe zone %q at %s, last seen at %.5f, %.5f.",
+					patient.Name, zoneNames[alert.SafeZoneID], when, alert.Latitude, alert.Longitude),
+				DedupeKey: fmt.Sprintf("location-alert:%d:%d", alert.ID, recipientID),
+			})
+			if err != nil {
+				log.Printf("Error queueing location alert notification for user %d: %v", recipientID, err)
+			}
+		}
+	}
+}
+
+func validateSafeZone(zone models.SafeZone) string {
+	if zone.Name == "" {
+		return "Zone name is required"
+	}
+
+	switch zone.Shape {
+	case "circle":
+		if !validCoordinates(models.GeoPoint{Latitude: zone.CenterLat, Longitude: zone.CenterLng}) {
+			return "Invalid zone center"
+		}
+		if zone.RadiusMeters <= 0 {
+			return "Circle zones need a positive radius_meters"
+		}
+	case "polygon":
+		if len(zone.Polygon) < 3 {
+			return "Polygon zones need at least 3 points"
+		}
+		for _, p := range zone.Polygon {
+			if !validCoordinates(p) {
+				return "Invalid polygon point"
+			}
+		}
+	default:
+		return "Shape must be 'circle' or 'polygon'"
+	}
+
+	return ""
+}
+
+func validCoordinates(p models.GeoPoint) bool {
+	return p.Latitude >= -90 && p.Latitude <= 90 && p.Longitude >= -180 && p.Longitude <= 180
+}
+
+func zoneContains(zone models.SafeZone, p models.GeoPoint) bool {
+	if zone.Shape == "polygon" {
+		return polygonContains(zone.Polygon, p)
+	}
+	center := models.GeoPoint{Latitude: zone.CenterLat, Longitude: zone.CenterLng}
+	return haversineMeters(center, p) <= zone.RadiusMeters
+}
+
+func haversineMeters(a, b models.GeoPoint) float64 {
+	lat1 := a.Latitude * math.Pi / 180
+	lat2 := b.Latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLng := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(h))
+}
+
+// polygonContains uses ray casting on raw coordinates, which is accurate
+// enough for neighbourhood-sized zones away from the antimeridian.
+func polygonContains(polygon []models.GeoPoint, p models.GeoPoint) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+		if (a.Latitude > p.Latitude) != (b.Latitude > p.Latitude) &&
+			p.Longitude < (b.Longitude-a.Longitude)*(p.Latitude-a.Latitude)/(b.Latitude-a.Latitude)+a.Longitude {
+			inside = !inside
+		}
+	}
+	return inside
+}
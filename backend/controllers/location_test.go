@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"dementicare-backend/models"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestPolygonContains(t *testing.T) {
+	square := []models.GeoPoint{
+		{Latitude: 51.50, Longitude: -0.13},
+		{Latitude: 51.50, Longitude: -0.11},
+		{Latitude: 51.52, Longitude: -0.11},
+		{Latitude: 51.52, Longitude: -0.13},
+	}
+	// An L shape whose notch is outside
+	lShape := []models.GeoPoint{
+		{Latitude: 0, Longitude: 0},
+		{Latitude: 0, Longitude: 2},
+		{Latitude: 1, Longitude: 2},
+		{Latitude: 1, Longitude: 1},
+		{Latitude: 2, Longitude: 1},
+		{Latitude: 2, Longitude: 0},
+	}
+	tests := []struct {
+		name    string
+		polygon []models.GeoPoint
+		point   models.GeoPoint
+		want    bool
+	}{
+		{"inside the square", square, models.GeoPoint{Latitude: 51.51, Longitude: -0.12}, true},
+		{"north of the square", square, models.GeoPoint{Latitude: 51.53, Longitude: -0.12}, false},
+		{"west of the square", square, models.GeoPoint{Latitude: 51.51, Longitude: -0.14}, false},
+		{"inside the L", lShape, models.GeoPoint{Latitude: 1.5, Longitude: 0.5}, true},
+		{"in the notch of the L", lShape, models.GeoPoint{Latitude: 1.5, Longitude: 1.5}, false},
+		{"too few points", square[:2], models.GeoPoint{Latitude: 51.51, Longitude: -0.12}, false},
+	}
+	for _, tt := range tests {
+		if got := polygonContains(tt.polygon, tt.point); got != tt.want {
+			t.Errorf("%s: polygonContains() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSafeZoneAccess(t *testing.T) {
+	db := useTestDB(t)
+	patient := models.Patient{UserID: 10, Name: "Edith", CaregiverID: 20}
+	db.Create(&patient)
+	db.Create(&models.CareTeamMember{PatientID: patient.ID, UserID: 30})
+	home := map[string]interface{}{"name": "Home", "shape": "circle", "center_lat": 51.5, "center_lng": -0.12, "radius_meters": 300}
+	target := "/patients/" + strconv.Itoa(int(patient.ID)) + "/safe-zones"
+
+	tests := []struct {
+		name   string
+		as     caller
+		status int
+	}{
+		{"patient", caller{id: 10, userType: "patient"}, http.StatusForbidden},
+		{"unrelated caregiver", caller{id: 21, userType: "caregiver"}, http.StatusForbidden},
+		{"primary caregiver", caller{id: 20, userType: "caregiver"}, http.StatusCreated},
+		{"care-team member", caller{id: 30, userType: "caregiver"}, http.StatusCreated},
+		{"doctor", caller{id: 1, userType: "doctor"}, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		w := serve(CreateSafeZone, "POST", "/patients/:id/safe-zones", target, tt.as, home)
+		if w.Code != tt.status {
+			t.Errorf("%s: create status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+		}
+	}
+
+	var zone models.SafeZone
+	db.First(&zone)
+	zoneTarget := "/safe-zones/" + strconv.Itoa(int(zone.ID))
+	asPatient := caller{id: 10, userType: "patient"}
+	if w := serve(UpdateSafeZone, "PUT", "/safe-zones/:id", zoneTarget, asPatient, home); w.Code != http.StatusForbidden {
+		t.Errorf("patient update status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := serve(DeleteSafeZone, "DELETE", "/safe-zones/:id", zoneTarget, asPatient, nil); w.Code != http.StatusForbidden {
+		t.Errorf("patient delete status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := serve(GetSafeZones, "GET", "/patients/:id/safe-zones", target, asPatient, nil); w.Code != http.StatusOK {
+		t.Errorf("patient list status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestRecordLocationAlerts(t *testing.T) {
+	db := useTestDB(t)
+	patient := models.Patient{UserID: 10, Name: "Edith", CaregiverID: 20}
+	db.Create(&patient)
+	db.Create(&models.CareTeamMember{PatientID: patient.ID, UserID: 30})
+	db.Create(&models.SafeZone{PatientID: patient.ID, Name: "Home", Shape: "circle", CenterLat: 51.5, CenterLng: -0.12, RadiusMeters: 300, Active: true})
+
+	asPatient := caller{id: 10, userType: "patient"}
+	target := "/patients/" + strconv.Itoa(int(patient.ID)) + "/locations"
+	ping := func(lat float64) {
+		w := serve(RecordLocation, "POST", "/patients/:id/locations", target, asPatient, map[string]float64{"latitude": lat, "longitude": -0.12})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("ping status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+		}
+	}
+	ping(51.5)
+	ping(51.6) // about 11 km north
+	ping(51.61)
+
+	var alerts []models.LocationAlert
+	db.Find(&alerts)
+	if len(alerts) != 1 {
+		t.Fatalf("got %d alerts, want 1 for leaving the zone once", len(alerts))
+	}
+	var recipients []uint
+	db.Model(&models.Notification{}).Where("kind = ?", "location_alert").Order("recipient_id").Pluck("recipient_id", &recipients)
+	if !reflect.DeepEqual(recipients, []uint{20, 30}) {
+		t.Errorf("notified users %v, want the caregiver and care team [20 30]", recipients)
+	}
+
+	alertTarget := "/location-alerts/" + strconv.Itoa(int(alerts[0].ID)) + "/acknowledge"
+	if w := serve(AcknowledgeLocationAlert, "POST", "/location-alerts/:id/acknowledge", alertTarget, asPatient, nil); w.Code != http.StatusForbidden {
+		t.Errorf("patient acknowledge status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := serve(AcknowledgeLocationAlert, "POST", "/location-alerts/:id/acknowledge", alertTarget, caller{id: 30, userType: "caregiver"}, nil); w.Code != http.StatusOK {
+		t.Errorf("care-team acknowledge status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,403 @@
+package controllers
+
+import (
+	"dementicare-backend/config"
+	"dementicare-backend/medication"
+	"dementicare-backend/models"
+	"dementicare-backend/scheduling"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MedicationScheduleRequest replaces a prescription's schedule
+type MedicationScheduleRequest struct {
+	Times     string     `json:"times"`    // e.g. "08:00,20:00"
+	Weekdays  string     `json:"weekdays"` // e.g. "1,3,5"; empty for every day
+	AsNeeded  bool       `json:"as_needed"`
+	StartDate *time.Time `json:"start_date"`
+	EndDate   *time.Time `json:"end_date"`
+}
+
+type MedicationAdministrationRequest struct {
+	ScheduleID     uint       `json:"schedule_id" binding:"required"`
+	DueAt          *time.Time `json:"due_at"` // the scheduled dose; omit for as-needed doses
+	Status         string     `json:"status" binding:"required"`
+	AdministeredAt *time.Time `json:"administered_at"`
+	Notes          string     `json:"notes"`
+}
+
+// MedicationDose is one scheduled dose of the day with what was recorded
+// for it
+type MedicationDose struct {
+	ScheduleID     uint                             `json:"schedule_id"`
+	PrescriptionID uint                             `json:"prescription_id"`
+	Medication     string                           `json:"medication"`
+	Dosage         string                           `json:"dosage"`
+	DueAt          time.Time                        `json:"due_at"`
+	Status         string                           `json:"status"` // due, or the recorded status
+	Administration *models.MedicationAdministration `json:"administration"`
+}
+
+func GetPrescriptionSchedule(c *gin.Context) {
+	prescription, ok := findPrescriptionForSchedule(c)
+	if !ok {
+		return
+	}
+
+	var schedule models.MedicationSchedule
+	if err := config.DB.Where("prescription_id = ?", prescription.ID).First(&schedule).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "This prescription has no schedule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// UpdatePrescriptionSchedule sets a prescription's schedule explicitly
+// (doctors and admins). It is no longer re-derived from the prescription
+// text afterwards.
+func UpdatePrescriptionSchedule(c *gin.Context) {
+	if userType := c.GetString("user_type"); userType != "doctor" && userType != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only doctors can change medication schedules"})
+		return
+	}
+	prescription, ok := findPrescriptionForSchedule(c)
+	if !ok {
+		return
+	}
+
+	var req MedicationScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var schedule models.MedicationSchedule
+	result := config.DB.Where("prescription_id = ?", prescription.ID).Limit(1).Find(&schedule)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save schedule"})
+		return
+	}
+
+	before := schedule
+	schedule.PrescriptionID = prescription.ID
+	schedule.PatientID = prescription.PatientID
+	schedule.Times = req.Times
+	schedule.Weekdays = req.Weekdays
+	schedule.AsNeeded = req.AsNeeded
+	schedule.EndDate = req.EndDate
+	schedule.Derived = false
+	if req.StartDate != nil {
+		schedule.StartDate = *req.StartDate
+	} else if schedule.StartDate.IsZero() {
+		now := time.Now().In(scheduling.Location())
+		schedule.StartDate = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	if err := medication.Validate(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		schedule.CreatedBy = c.GetUint("user_id")
+	}
+	if result.RowsAffected == 0 || medication.DosesChanged(before, schedule) {
+		schedule.EffectiveFrom = time.Now()
+	}
+	if err := config.DB.Save(&schedule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save schedule"})
+		return
+	}
+
+	log.Printf("Medication schedule set - Prescription: %d, Times: %s, By: %d", prescription.ID, schedule.Times, c.GetUint("user_id"))
+	c.JSON(http.StatusOK, schedule)
+}
+
+// GetMedicationSchedules lists the schedules of the patient's current
+// prescriptions
+func GetMedicationSchedules(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	schedules, err := activeSchedules(patient.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch medication schedules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"schedules": schedules})
+}
+
+// GetMedicationDoses returns the patient's doses for one day (date as
+// YYYY-MM-DD in clinic time, default today) with what was recorded for each,
+// and the as-needed doses given that day
+func GetMedicationDoses(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	loc := scheduling.Location()
+	now := time.Now().In(loc)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if value := c.Query("date"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+			return
+		}
+		day = parsed
+	}
+	from, to := day, day.AddDate(0, 0, 1)
+
+	schedules, err := activeSchedules(patient.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch medication doses"})
+		return
+	}
+
+	var recorded []models.MedicationAdministration
+	if err := config.DB.Where("patient_id = ? AND ((due_at >= ? AND due_at < ?) OR (due_at IS NULL AND administered_at >= ? AND administered_at < ?))",
+		patient.ID, from, to, from, to).Find(&recorded).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch medication doses"})
+		return
+	}
+
+	byDose := make(map[uint]map[int64]models.MedicationAdministration)
+	asNeeded := []models.MedicationAdministration{}
+	for _, r := range recorded {
+		if r.DueAt == nil {
+			asNeeded = append(asNeeded, r)
+			continue
+		}
+		if byDose[r.ScheduleID] == nil {
+			byDose[r.ScheduleID] = make(map[int64]models.MedicationAdministration)
+		}
+		byDose[r.ScheduleID][r.DueAt.Unix()] = r
+	}
+
+	doses := []MedicationDose{}
+	for _, schedule := range schedules {
+		for _, due := range medication.DueTimes(schedule, from, to) {
+			dose := MedicationDose{
+				ScheduleID:     schedule.ID,
+				PrescriptionID: schedule.PrescriptionID,
+				DueAt:          due,
+				Status:         "due",
+			}
+			if schedule.Prescription != nil {
+				dose.Medication = schedule.Prescription.Medication
+				dose.Dosage = schedule.Prescription.Dosage
+			}
+			if r, ok := byDose[schedule.ID][due.Unix()]; ok {
+				r := r
+				dose.Status = r.Status
+				dose.Administration = &r
+			}
+			doses = append(doses, dose)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"date": day.Format("2006-01-02"), "doses": doses, "as_needed": asNeeded})
+}
+
+// RecordMedicationAdministration marks a dose given, skipped or refused
+// (caregivers, care-team members, doctors and admins). A reason is required
+// for skipped and refused doses. A dose the worker already marked missed can
+// still be recorded late.
+func RecordMedicationAdministration(c *gin.Context) {
+	if c.GetString("user_type") == "patient" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Doses are recorded by caregivers and clinicians"})
+		return
+	}
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req MedicationAdministrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Notes = strings.TrimSpace(req.Notes)
+	switch req.Status {
+	case "given":
+	case "skipped", "refused":
+		if req.Notes == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "notes are required for skipped and refused doses"})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be given, skipped or refused"})
+		return
+	}
+
+	var schedule models.MedicationSchedule
+	if err := config.DB.Where("patient_id = ? AND prescription_id IN (?)", patient.ID,
+		config.DB.Model(&models.Prescription{}).Select("id")).First(&schedule, req.ScheduleID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Medication schedule not found"})
+		return
+	}
+
+	now := time.Now()
+	if req.AdministeredAt == nil && req.Status == "given" {
+		req.AdministeredAt = &now
+	}
+	if req.AdministeredAt != nil && req.AdministeredAt.After(now.Add(time.Minute)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "administered_at cannot be in the future"})
+		return
+	}
+
+	if schedule.AsNeeded {
+		req.DueAt = nil
+		if req.AdministeredAt == nil {
+			req.AdministeredAt = &now
+		}
+	} else if req.DueAt == nil || !medication.IsDue(schedule, *req.DueAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "due_at must be one of the schedule's dose times"})
+		return
+	}
+
+	dose := models.MedicationAdministration{
+		ScheduleID:     schedule.ID,
+		DueAt:          req.DueAt,
+		PrescriptionID: schedule.PrescriptionID,
+		PatientID:      patient.ID,
+		Status:         req.Status,
+		AdministeredAt: req.AdministeredAt,
+		RecordedBy:     c.GetUint("user_id"),
+		Notes:          req.Notes,
+	}
+
+	status := http.StatusCreated
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if dose.DueAt == nil {
+			return tx.Create(&dose).Error
+		}
+
+		// Two caregivers recording the same dose wait on the schedule row
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.MedicationSchedule{}, schedule.ID).Error; err != nil {
+			return err
+		}
+		var existing models.MedicationAdministration
+		result := tx.Where("schedule_id = ? AND due_at = ?", schedule.ID, dose.DueAt).Limit(1).Find(&existing)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return tx.Create(&dose).Error
+		}
+		if existing.Status != "missed" {
+			return errDoseRecorded
+		}
+
+		// A late record replaces the missed marker
+		updated := tx.Model(&models.MedicationAdministration{}).Where("id = ? AND status = ?", existing.ID, "missed").
+			Updates(map[string]interface{}{
+				"status":          dose.Status,
+				"administered_at": dose.AdministeredAt,
+				"recorded_by":     dose.RecordedBy,
+				"notes":           dose.Notes,
+			})
+		if updated.Error != nil {
+			return updated.Error
+		}
+		if updated.RowsAffected == 0 {
+			return errDoseRecorded
+		}
+		dose.ID, dose.CreatedAt = existing.ID, existing.CreatedAt
+		status = http.StatusOK
+		return nil
+	})
+	if errors.Is(err, errDoseRecorded) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This dose has already been recorded"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error recording dose for schedule %d: %v", schedule.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record dose"})
+		return
+	}
+
+	log.Printf("Medication dose recorded - Patient: %d, Schedule: %d, Status: %s, By: %d", patient.ID, schedule.ID, dose.Status, dose.RecordedBy)
+	c.JSON(status, dose)
+}
+
+// GetMedicationAdministrations returns the patient's medication
+// administration record, latest first. Takes from/to, status and
+// prescription_id filters.
+func GetMedicationAdministrations(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	query := config.DB.Where("patient_id = ?", patient.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if prescriptionID := c.Query("prescription_id"); prescriptionID != "" {
+		query = query.Where("prescription_id = ?", prescriptionID)
+	}
+	if from, err := parseDateParamIn(c.Query("from"), scheduling.Location()); err == nil {
+		query = query.Where("COALESCE(due_at, administered_at) >= ?", from)
+	}
+	if to, err := parseDateParamIn(c.Query("to"), scheduling.Location()); err == nil {
+		if len(c.Query("to")) == len("2006-01-02") {
+			to = to.AddDate(0, 0, 1)
+		}
+		query = query.Where("COALESCE(due_at, administered_at) < ?", to)
+	}
+
+	var entries []models.MedicationAdministration
+	if err := query.Order("COALESCE(due_at, administered_at) desc").Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch medication record"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+var errDoseRecorded = errors.New("dose already recorded")
+
+// findPrescriptionForSchedule loads the prescription named by :id and checks
+// that the caller can see its patient
+func findPrescriptionForSchedule(c *gin.Context) (models.Prescription, bool) {
+	var prescription models.Prescription
+	if err := config.DB.First(&prescription, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prescription not found"})
+		return prescription, false
+	}
+	if _, ok := findAccessiblePatient(c, prescription.PatientID); !ok {
+		return prescription, false
+	}
+	return prescription, true
+}
+
+// activeSchedules returns the schedules of the patient's prescriptions that
+// have not been deleted, with their prescription
+func activeSchedules(patientID uint) ([]models.MedicationSchedule, error) {
+	schedules := []models.MedicationSchedule{}
+	err := config.DB.Preload("Prescription").
+		Where("patient_id = ? AND prescription_id IN (?)", patientID, config.DB.Model(&models.Prescription{}).Select("id")).
+		Order("id asc").Find(&schedules).Error
+	return schedules, err
+}
+
+// syncDerivedSchedule derives the prescription's schedule from its frequency
+// and duration (see medication.SyncDerived)
+func syncDerivedSchedule(prescription models.Prescription, userID uint) {
+	if err := medication.SyncDerived(config.DB, prescription, time.Now(), userID); err != nil {
+		log.Printf("Error saving schedule of prescription %d: %v", prescription.ID, err)
+	}
+}
@@ -0,0 +1,351 @@
+package controllers
+
+import (
+	"dementicare-backend/config"
+	"dementicare-backend/models"
+	"dementicare-backend/scheduling"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const maxObservationsPerRequest = 500
+
+// Supported observation types and the unit each is stored in
+var observationUnits = map[string]string{
+	"blood_pressure_systolic":  "mmHg",
+	"blood_pressure_diastolic": "mmHg",
+	"weight":                   "kg",
+	"heart_rate":               "bpm",
+	"sleep_hours":              "h",
+	"blood_glucose":            "mg/dL",
+}
+
+type ObservationBatchRequest struct {
+	Observations []models.Observation `json:"observations" binding:"required"`
+}
+
+type ObservationAggregate struct {
+	PeriodStart time.Time `json:"period_start"`
+	Count       int       `json:"count"`
+	Mean        float64   `json:"mean"`
+	Min         float64   `json:"min"`
+	Max         float64   `json:"max"`
+}
+
+// CreateObservations ingests one or more observations for a patient in a
+// single transaction and checks each against the patient's thresholds.
+func CreateObservations(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req ObservationBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Observations) == 0 || len(req.Observations) > maxObservationsPerRequest {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Send between 1 and %d observations", maxObservationsPerRequest)})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	for i := range req.Observations {
+		obs := &req.Observations[i]
+		unit, known := observationUnits[obs.Type]
+		if !known {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Observation %d: unknown type %q", i, obs.Type)})
+			return
+		}
+		if obs.Unit != "" && obs.Unit != unit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Observation %d: %s must be recorded in %s", i, obs.Type, unit)})
+			return
+		}
+		if math.IsNaN(obs.Value) || math.IsInf(obs.Value, 0) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Observation %d: invalid value", i)})
+			return
+		}
+		if obs.MeasuredAt.IsZero() {
+			obs.MeasuredAt = time.Now()
+		}
+		obs.ID = 0
+		obs.PatientID = patient.ID
+		obs.Unit = unit
+		obs.RecordedBy = userID
+	}
+
+	var thresholds []models.ObservationThreshold
+	if err := config.DB.Where("patient_id = ?", patient.ID).Find(&thresholds).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load thresholds"})
+		return
+	}
+
+	alerts := []models.ObservationAlert{}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&req.Observations).Error; err != nil {
+			return err
+		}
+
+		for _, obs := range req.Observations {
+			for _, t := range thresholds {
+				if msg := thresholdViolation(t, obs); msg != "" {
+					alerts = append(alerts, models.ObservationAlert{
+						PatientID:     patient.ID,
+						ObservationID: obs.ID,
+						ThresholdID:   t.ID,
+						Type:          obs.Type,
+						Value:         obs.Value,
+						Message:       msg,
+						Status:        "open",
+					})
+				}
+			}
+		}
+
+		if len(alerts) > 0 {
+			return tx.Create(&alerts).Error
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error saving observations for patient %d: %v", patient.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save observations"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"observations": req.Observations, "alerts": alerts})
+}
+
+func GetObservations(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var observations []models.Observation
+	if err := observationQuery(c, patient.ID).Order("measured_at desc").Find(&observations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch observations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"observations": observations})
+}
+
+// GetObservationSummary returns mean/min/max per day or week for one type
+func GetObservationSummary(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	obsType := c.Query("type")
+	if _, known := observationUnits[obsType]; !known {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A valid observation type is required"})
+		return
+	}
+
+	interval := c.DefaultQuery("interval", "day")
+	if interval != "day" && interval != "week" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Interval must be 'day' or 'week'"})
+		return
+	}
+
+	var observations []models.Observation
+	if err := observationQuery(c, patient.ID).Order("measured_at asc").Find(&observations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch observations"})
+		return
+	}
+
+	buckets := map[time.Time]*ObservationAggregate{}
+	for _, obs := range observations {
+		start := periodStart(obs.MeasuredAt, interval)
+		agg, exists := buckets[start]
+		if !exists {
+			agg = &ObservationAggregate{PeriodStart: start, Min: obs.Value, Max: obs.Value}
+			buckets[start] = agg
+		}
+		agg.Count++
+		agg.Mean += obs.Value
+		agg.Min = math.Min(agg.Min, obs.Value)
+		agg.Max = math.Max(agg.Max, obs.Value)
+	}
+
+	series := make([]ObservationAggregate, 0, len(buckets))
+	for _, agg := range buckets {
+		agg.Mean /= float64(agg.Count)
+		series = append(series, *agg)
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].PeriodStart.Before(series[j].PeriodStart) })
+
+	c.JSON(http.StatusOK, gin.H{
+		"type":     obsType,
+		"unit":     observationUnits[obsType],
+		"interval": interval,
+		"series":   series,
+	})
+}
+
+func GetObservationThresholds(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var thresholds []models.ObservationThreshold
+	if err := config.DB.Where("patient_id = ?", patient.ID).Find(&thresholds).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch thresholds"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"thresholds": thresholds})
+}
+
+func CreateObservationThreshold(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var threshold models.ObservationThreshold
+	if err := c.ShouldBindJSON(&threshold); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, known := observationUnits[threshold.Type]; !known {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown observation type"})
+		return
+	}
+	if threshold.Min == nil && threshold.Max == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one of min or max is required"})
+		return
+	}
+	if threshold.Min != nil && threshold.Max != nil && *threshold.Min > *threshold.Max {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Min must not be greater than max"})
+		return
+	}
+
+	threshold.ID = 0
+	threshold.PatientID = patient.ID
+	threshold.CreatedBy = c.GetUint("user_id")
+
+	if err := config.DB.Create(&threshold).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create threshold"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, threshold)
+}
+
+func DeleteObservationThreshold(c *gin.Context) {
+	var threshold models.ObservationThreshold
+	if err := config.DB.First(&threshold, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Threshold not found"})
+		return
+	}
+
+	if _, ok := findAccessiblePatient(c, threshold.PatientID); !ok {
+		return
+	}
+
+	if err := config.DB.Delete(&threshold).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete threshold"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Threshold deleted successfully"})
+}
+
+func GetObservationAlerts(c *gin.Context) {
+	query, ok := visiblePatientScope(c, config.DB.Model(&models.ObservationAlert{}))
+	if !ok {
+		return
+	}
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if patientID := c.Query("patient_id"); patientID != "" {
+		query = query.Where("patient_id = ?", patientID)
+	}
+
+	var alerts []models.ObservationAlert
+	if err := query.Order("created_at desc").Find(&alerts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch observation alerts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"alerts": alerts})
+}
+
+func AcknowledgeObservationAlert(c *gin.Context) {
+	var alert models.ObservationAlert
+	if err := config.DB.First(&alert, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return
+	}
+
+	if _, ok := findAccessiblePatient(c, alert.PatientID); !ok {
+		return
+	}
+
+	if alert.Status != "acknowledged" {
+		now := time.Now()
+		alert.Status = "acknowledged"
+		alert.AcknowledgedBy = c.GetUint("user_id")
+		alert.AcknowledgedAt = &now
+		if err := config.DB.Save(&alert).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to acknowledge alert"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, alert)
+}
+
+func observationQuery(c *gin.Context, patientID uint) *gorm.DB {
+	query := config.DB.Where("patient_id = ?", patientID)
+	if obsType := c.Query("type"); obsType != "" {
+		query = query.Where("type = ?", obsType)
+	}
+	if from, err := time.Parse(time.RFC3339, c.Query("from")); err == nil {
+		query = query.Where("measured_at >= ?", from)
+	}
+	if to, err := time.Parse(time.RFC3339, c.Query("to")); err == nil {
+		query = query.Where("measured_at <= ?", to)
+	}
+	return query
+}
+
+func thresholdViolation(t models.ObservationThreshold, obs models.Observation) string {
+	if t.Type != obs.Type {
+		return ""
+	}
+	if t.Min != nil && obs.Value < *t.Min {
+		return fmt.Sprintf("%s %.1f %s is below %.1f", obs.Type, obs.Value, obs.Unit, *t.Min)
+	}
+	if t.Max != nil && obs.Value > *t.Max {
+		return fmt.Sprintf("%s %.1f %s is above %.1f", obs.Type, obs.Value, obs.Unit, *t.Max)
+	}
+	return ""
+}
+
+// periodStart truncates t to the start of its day or ISO week (Monday) in
+// the clinic time zone
+func periodStart(t time.Time, interval string) time.Time {
+	t = t.In(scheduling.Location())
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if interval == "week" {
+		offset := (int(day.Weekday()) + 6) % 7
+		day = day.AddDate(0, 0, -offset)
+	}
+	return day
+}
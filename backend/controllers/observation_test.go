@@ -0,0 +1,211 @@
+package controllers
+
+import (
+	"dementicare-backend/models"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCreateObservations(t *testing.T) {
+	db := useTestDB(t)
+	patient := models.Patient{UserID: 10, Name: "Edith", CaregiverID: 20}
+	db.Create(&patient)
+	limit := 140.0
+	db.Create(&models.ObservationThreshold{PatientID: patient.ID, Type: "blood_pressure_systolic", Max: &limit})
+	target := "/patients/" + strconv.Itoa(int(patient.ID)) + "/observations"
+	reading := func(obsType, unit string, value float64) map[string]interface{} {
+		return map[string]interface{}{"type": obsType, "unit": unit, "value": value}
+	}
+	batch := func(observations ...map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"observations": observations}
+	}
+	tooMany := make([]map[string]interface{}, maxObservationsPerRequest+1)
+	for i := range tooMany {
+		tooMany[i] = reading("weight", "", 70)
+	}
+
+	tests := []struct {
+		name   string
+		as     caller
+		body   map[string]interface{}
+		status int
+		alerts int
+	}{
+		{"unrelated caregiver", caller{id: 21, userType: "caregiver"}, batch(reading("weight", "kg", 70)), http.StatusForbidden, 0},
+		{"other patient", caller{id: 11, userType: "patient"}, batch(reading("weight", "kg", 70)), http.StatusForbidden, 0},
+		{"empty batch", caller{id: 20, userType: "caregiver"}, batch(), http.StatusBadRequest, 0},
+		{"too many", caller{id: 20, userType: "caregiver"}, batch(tooMany...), http.StatusBadRequest, 0},
+		{"unknown type", caller{id: 20, userType: "caregiver"}, batch(reading("steps", "", 5000)), http.StatusBadRequest, 0},
+		{"wrong unit", caller{id: 20, userType: "caregiver"}, batch(reading("weight", "lb", 150)), http.StatusBadRequest, 0},
+		{"within threshold", caller{id: 10, userType: "patient"}, batch(reading("blood_pressure_systolic", "", 120)), http.StatusCreated, 0},
+		{"above threshold", caller{id: 20, userType: "caregiver"}, batch(reading("blood_pressure_systolic", "mmHg", 150), reading("weight", "", 70)), http.StatusCreated, 1},
+	}
+	for _, tt := range tests {
+		w := serve(CreateObservations, "POST", "/patients/:id/observations", target, tt.as, tt.body)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+			continue
+		}
+		if w.Code != http.StatusCreated {
+			continue
+		}
+		var resp struct {
+			Observations []models.Observation      `json:"observations"`
+			Alerts       []models.ObservationAlert `json:"alerts"`
+		}
+		decode(t, w, &resp)
+		if len(resp.Alerts) != tt.alerts {
+			t.Errorf("%s: got %d alerts, want %d", tt.name, len(resp.Alerts), tt.alerts)
+		}
+		for _, obs := range resp.Observations {
+			if obs.Unit != observationUnits[obs.Type] || obs.RecordedBy != tt.as.id || obs.MeasuredAt.IsZero() {
+				t.Errorf("%s: stored %+v, want the canonical unit, recorder and a measurement time", tt.name, obs)
+			}
+		}
+	}
+
+	// A rejected batch saves nothing, an accepted one saves every observation
+	var count int64
+	db.Model(&models.Observation{}).Count(&count)
+	if count != 3 {
+		t.Errorf("stored %d observations, want 3", count)
+	}
+}
+
+func TestObservationSummary(t *testing.T) {
+	db := useTestDB(t)
+	patient := models.Patient{UserID: 10, Name: "Edith", CaregiverID: 20}
+	db.Create(&patient)
+	// 2026-03-02 is a Monday
+	at := func(day int) time.Time { return time.Date(2026, 3, day, 12, 0, 0, 0, time.UTC) }
+	for _, obs := range []models.Observation{
+		{Type: "weight", Value: 70, MeasuredAt: at(2)},
+		{Type: "weight", Value: 72, MeasuredAt: at(2)},
+		{Type: "weight", Value: 74, MeasuredAt: at(4)},
+		{Type: "weight", Value: 80, MeasuredAt: at(9)},
+		{Type: "heart_rate", Value: 60, MeasuredAt: at(2)},
+	} {
+		obs.PatientID = patient.ID
+		db.Create(&obs)
+	}
+	target := "/patients/" + strconv.Itoa(int(patient.ID)) + "/observations/summary"
+	asCaregiver := caller{id: 20, userType: "caregiver"}
+
+	tests := []struct {
+		query  string
+		status int
+		want   []ObservationAggregate
+	}{
+		{"?type=weight", http.StatusOK, []ObservationAggregate{
+			{Count: 2, Mean: 71, Min: 70, Max: 72},
+			{Count: 1, Mean: 74, Min: 74, Max: 74},
+			{Count: 1, Mean: 80, Min: 80, Max: 80},
+		}},
+		{"?type=weight&interval=week", http.StatusOK, []ObservationAggregate{
+			{Count: 3, Mean: 72, Min: 70, Max: 74},
+			{Count: 1, Mean: 80, Min: 80, Max: 80},
+		}},
+		{"?type=weight&interval=week&to=2026-03-03T00:00:00Z", http.StatusOK, []ObservationAggregate{
+			{Count: 2, Mean: 71, Min: 70, Max: 72},
+		}},
+		{"?type=steps", http.StatusBadRequest, nil},
+		{"?type=weight&interval=month", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		w := serve(GetObservationSummary, "GET", "/patients/:id/observations/summary", target+tt.query, asCaregiver, nil)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.query, w.Code, tt.status, w.Body)
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		var resp struct {
+			Series []ObservationAggregate `json:"series"`
+		}
+		decode(t, w, &resp)
+		if len(resp.Series) != len(tt.want) {
+			t.Errorf("%s: got %d periods %+v, want %d", tt.query, len(resp.Series), resp.Series, len(tt.want))
+			continue
+		}
+		for i, got := range resp.Series {
+			want := tt.want[i]
+			if got.Count != want.Count || got.Mean != want.Mean || got.Min != want.Min || got.Max != want.Max {
+				t.Errorf("%s: period %d = %+v, want %+v", tt.query, i, got, want)
+			}
+			if i > 0 && !got.PeriodStart.After(resp.Series[i-1].PeriodStart) {
+				t.Errorf("%s: periods are not in order: %+v", tt.query, resp.Series)
+			}
+		}
+	}
+
+	if w := serve(GetObservationSummary, "GET", "/patients/:id/observations/summary", target+"?type=weight", caller{id: 21, userType: "caregiver"}, nil); w.Code != http.StatusForbidden {
+		t.Errorf("unrelated caregiver status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestObservationThresholdsAndAlerts(t *testing.T) {
+	db := useTestDB(t)
+	edith := models.Patient{UserID: 10, Name: "Edith", CaregiverID: 20}
+	frank := models.Patient{UserID: 11, Name: "Frank", CaregiverID: 21}
+	db.Create(&edith)
+	db.Create(&frank)
+	target := "/patients/" + strconv.Itoa(int(edith.ID)) + "/observation-thresholds"
+	asCaregiver := caller{id: 20, userType: "caregiver"}
+
+	tests := []struct {
+		name   string
+		as     caller
+		body   map[string]interface{}
+		status int
+	}{
+		{"unrelated caregiver", caller{id: 21, userType: "caregiver"}, map[string]interface{}{"type": "weight", "min": 50}, http.StatusForbidden},
+		{"unknown type", asCaregiver, map[string]interface{}{"type": "steps", "min": 50}, http.StatusBadRequest},
+		{"no bounds", asCaregiver, map[string]interface{}{"type": "weight"}, http.StatusBadRequest},
+		{"min above max", asCaregiver, map[string]interface{}{"type": "weight", "min": 90, "max": 50}, http.StatusBadRequest},
+		{"valid", asCaregiver, map[string]interface{}{"type": "weight", "min": 50, "max": 90}, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		w := serve(CreateObservationThreshold, "POST", "/patients/:id/observation-thresholds", target, tt.as, tt.body)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+		}
+	}
+
+	db.Create(&models.ObservationAlert{PatientID: edith.ID, Type: "weight", Value: 45, Status: "open"})
+	db.Create(&models.ObservationAlert{PatientID: frank.ID, Type: "weight", Value: 95, Status: "open"})
+
+	// Callers see only the alerts of the patients visible to them
+	for _, tt := range []struct {
+		as   caller
+		want uint
+	}{
+		{asCaregiver, edith.ID},
+		{caller{id: 11, userType: "patient"}, frank.ID},
+	} {
+		w := serve(GetObservationAlerts, "GET", "/observation-alerts", "/observation-alerts?status=open", tt.as, nil)
+		var resp struct {
+			Alerts []models.ObservationAlert `json:"alerts"`
+		}
+		decode(t, w, &resp)
+		if len(resp.Alerts) != 1 || resp.Alerts[0].PatientID != tt.want {
+			t.Errorf("user %d sees alerts %+v, want only patient %d's", tt.as.id, resp.Alerts, tt.want)
+		}
+	}
+
+	var alert models.ObservationAlert
+	db.Where("patient_id = ?", frank.ID).First(&alert)
+	alertTarget := "/observation-alerts/" + strconv.Itoa(int(alert.ID)) + "/acknowledge"
+	if w := serve(AcknowledgeObservationAlert, "POST", "/observation-alerts/:id/acknowledge", alertTarget, asCaregiver, nil); w.Code != http.StatusForbidden {
+		t.Errorf("unrelated caregiver acknowledge status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := serve(AcknowledgeObservationAlert, "POST", "/observation-alerts/:id/acknowledge", alertTarget, caller{id: 21, userType: "caregiver"}, nil); w.Code != http.StatusOK {
+		t.Errorf("caregiver acknowledge status = %d, want %d", w.Code, http.StatusOK)
+	}
+	db.First(&alert, alert.ID)
+	if alert.Status != "acknowledged" || alert.AcknowledgedBy != 21 || alert.AcknowledgedAt == nil {
+		t.Errorf("alert after acknowledging = %+v", alert)
+	}
+}
@@ -3,9 +3,11 @@ package controllers
 import (
 	"dementicare-backend/config"
 	"dementicare-backend/models"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func GetPatients(c *gin.Context) {
@@ -17,7 +19,7 @@ func GetPatients(c *gin.Context) {
 
 	query := config.DB.Order("created_at desc")
 	if userType == "caregiver" {
-		query = query.Where("caregiver_id = ?", userID)
+		query = query.Where("id IN (?)", caredForPatients(userID))
 	}
 
 	if err := query.Find(&patients).Error; err != nil {
@@ -51,8 +53,16 @@ func CreatePatient(c *gin.Context) {
 	if c.GetString("user_type") == "caregiver" {
 		patient.CaregiverID = c.GetUint("user_id")
 	}
-
-	if err := config.DB.Create(&patient).Error; err != nil {
+	// The login is linked only through LinkPatientUser
+	patient.UserID = 0
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&patient).Error; err != nil {
+			return err
+		}
+		return createIntakeDiagnoses(tx, []models.Patient{patient})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create patient"})
 		return
 	}
@@ -69,10 +79,13 @@ func UpdatePatient(c *gin.Context) {
 		return
 	}
 
+	// The diagnosis changes only through the diagnosis history
+	userID, diagnosis := patient.UserID, patient.Diagnosis
 	if err := c.ShouldBindJSON(&patient); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	patient.UserID, patient.Diagnosis = userID, diagnosis
 
 	if err := config.DB.Save(&patient).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update patient"})
@@ -82,6 +95,47 @@ func UpdatePatient(c *gin.Context) {
 	c.JSON(http.StatusOK, patient)
 }
 
+// LinkPatientUser links the patient record to a patient login, or unlinks it
+// with user_id 0. The login gains access to the record and its appointments,
+// so only doctors and admins can do this.
+func LinkPatientUser(c *gin.Context) {
+	if userType := c.GetString("user_type"); userType != "doctor" && userType != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only doctors and admins can link patient logins"})
+		return
+	}
+
+	var patient models.Patient
+	if err := config.DB.First(&patient, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
+		return
+	}
+
+	var req struct {
+		UserID uint `json:"user_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.UserID != 0 {
+		var user models.User
+		if err := config.DB.Where("user_type = ?", "patient").First(&user, req.UserID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "user_id must be a patient account"})
+			return
+		}
+	}
+
+	if err := config.DB.Model(&patient).Update("user_id", req.UserID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link patient login"})
+		return
+	}
+	patient.UserID = req.UserID
+
+	log.Printf("Patient login linked - Patient: %d, User: %d, By: %d", patient.ID, req.UserID, c.GetUint("user_id"))
+	c.JSON(http.StatusOK, patient)
+}
+
 func DeletePatient(c *gin.Context) {
 	id := c.Param("id")
 
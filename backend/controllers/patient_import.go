@@ -0,0 +1,357 @@
+package controllers
+
+import (
+	"dementicare-backend/config"
+	"dementicare-backend/models"
+	"dementicare-backend/spreadsheet"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	maxPatientImportRows = 5000
+	// maxImportFileSize caps the request body of file imports
+	maxImportFileSize = 10 << 20 // 10 MB
+)
+
+// Patient fields accepted by the import, in export column order
+var patientImportFields = []string{"name", "age", "gender", "phone", "address", "diagnosis", "caregiver_id"}
+
+var patientGenders = map[string]string{
+	"male": "Male", "m": "Male",
+	"female": "Female", "f": "Female",
+	"other": "Other",
+}
+
+type ImportRowError struct {
+	Row   int    `json:"row"` // 1-based spreadsheet row, header is row 1
+	Field string `json:"field,omitempty"`
+	Error string `json:"error"`
+}
+
+type PatientImportReport struct {
+	DryRun    bool             `json:"dry_run"`
+	TotalRows int              `json:"total_rows"`
+	ValidRows int              `json:"valid_rows"`
+	Imported  int              `json:"imported"`
+	Errors    []ImportRowError `json:"errors"`
+	Preview   []models.Patient `json:"preview,omitempty"`
+}
+
+// ImportPatients loads patients from an uploaded CSV or XLSX file. The
+// optional "mapping" form field maps patient fields to column headers, e.g.
+// {"name": "Full Name"}; unmapped fields match headers by name. With
+// ?dry_run=true the file is only validated. Otherwise all rows are imported
+// in one transaction, or none if any row is invalid.
+func ImportPatients(c *gin.Context) {
+	userType := c.GetString("user_type")
+	if userType != "doctor" && userType != "admin" && userType != "caregiver" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed to import patients"})
+		return
+	}
+
+	header, ok := importFile(c)
+	if !ok {
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	// The header row comes on top of the data rows
+	var rows [][]string
+	switch strings.ToLower(filepath.Ext(header.Filename)) {
+	case ".csv":
+		rows, err = spreadsheet.ReadCSV(file, maxPatientImportRows+1)
+	case ".xlsx":
+		rows, err = spreadsheet.ReadXLSX(file, header.Size, maxPatientImportRows+1)
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Only .csv and .xlsx files are supported"})
+		return
+	}
+	if errors.Is(err, spreadsheet.ErrTooManyRows) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d rows can be imported at once", maxPatientImportRows)})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse file: " + err.Error()})
+		return
+	}
+	if len(rows) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File must have a header row and at least one data row"})
+		return
+	}
+
+	mapping := map[string]string{}
+	if raw := c.PostForm("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Mapping must be a JSON object of field to column header"})
+			return
+		}
+	}
+
+	columns, err := resolveImportColumns(rows[0], mapping)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report := PatientImportReport{
+		DryRun:    c.Query("dry_run") == "true",
+		TotalRows: len(rows) - 1,
+		Errors:    []ImportRowError{},
+	}
+
+	caregivers := map[uint]bool{}
+	patients := []models.Patient{}
+	for i, row := range rows[1:] {
+		if isBlankRow(row) {
+			report.TotalRows--
+			continue
+		}
+		patient, rowErrors := parseImportRow(c, row, columns, caregivers)
+		for _, e := range rowErrors {
+			e.Row = i + 2
+			report.Errors = append(report.Errors, e)
+		}
+		if len(rowErrors) == 0 {
+			patients = append(patients, patient)
+		}
+	}
+	report.ValidRows = len(patients)
+
+	if report.DryRun {
+		if len(patients) > 20 {
+			report.Preview = patients[:20]
+		} else {
+			report.Preview = patients
+		}
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	if len(report.Errors) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, report)
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(&patients, 200).Error; err != nil {
+			return err
+		}
+		return createIntakeDiagnoses(tx, patients)
+	})
+	if err != nil {
+		log.Printf("Error importing patients: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import patients"})
+		return
+	}
+
+	report.Imported = len(patients)
+	log.Printf("Patients imported - Count: %d, User: %d", report.Imported, c.GetUint("user_id"))
+	c.JSON(http.StatusCreated, report)
+}
+
+// ExportPatients writes the caller's visible patients as CSV
+func ExportPatients(c *gin.Context) {
+	query := config.DB.Order("id asc")
+	switch c.GetString("user_type") {
+	case "doctor", "admin":
+	case "caregiver":
+		query = query.Where("caregiver_id = ?", c.GetUint("user_id"))
+	case "patient":
+		query = query.Where("user_id = ?", c.GetUint("user_id"))
+	default:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed to export patients"})
+		return
+	}
+
+	var patients []models.Patient
+	if err := query.Find(&patients).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch patients"})
+		return
+	}
+
+	filename := fmt.Sprintf("patients-%s.csv", time.Now().Format("2006-01-02"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	w := csv.NewWriter(c.Writer)
+	w.Write(append([]string{"id"}, patientImportFields...))
+	for _, p := range patients {
+		w.Write([]string{
+			strconv.FormatUint(uint64(p.ID), 10),
+			csvSafe(p.Name),
+			strconv.Itoa(p.Age),
+			csvSafe(p.Gender),
+			csvSafe(p.Phone),
+			csvSafe(p.Address),
+			csvSafe(p.Diagnosis),
+			strconv.FormatUint(uint64(p.CaregiverID), 10),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Printf("Error writing patient export: %v", err)
+	}
+}
+
+// importFile returns the uploaded "file" of an import request. The request
+// body is capped first, as the whole form is parsed before the file is seen.
+func importFile(c *gin.Context) (*multipart.FileHeader, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportFileSize)
+	header, err := c.FormFile("file")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Upload exceeds the %d byte limit", maxImportFileSize)})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required"})
+		return nil, false
+	}
+	return header, true
+}
+
+// resolveImportColumns maps each patient field to a column index using the
+// explicit mapping first and a case-insensitive header match otherwise.
+func resolveImportColumns(headers []string, mapping map[string]string) (map[string]int, error) {
+	index := map[string]int{}
+	for i, h := range headers {
+		index[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+
+	known := map[string]bool{}
+	for _, f := range patientImportFields {
+		known[f] = true
+	}
+
+	columns := map[string]int{}
+	for field, header := range mapping {
+		if !known[field] {
+			return nil, fmt.Errorf("unknown field %q in mapping", field)
+		}
+		i, ok := index[strings.ToLower(strings.TrimSpace(header))]
+		if !ok {
+			return nil, fmt.Errorf("column %q mapped to %s not found", header, field)
+		}
+		columns[field] = i
+	}
+	for _, field := range patientImportFields {
+		if _, mapped := columns[field]; mapped {
+			continue
+		}
+		if i, ok := index[field]; ok {
+			columns[field] = i
+		}
+	}
+
+	if _, ok := columns["name"]; !ok {
+		return nil, fmt.Errorf("no column found for required field name")
+	}
+	return columns, nil
+}
+
+func parseImportRow(c *gin.Context, row []string, columns map[string]int, caregivers map[uint]bool) (models.Patient, []ImportRowError) {
+	value := func(field string) string {
+		i, ok := columns[field]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return csvUnsafe(strings.TrimSpace(row[i])) // re-importing an export
+	}
+
+	var errs []ImportRowError
+	patient := models.Patient{
+		Name:      value("name"),
+		Phone:     value("phone"),
+		Address:   value("address"),
+		Diagnosis: value("diagnosis"),
+	}
+
+	if patient.Name == "" {
+		errs = append(errs, ImportRowError{Field: "name", Error: "Name is required"})
+	}
+
+	if age := value("age"); age != "" {
+		n, err := strconv.Atoi(age)
+		if err != nil || n < 0 || n > 130 {
+			errs = append(errs, ImportRowError{Field: "age", Error: "Age must be a whole number between 0 and 130"})
+		}
+		patient.Age = n
+	}
+
+	if gender := value("gender"); gender != "" {
+		normalized, ok := patientGenders[strings.ToLower(gender)]
+		if !ok {
+			errs = append(errs, ImportRowError{Field: "gender", Error: "Gender must be Male, Female or Other"})
+		}
+		patient.Gender = normalized
+	}
+
+	if c.GetString("user_type") == "caregiver" {
+		patient.CaregiverID = c.GetUint("user_id")
+	} else if raw := value("caregiver_id"); raw != "" && raw != "0" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil || !isCaregiver(uint(id), caregivers) {
+			errs = append(errs, ImportRowError{Field: "caregiver_id", Error: "Caregiver not found"})
+		}
+		patient.CaregiverID = uint(id)
+	}
+
+	return patient, errs
+}
+
+// isCaregiver checks that the user exists and is a caregiver, caching lookups
+// for the duration of one import.
+func isCaregiver(id uint, cache map[uint]bool) bool {
+	if ok, seen := cache[id]; seen {
+		return ok
+	}
+	var count int64
+	config.DB.Model(&models.User{}).Where("id = ? AND user_type = ?", id, "caregiver").Count(&count)
+	cache[id] = count > 0
+	return cache[id]
+}
+
+func isBlankRow(row []string) bool {
+	for _, v := range row {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// csvSafe prevents spreadsheet formula injection when the export is opened
+// in Excel or similar tools.
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// csvUnsafe undoes csvSafe. Other leading apostrophes, as in "'Sullivan", are
+// kept.
+func csvUnsafe(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune("=+-@\t\r", rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestImportFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		field  string
+		status int
+	}{
+		{"small file", 1 << 10, "file", http.StatusOK},
+		{"no file field", 1 << 10, "upload", http.StatusBadRequest},
+		{"over the upload cap", maxImportFileSize + 1, "file", http.StatusRequestEntityTooLarge},
+	}
+	handler := func(c *gin.Context) {
+		if _, ok := importFile(c); ok {
+			c.Status(http.StatusOK)
+		}
+	}
+	for _, tt := range tests {
+		body := upload{field: tt.field, filename: "patients.csv", content: bytes.Repeat([]byte("a"), tt.size)}
+		w := serve(handler, "POST", "/import", "/import", caller{id: 1, userType: "doctor"}, body)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+		}
+	}
+}
+
+func TestCSVSafeRoundTrip(t *testing.T) {
+	tests := []struct {
+		in, safe string
+	}{
+		{"Alzheimer's disease", "Alzheimer's disease"},
+		{"=HYPERLINK(\"x\")", "'=HYPERLINK(\"x\")"},
+		{"+44 20 7946 0000", "'+44 20 7946 0000"},
+		{"-", "'-"},
+		{"@home", "'@home"},
+		{"'Sullivan Road", "'Sullivan Road"},
+		{"''=quoted", "''=quoted"},
+		{"'", "'"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		safe := csvSafe(tt.in)
+		if safe != tt.safe {
+			t.Errorf("csvSafe(%q) = %q, want %q", tt.in, safe, tt.safe)
+		}
+		if got := csvUnsafe(safe); got != tt.in {
+			t.Errorf("csvUnsafe(%q) = %q, want %q", safe, got, tt.in)
+		}
+	}
+}
@@ -0,0 +1,513 @@
+package controllers
+
+import (
+	"dementicare-backend/config"
+	"dementicare-backend/models"
+	"errors"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultDuplicateMinScore = 0.6
+
+// Tables whose patient_id references patients.id. A merge moves their rows to
+// the surviving patient.
+var patientRecordTables = []string{
+	"prescriptions",
+	"quiz_results",
+	"attachments",
+	"safe_zones",
+	"location_pings",
+	"location_alerts",
+	"observations",
+	"observation_thresholds",
+	"observation_alerts",
+	"diagnoses",
+	"allergies",
+	"care_log_entries",
+	"symptom_episodes",
+	"care_plans",
+	"care_team_members",
+	"medication_schedules",
+	"medication_administrations",
+}
+
+// Tables whose patient_id references the patient's users.id. Their rows move
+// when both patients have a login of their own.
+var patientUserTables = []string{
+	"appointments",
+	"appointment_series",
+	"waitlist_entries",
+}
+
+// Survivor fields a merge fills in from the duplicate when blank, by column
+var mergeFillFields = []struct {
+	name  string
+	field func(p *models.Patient) reflect.Value
+}{
+	{"user_id", func(p *models.Patient) reflect.Value { return reflect.ValueOf(&p.UserID).Elem() }},
+	{"age", func(p *models.Patient) reflect.Value { return reflect.ValueOf(&p.Age).Elem() }},
+	{"gender", func(p *models.Patient) reflect.Value { return reflect.ValueOf(&p.Gender).Elem() }},
+	{"phone", func(p *models.Patient) reflect.Value { return reflect.ValueOf(&p.Phone).Elem() }},
+	{"address", func(p *models.Patient) reflect.Value { return reflect.ValueOf(&p.Address).Elem() }},
+	{"diagnosis", func(p *models.Patient) reflect.Value { return reflect.ValueOf(&p.Diagnosis).Elem() }},
+	{"caregiver_id", func(p *models.Patient) reflect.Value { return reflect.ValueOf(&p.CaregiverID).Elem() }},
+}
+
+var (
+	errMergeReverted = errors.New("merge already reverted")
+	errSurvivorGone  = errors.New("surviving patient deleted or merged since")
+)
+
+type DuplicateCandidate struct {
+	Patient   models.Patient `json:"patient"`
+	Duplicate models.Patient `json:"duplicate"`
+	Score     float64        `json:"score"`
+	Reasons   []string       `json:"reasons"`
+}
+
+type MergePatientsRequest struct {
+	SurvivorID  uint `json:"survivor_id" binding:"required"`
+	DuplicateID uint `json:"duplicate_id" binding:"required"`
+}
+
+// FindDuplicatePatients fuzzy-matches the caller's visible patients on name,
+// phone, age and address. With ?patient_id= only matches for that patient are
+// returned.
+func FindDuplicatePatients(c *gin.Context) {
+	minScore := defaultDuplicateMinScore
+	if v, err := strconv.ParseFloat(c.Query("min_score"), 64); err == nil && v > 0 && v <= 1 {
+		minScore = v
+	}
+
+	query := config.DB.Order("id asc")
+	switch c.GetString("user_type") {
+	case "doctor", "admin":
+	case "caregiver":
+		query = query.Where("caregiver_id = ?", c.GetUint("user_id"))
+	default:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed to search for duplicates"})
+		return
+	}
+
+	var patients []models.Patient
+	if err := query.Find(&patients).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch patients"})
+		return
+	}
+
+	var targetID uint64
+	if v := c.Query("patient_id"); v != "" {
+		targetID, _ = strconv.ParseUint(v, 10, 64)
+	}
+
+	candidates := []DuplicateCandidate{}
+	for i := range patients {
+		for j := i + 1; j < len(patients); j++ {
+			a, b := patients[i], patients[j]
+			if targetID != 0 && uint(targetID) != a.ID && uint(targetID) != b.ID {
+				continue
+			}
+			if uint(targetID) == b.ID {
+				a, b = b, a
+			}
+			score, reasons := duplicateScore(a, b)
+			if score >= minScore {
+				candidates = append(candidates, DuplicateCandidate{Patient: a, Duplicate: b, Score: score, Reasons: reasons})
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+
+	c.JSON(http.StatusOK, gin.H{"candidates": candidates})
+}
+
+func GetPatientMerges(c *gin.Context) {
+	if !canMergePatients(c) {
+		return
+	}
+
+	var merges []models.PatientMerge
+	if err := config.DB.Order("created_at desc").Find(&merges).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch merge log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"merges": merges})
+}
+
+// MergePatients moves every record of the duplicate patient to the survivor
+// and soft-deletes the duplicate, all in one transaction. Care-team members
+// the survivor already has are dropped instead of moved.
+func MergePatients(c *gin.Context) {
+	if !canMergePatients(c) {
+		return
+	}
+
+	var req MergePatientsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.SurvivorID == req.DuplicateID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot merge a patient into itself"})
+		return
+	}
+
+	var merge models.PatientMerge
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Locked so that neither patient can be merged elsewhere meanwhile
+		var survivor, duplicate models.Patient
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&survivor, req.SurvivorID).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&duplicate, req.DuplicateID).Error; err != nil {
+			return err
+		}
+
+		merge = models.PatientMerge{
+			SurvivorID:        survivor.ID,
+			DuplicateID:       duplicate.ID,
+			MergedBy:          c.GetUint("user_id"),
+			SurvivorSnapshot:  survivor,
+			DuplicateSnapshot: duplicate,
+			MovedRecords:      map[string][]uint{},
+			DroppedRecords:    map[string][]uint{},
+			Status:            "merged",
+		}
+
+		dropped, err := dropSharedCareTeamMembers(tx, survivor.ID, duplicate.ID)
+		if err != nil {
+			return err
+		}
+		if len(dropped) > 0 {
+			merge.DroppedRecords["care_team_members"] = dropped
+		}
+
+		for _, table := range patientRecordTables {
+			ids, err := moveRecords(tx, table, duplicate.ID, survivor.ID, merge.DroppedRecords[table])
+			if err != nil {
+				return err
+			}
+			if len(ids) > 0 {
+				merge.MovedRecords[table] = ids
+			}
+		}
+
+		// A survivor without a login of its own takes over the duplicate's
+		// below, so user-keyed records only move when both have one
+		if duplicate.UserID != 0 && survivor.UserID != 0 && survivor.UserID != duplicate.UserID {
+			merge.FromUserID = duplicate.UserID
+			merge.ToUserID = survivor.UserID
+			for _, table := range patientUserTables {
+				ids, err := moveRecords(tx, table, duplicate.UserID, survivor.UserID, nil)
+				if err != nil {
+					return err
+				}
+				if len(ids) > 0 {
+					merge.MovedRecords[table] = ids
+				}
+			}
+		}
+
+		merge.FilledFields = fillBlankPatientFields(&survivor, duplicate)
+		if err := tx.Save(&survivor).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&duplicate).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&merge).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error merging patient %d into %d: %v", req.DuplicateID, req.SurvivorID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge patients"})
+		return
+	}
+
+	log.Printf("Patients merged - Merge: %d, Survivor: %d, Duplicate: %d", merge.ID, merge.SurvivorID, merge.DuplicateID)
+	c.JSON(http.StatusCreated, merge)
+}
+
+// RevertPatientMerge restores the duplicate patient and moves back its records
+// that are still with the survivor. Survivor fields filled in by the merge are
+// blanked again unless they have been edited since. A survivor that has since
+// been deleted or merged away cannot be reverted.
+func RevertPatientMerge(c *gin.Context) {
+	if !canMergePatients(c) {
+		return
+	}
+
+	var merge models.PatientMerge
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Locked so that the merge is reverted at most once
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&merge, c.Param("id")).Error; err != nil {
+			return err
+		}
+		if merge.Status != "merged" {
+			return errMergeReverted
+		}
+		var survivor models.Patient
+		if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).First(&survivor, merge.SurvivorID).Error; err != nil {
+			return err
+		}
+		if survivor.DeletedAt.Valid {
+			return errSurvivorGone
+		}
+
+		for table, ids := range merge.MovedRecords {
+			from, to := merge.SurvivorID, merge.DuplicateID
+			if isPatientUserTable(table) {
+				from, to = merge.ToUserID, merge.FromUserID
+			}
+			// Records moved on or deleted since the merge are left alone
+			if err := tx.Table(table).Where("id IN ? AND patient_id = ?", ids, from).Update("patient_id", to).Error; err != nil {
+				return err
+			}
+		}
+		for table, ids := range merge.DroppedRecords {
+			if err := tx.Table(table).Where("id IN ? AND patient_id = ?", ids, merge.DuplicateID).Update("deleted_at", nil).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Unscoped().Model(&models.Patient{}).Where("id = ?", merge.DuplicateID).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		unfillPatientFields(&survivor, merge)
+		if err := tx.Save(&survivor).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		merge.Status = "reverted"
+		merge.RevertedBy = c.GetUint("user_id")
+		merge.RevertedAt = &now
+		return tx.Save(&merge).Error
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Merge not found"})
+		return
+	case errors.Is(err, errMergeReverted):
+		c.JSON(http.StatusConflict, gin.H{"error": "Merge has already been reverted"})
+		return
+	case errors.Is(err, errSurvivorGone):
+		c.JSON(http.StatusConflict, gin.H{"error": "The surviving patient has since been deleted or merged; revert that first"})
+		return
+	case err != nil:
+		log.Printf("Error reverting merge %d: %v", merge.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revert merge"})
+		return
+	}
+
+	c.JSON(http.StatusOK, merge)
+}
+
+func canMergePatients(c *gin.Context) bool {
+	switch c.GetString("user_type") {
+	case "doctor", "admin":
+		return true
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "Only doctors and admins can merge patients"})
+	return false
+}
+
+func isPatientUserTable(table string) bool {
+	for _, t := range patientUserTables {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
+
+// moveRecords re-points the rows of table from one patient to another, except
+// those in skip, and returns the IDs moved
+func moveRecords(tx *gorm.DB, table string, from, to uint, skip []uint) ([]uint, error) {
+	query := tx.Table(table).Where("patient_id = ?", from)
+	if len(skip) > 0 {
+		query = query.Where("id NOT IN ?", skip)
+	}
+	var ids []uint
+	if err := query.Pluck("id", &ids).Error; err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	if err := tx.Table(table).Where("id IN ?", ids).Update("patient_id", to).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// dropSharedCareTeamMembers soft-deletes the duplicate's care-team members
+// who are on the survivor's team already, so that nobody is listed twice
+func dropSharedCareTeamMembers(tx *gorm.DB, survivorID, duplicateID uint) ([]uint, error) {
+	var ids []uint
+	shared := tx.Model(&models.CareTeamMember{}).Select("user_id").Where("patient_id = ?", survivorID)
+	if err := tx.Model(&models.CareTeamMember{}).Where("patient_id = ? AND user_id IN (?)", duplicateID, shared).Pluck("id", &ids).Error; err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	if err := tx.Delete(&models.CareTeamMember{}, ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// fillBlankPatientFields copies the duplicate's values into blank survivor
+// fields and returns the columns filled
+func fillBlankPatientFields(survivor *models.Patient, duplicate models.Patient) []string {
+	var filled []string
+	for _, f := range mergeFillFields {
+		value, merged := f.field(survivor), f.field(&duplicate)
+		if value.IsZero() && !merged.IsZero() {
+			value.Set(merged)
+			filled = append(filled, f.name)
+		}
+	}
+	return filled
+}
+
+// unfillPatientFields blanks the survivor fields a merge filled in, unless
+// they have been changed since
+func unfillPatientFields(survivor *models.Patient, merge models.PatientMerge) {
+	for _, f := range mergeFillFields {
+		for _, name := range merge.FilledFields {
+			if name != f.name {
+				continue
+			}
+			value := f.field(survivor)
+			if value.Interface() == f.field(&merge.DuplicateSnapshot).Interface() {
+				value.Set(f.field(&merge.SurvivorSnapshot))
+			}
+		}
+	}
+}
+
+// duplicateScore weighs name, phone, age and address similarity into a
+// score between 0 and 1.
+func duplicateScore(a, b models.Patient) (float64, []string) {
+	score := 0.0
+	reasons := []string{}
+
+	nameSim := stringSimilarity(normalizeText(a.Name), normalizeText(b.Name))
+	score += 0.5 * nameSim
+	if nameSim >= 0.8 {
+		reasons = append(reasons, "similar name")
+	}
+
+	phoneA, phoneB := digitsOnly(a.Phone), digitsOnly(b.Phone)
+	if len(phoneA) >= 7 && len(phoneB) >= 7 && phoneA[len(phoneA)-7:] == phoneB[len(phoneB)-7:] {
+		score += 0.25
+		reasons = append(reasons, "same phone")
+	}
+
+	if a.Age > 0 && b.Age > 0 && abs(a.Age-b.Age) <= 1 {
+		score += 0.1
+		reasons = append(reasons, "same age")
+	}
+
+	if a.Address != "" && b.Address != "" {
+		addrSim := tokenSimilarity(normalizeText(a.Address), normalizeText(b.Address))
+		score += 0.15 * addrSim
+		if addrSim >= 0.6 {
+			reasons = append(reasons, "similar address")
+		}
+	}
+
+	return score, reasons
+}
+
+func normalizeText(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func digitsOnly(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// stringSimilarity is 1 minus the normalized Levenshtein distance
+func stringSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 0
+	}
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return 1 - float64(prev[len(rb)])/float64(max(len(ra), len(rb)))
+}
+
+// tokenSimilarity is the Jaccard index of the two word sets
+func tokenSimilarity(a, b string) float64 {
+	setA := map[string]bool{}
+	for _, w := range strings.Fields(a) {
+		setA[w] = true
+	}
+	setB := map[string]bool{}
+	for _, w := range strings.Fields(b) {
+		setB[w] = true
+	}
+
+	shared := 0
+	for w := range setA {
+		if setB[w] {
+			shared++
+		}
+	}
+	union := len(setA) + len(setB) - shared
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
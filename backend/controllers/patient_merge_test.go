@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"dementicare-backend/models"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestDuplicateScore(t *testing.T) {
+	base := models.Patient{Name: "Margaret Thompson", Phone: "+44 20 7946 0958", Age: 81, Address: "12 Elm Road, London"}
+	tests := []struct {
+		name     string
+		other    models.Patient
+		min, max float64
+		reasons  []string
+	}{
+		{
+			name:    "same person, formatted differently",
+			other:   models.Patient{Name: "margaret  thompson", Phone: "020 7946 0958", Age: 82, Address: "12 Elm Road London"},
+			min:     0.99,
+			max:     1.0,
+			reasons: []string{"similar name", "same phone", "same age", "similar address"},
+		},
+		{
+			name:    "typo in the name only",
+			other:   models.Patient{Name: "Margret Thompson"},
+			min:     0.45,
+			max:     0.5,
+			reasons: []string{"similar name"},
+		},
+		{
+			name:    "different person",
+			other:   models.Patient{Name: "Arthur Jenkins", Phone: "07700 900123", Age: 70, Address: "4 Oak Lane, Leeds"},
+			min:     0,
+			max:     0.2,
+			reasons: []string{},
+		},
+		{
+			name:    "short phone numbers are not compared",
+			other:   models.Patient{Name: "Someone Else", Phone: "0958"},
+			min:     0,
+			max:     0.25,
+			reasons: []string{},
+		},
+	}
+	for _, tt := range tests {
+		score, reasons := duplicateScore(base, tt.other)
+		if score < tt.min || score > tt.max {
+			t.Errorf("%s: score = %.3f, want between %.2f and %.2f", tt.name, score, tt.min, tt.max)
+		}
+		if !reflect.DeepEqual(reasons, tt.reasons) {
+			t.Errorf("%s: reasons = %v, want %v", tt.name, reasons, tt.reasons)
+		}
+	}
+}
+
+func TestMergeAndRevertPatients(t *testing.T) {
+	db := useTestDB(t)
+	doctor := caller{id: 1, userType: "doctor"}
+
+	survivor := models.Patient{UserID: 10, Name: "Margaret Thompson", Address: "12 Elm Road"}
+	duplicate := models.Patient{UserID: 11, Name: "Margaret Thomson", Age: 81, Gender: "Female", Phone: "020 7946 0958", CaregiverID: 20}
+	db.Create(&survivor)
+	db.Create(&duplicate)
+	prescription := models.Prescription{PatientID: duplicate.ID, Medication: "Donepezil"}
+	db.Create(&prescription)
+	db.Create(&models.CareTeamMember{PatientID: survivor.ID, UserID: 30})
+	shared := models.CareTeamMember{PatientID: duplicate.ID, UserID: 30}
+	db.Create(&shared)
+	db.Create(&models.CareTeamMember{PatientID: duplicate.ID, UserID: 31})
+	appointment := models.Appointment{PatientID: 11, DoctorID: 1}
+	db.Create(&appointment)
+	series := models.AppointmentSeries{PatientID: 11, DoctorID: 1}
+	db.Create(&series)
+	entry := models.WaitlistEntry{PatientID: 11, DoctorID: 1, Status: "waiting"}
+	db.Create(&entry)
+
+	if w := serve(MergePatients, "POST", "/patients/merge", "/patients/merge", caller{id: 10, userType: "patient"},
+		MergePatientsRequest{SurvivorID: survivor.ID, DuplicateID: duplicate.ID}); w.Code != http.StatusForbidden {
+		t.Errorf("merge by a patient: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	w := serve(MergePatients, "POST", "/patients/merge", "/patients/merge", doctor,
+		MergePatientsRequest{SurvivorID: survivor.ID, DuplicateID: duplicate.ID})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("merge: status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+	var merge models.PatientMerge
+	decode(t, w, &merge)
+
+	patientOf := func(table string, id uint) uint {
+		var patientID uint
+		db.Table(table).Where("id = ?", id).Pluck("patient_id", &patientID)
+		return patientID
+	}
+	teamOf := func(patientID uint) []uint {
+		var users []uint
+		db.Model(&models.CareTeamMember{}).Where("patient_id = ?", patientID).Order("user_id").Pluck("user_id", &users)
+		return users
+	}
+
+	if got := patientOf("prescriptions", prescription.ID); got != survivor.ID {
+		t.Errorf("after merge: prescription belongs to patient %d, want %d", got, survivor.ID)
+	}
+	for table, id := range map[string]uint{"appointments": appointment.ID, "appointment_series": series.ID, "waitlist_entries": entry.ID} {
+		if got := patientOf(table, id); got != 10 {
+			t.Errorf("after merge: %s row belongs to user %d, want 10", table, got)
+		}
+	}
+	if got := teamOf(survivor.ID); !reflect.DeepEqual(got, []uint{30, 31}) {
+		t.Errorf("after merge: survivor's care team = %v, want [30 31] without duplicates", got)
+	}
+	var merged models.Patient
+	db.First(&merged, survivor.ID)
+	if merged.Phone != duplicate.Phone || merged.Age != 81 || merged.CaregiverID != 20 || merged.UserID != 10 {
+		t.Errorf("after merge: survivor = %+v, want blank fields filled from the duplicate", merged)
+	}
+	if err := db.First(&models.Patient{}, duplicate.ID).Error; err == nil {
+		t.Error("after merge: duplicate is still visible")
+	}
+
+	// Changes made after the merge survive its revert
+	db.Model(&models.Patient{}).Where("id = ?", survivor.ID).Update("phone", "07700 900123")
+	later := models.Prescription{PatientID: survivor.ID, Medication: "Memantine"}
+	db.Create(&later)
+
+	revert := func() int {
+		target := "/patients/merges/" + strconv.Itoa(int(merge.ID)) + "/revert"
+		return serve(RevertPatientMerge, "POST", "/patients/merges/:id/revert", target, doctor, nil).Code
+	}
+	if code := revert(); code != http.StatusOK {
+		t.Fatalf("revert: status = %d, want %d", code, http.StatusOK)
+	}
+
+	if got := patientOf("prescriptions", prescription.ID); got != duplicate.ID {
+		t.Errorf("after revert: prescription belongs to patient %d, want %d", got, duplicate.ID)
+	}
+	if got := patientOf("prescriptions", later.ID); got != survivor.ID {
+		t.Errorf("after revert: later prescription belongs to patient %d, want %d", got, survivor.ID)
+	}
+	for table, id := range map[string]uint{"appointments": appointment.ID, "appointment_series": series.ID, "waitlist_entries": entry.ID} {
+		if got := patientOf(table, id); got != 11 {
+			t.Errorf("after revert: %s row belongs to user %d, want 11", table, got)
+		}
+	}
+	if got := teamOf(survivor.ID); !reflect.DeepEqual(got, []uint{30}) {
+		t.Errorf("after revert: survivor's care team = %v, want [30]", got)
+	}
+	if got := teamOf(duplicate.ID); !reflect.DeepEqual(got, []uint{30, 31}) {
+		t.Errorf("after revert: duplicate's care team = %v, want [30 31]", got)
+	}
+	var reverted models.Patient
+	db.First(&reverted, survivor.ID)
+	if reverted.Phone != "07700 900123" || reverted.Age != 0 || reverted.Gender != "" || reverted.CaregiverID != 0 ||
+		reverted.Address != "12 Elm Road" || reverted.UserID != 10 {
+		t.Errorf("after revert: survivor = %+v, want filled fields blank and the later phone kept", reverted)
+	}
+	if err := db.First(&models.Patient{}, duplicate.ID).Error; err != nil {
+		t.Errorf("after revert: duplicate not restored: %v", err)
+	}
+
+	if code := revert(); code != http.StatusConflict {
+		t.Errorf("second revert: status = %d, want %d", code, http.StatusConflict)
+	}
+}
+
+func TestRevertMergeOfMergedSurvivor(t *testing.T) {
+	db := useTestDB(t)
+	doctor := caller{id: 1, userType: "doctor"}
+
+	patients := []models.Patient{{Name: "A"}, {Name: "B"}, {Name: "C"}}
+	db.Create(&patients)
+	merge := func(survivor, duplicate uint) models.PatientMerge {
+		w := serve(MergePatients, "POST", "/patients/merge", "/patients/merge", doctor,
+			MergePatientsRequest{SurvivorID: survivor, DuplicateID: duplicate})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("merge %d into %d: status = %d: %s", duplicate, survivor, w.Code, w.Body)
+		}
+		var m models.PatientMerge
+		decode(t, w, &m)
+		return m
+	}
+	revert := func(m models.PatientMerge) int {
+		target := "/patients/merges/" + strconv.Itoa(int(m.ID)) + "/revert"
+		return serve(RevertPatientMerge, "POST", "/patients/merges/:id/revert", target, doctor, nil).Code
+	}
+
+	first := merge(patients[0].ID, patients[1].ID)
+	second := merge(patients[2].ID, patients[0].ID)
+	if code := revert(first); code != http.StatusConflict {
+		t.Errorf("revert while the survivor is merged away: status = %d, want %d", code, http.StatusConflict)
+	}
+	if code := revert(second); code != http.StatusOK {
+		t.Errorf("revert of the later merge: status = %d, want %d", code, http.StatusOK)
+	}
+	if code := revert(first); code != http.StatusOK {
+		t.Errorf("revert once the survivor is back: status = %d, want %d", code, http.StatusOK)
+	}
+	if code := serve(RevertPatientMerge, "POST", "/patients/merges/:id/revert", "/patients/merges/99/revert", doctor, nil).Code; code != http.StatusNotFound {
+		t.Errorf("revert of an unknown merge: status = %d, want %d", code, http.StatusNotFound)
+	}
+}
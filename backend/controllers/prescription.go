@@ -2,8 +2,11 @@ package controllers
 
 import (
 	"dementicare-backend/config"
+	"dementicare-backend/interactions"
 	"dementicare-backend/models"
+	"log"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -48,10 +51,18 @@ func CreatePrescription(c *gin.Context) {
 	// Set doctor ID from authenticated user
 	prescription.DoctorID = c.GetUint("user_id")
 
+	if !checkPrescriptionAllergies(c, prescription) {
+		return
+	}
+	if !checkPrescriptionInteractions(c, &prescription, true) {
+		return
+	}
+
 	if err := config.DB.Create(&prescription).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create prescription"})
 		return
 	}
+	syncDerivedSchedule(prescription, prescription.DoctorID)
 
 	c.JSON(http.StatusCreated, prescription)
 }
@@ -65,15 +76,43 @@ func UpdatePrescription(c *gin.Context) {
 		return
 	}
 
+	// A new medication or patient needs its own override reason
+	medication, patientID, overrideReason := prescription.Medication, prescription.PatientID, prescription.AllergyOverrideReason
+	interactionOverride := prescription.InteractionOverrideReason
+	frequency, duration := prescription.Frequency, prescription.Duration
+	prescription.AllergyOverrideReason = ""
+	prescription.InteractionOverrideReason = ""
 	if err := c.ShouldBindJSON(&prescription); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	changed := prescription.Medication != medication || prescription.PatientID != patientID
+	if changed {
+		if !checkPrescriptionAllergies(c, prescription) {
+			return
+		}
+	} else {
+		if prescription.AllergyOverrideReason == "" {
+			prescription.AllergyOverrideReason = overrideReason
+		}
+		if prescription.InteractionOverrideReason == "" {
+			prescription.InteractionOverrideReason = interactionOverride
+		}
+	}
+	// Interactions are always reported, but only a new medication or
+	// patient needs an override
+	if !checkPrescriptionInteractions(c, &prescription, changed) {
+		return
+	}
+
 	if err := config.DB.Save(&prescription).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update prescription"})
 		return
 	}
+	if prescription.Frequency != frequency || prescription.Duration != duration || prescription.PatientID != patientID {
+		syncDerivedSchedule(prescription, c.GetUint("user_id"))
+	}
 
 	c.JSON(http.StatusOK, prescription)
 }
@@ -85,6 +124,67 @@ func DeletePrescription(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete prescription"})
 		return
 	}
+	config.DB.Where("prescription_id = ?", id).Delete(&models.MedicationSchedule{})
 
 	c.JSON(http.StatusOK, gin.H{"message": "Prescription deleted successfully"})
 }
+
+// checkPrescriptionAllergies rejects a prescription whose medication matches
+// one of the patient's allergies unless an override reason is given. It
+// writes the error response itself.
+func checkPrescriptionAllergies(c *gin.Context, prescription models.Prescription) bool {
+	conflicts, err := findAllergyConflicts(prescription.PatientID, prescription.Medication)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check allergies"})
+		return false
+	}
+
+	if len(conflicts) > 0 && strings.TrimSpace(prescription.AllergyOverrideReason) == "" {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":     "Patient is allergic to this medication; provide allergy_override_reason to prescribe anyway",
+			"allergies": conflicts,
+		})
+		return false
+	}
+
+	if len(conflicts) > 0 {
+		log.Printf("Allergy override - Patient: %d, Medication: %s, Doctor: %d", prescription.PatientID, prescription.Medication, c.GetUint("user_id"))
+	}
+	return true
+}
+
+// checkPrescriptionInteractions looks up interactions between the
+// prescription and the patient's other current prescriptions and lists them
+// on the prescription. With requireOverride, a major interaction is rejected
+// unless an override reason is given. It writes the error response itself.
+func checkPrescriptionInteractions(c *gin.Context, prescription *models.Prescription, requireOverride bool) bool {
+	current, err := currentPrescriptions(prescription.PatientID, prescription.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check drug interactions"})
+		return false
+	}
+	matches, err := findInteractions(prescription.Medication, current)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check drug interactions"})
+		return false
+	}
+
+	major := false
+	for _, match := range matches {
+		major = major || interactions.IsMajor(match.Interaction)
+	}
+
+	if requireOverride && major && strings.TrimSpace(prescription.InteractionOverrideReason) == "" {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":        "This medication has a major interaction with the patient's current medications; provide interaction_override_reason to prescribe anyway",
+			"interactions": matches,
+		})
+		return false
+	}
+
+	if requireOverride && major {
+		log.Printf("Interaction override - Patient: %d, Medication: %s, Doctor: %d", prescription.PatientID, prescription.Medication, c.GetUint("user_id"))
+	}
+	prescription.Interactions = matches
+	return true
+}
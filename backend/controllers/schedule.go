@@ -0,0 +1,278 @@
+package controllers
+
+import (
+	"dementicare-backend/config"
+	"dementicare-backend/models"
+	"dementicare-backend/scheduling"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const maxSlotRangeDays = 31
+
+type ScheduleExceptionRequest struct {
+	StartAt   time.Time `json:"start_at"`
+	EndAt     time.Time `json:"end_at"`
+	StartDate string    `json:"start_date"` // YYYY-MM-DD, whole days
+	EndDate   string    `json:"end_date"`   // inclusive
+	Reason    string    `json:"reason"`
+}
+
+func GetDoctorSchedule(c *gin.Context) {
+	doctor, ok := findDoctor(c)
+	if !ok {
+		return
+	}
+
+	var schedules []models.DoctorSchedule
+	if err := config.DB.Where("doctor_id = ?", doctor.ID).Order("weekday asc, start_time asc").Find(&schedules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch schedule"})
+		return
+	}
+
+	var durations []models.AppointmentTypeDuration
+	if err := config.DB.Where("doctor_id = ?", doctor.ID).Find(&durations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch appointment types"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"schedule":          schedules,
+		"appointment_types": durations,
+		"default_durations": scheduling.DefaultDurations,
+	})
+}
+
+// UpdateDoctorSchedule replaces the doctor's weekly working-hour template
+func UpdateDoctorSchedule(c *gin.Context) {
+	doctor, ok := findEditableDoctor(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Schedule []models.DoctorSchedule `json:"schedule"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for i := range req.Schedule {
+		block := &req.Schedule[i]
+		block.ID = 0
+		block.DoctorID = doctor.ID
+		if err := scheduling.ValidateSchedule(*block); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Block " + strconv.Itoa(i) + ": " + err.Error()})
+			return
+		}
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("doctor_id = ?", doctor.ID).Delete(&models.DoctorSchedule{}).Error; err != nil {
+			return err
+		}
+		if len(req.Schedule) == 0 {
+			return nil
+		}
+		return tx.Create(&req.Schedule).Error
+	})
+	if err != nil {
+		log.Printf("Error saving schedule for doctor %d: %v", doctor.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save schedule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"schedule": req.Schedule})
+}
+
+// UpdateAppointmentTypeDuration sets the doctor's slot length for one type
+func UpdateAppointmentTypeDuration(c *gin.Context) {
+	doctor, ok := findEditableDoctor(c)
+	if !ok {
+		return
+	}
+
+	var req models.AppointmentTypeDuration
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	appointmentType := scheduling.NormalizeType(req.Type)
+	if appointmentType == "" || req.DurationMinutes < 5 || req.DurationMinutes > 240 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Type is required and duration_minutes must be between 5 and 240"})
+		return
+	}
+
+	var duration models.AppointmentTypeDuration
+	config.DB.Where("doctor_id = ? AND type = ?", doctor.ID, appointmentType).Limit(1).Find(&duration)
+	duration.DoctorID = doctor.ID
+	duration.Type = appointmentType
+	duration.DurationMinutes = req.DurationMinutes
+
+	if err := config.DB.Save(&duration).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save appointment type"})
+		return
+	}
+
+	c.JSON(http.StatusOK, duration)
+}
+
+func GetScheduleExceptions(c *gin.Context) {
+	doctor, ok := findDoctor(c)
+	if !ok {
+		return
+	}
+
+	query := config.DB.Where("doctor_id = ?", doctor.ID)
+	if c.Query("include_past") != "true" {
+		query = query.Where("end_at > ?", time.Now())
+	}
+
+	var exceptions []models.ScheduleException
+	if err := query.Order("start_at asc").Find(&exceptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch schedule exceptions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"exceptions": exceptions})
+}
+
+func CreateScheduleException(c *gin.Context) {
+	doctor, ok := findEditableDoctor(c)
+	if !ok {
+		return
+	}
+
+	var req ScheduleExceptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	exception := models.ScheduleException{
+		DoctorID: doctor.ID,
+		StartAt:  req.StartAt,
+		EndAt:    req.EndAt,
+		Reason:   req.Reason,
+	}
+	if req.StartDate != "" {
+		start, err := time.ParseInLocation("2006-01-02", req.StartDate, scheduling.Location())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_date must be YYYY-MM-DD"})
+			return
+		}
+		end := start
+		if req.EndDate != "" {
+			if end, err = time.ParseInLocation("2006-01-02", req.EndDate, scheduling.Location()); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must be YYYY-MM-DD"})
+				return
+			}
+		}
+		exception.StartAt = start
+		exception.EndAt = end.AddDate(0, 0, 1)
+	}
+
+	if exception.StartAt.IsZero() || !exception.EndAt.After(exception.StartAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide start_at/end_at or start_date/end_date with the end after the start"})
+		return
+	}
+
+	if err := config.DB.Create(&exception).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create schedule exception"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, exception)
+}
+
+func DeleteScheduleException(c *gin.Context) {
+	doctor, ok := findEditableDoctor(c)
+	if !ok {
+		return
+	}
+
+	result := config.DB.Where("doctor_id = ?", doctor.ID).Delete(&models.ScheduleException{}, c.Param("exceptionId"))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete schedule exception"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Schedule exception not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Schedule exception deleted successfully"})
+}
+
+// GetDoctorSlots returns the doctor's free slots between from and to.
+// Both accept RFC3339 or YYYY-MM-DD; a date-only "to" includes that day.
+func GetDoctorSlots(c *gin.Context) {
+	doctor, ok := findDoctor(c)
+	if !ok {
+		return
+	}
+
+	from, err := parseDateParam(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from is required (RFC3339 or YYYY-MM-DD)"})
+		return
+	}
+	to, err := parseDateParam(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to is required (RFC3339 or YYYY-MM-DD)"})
+		return
+	}
+	if len(c.Query("to")) == len("2006-01-02") {
+		to = to.AddDate(0, 0, 1)
+	}
+	if !to.After(from) || to.Sub(from) > maxSlotRangeDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be after from and at most 31 days later"})
+		return
+	}
+
+	appointmentType := c.DefaultQuery("type", "consultation")
+	slots, err := scheduling.FreeSlots(config.DB, doctor.ID, appointmentType, from, to)
+	if err != nil {
+		log.Printf("Error computing slots for doctor %d: %v", doctor.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute available slots"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"doctor_id":        doctor.ID,
+		"type":             scheduling.NormalizeType(appointmentType),
+		"duration_minutes": int(scheduling.Duration(config.DB, doctor.ID, appointmentType) / time.Minute),
+		"slots":            slots,
+	})
+}
+
+func findDoctor(c *gin.Context) (models.User, bool) {
+	var doctor models.User
+	if err := config.DB.Where("user_type = ?", "doctor").First(&doctor, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Doctor not found"})
+		return doctor, false
+	}
+	return doctor, true
+}
+
+// findEditableDoctor loads the doctor and checks that the caller is that
+// doctor or an admin.
+func findEditableDoctor(c *gin.Context) (models.User, bool) {
+	doctor, ok := findDoctor(c)
+	if !ok {
+		return doctor, false
+	}
+
+	if c.GetString("user_type") != "admin" && c.GetUint("user_id") != doctor.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the doctor or an admin can change this schedule"})
+		return doctor, false
+	}
+	return doctor, true
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"bytes"
+	"dementicare-backend/config"
+	"dementicare-backend/testdb"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// useTestDB points config.DB at a fresh database with every table for the
+// duration of the test
+func useTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	previous := config.DB
+	config.DB = testdb.Open(t, config.Models...)
+	t.Cleanup(func() { config.DB = previous })
+	return config.DB
+}
+
+// caller identifies the authenticated user of a test request, as the auth
+// middleware would
+type caller struct {
+	id       uint
+	userType string
+}
+
+// upload is a multipart/form-data request body with one file
+type upload struct {
+	field, filename string
+	content         []byte
+	values          map[string]string
+}
+
+// serve runs one request through handler, mounted at pattern, as caller and
+// returns the response. A non-nil body is sent as JSON, an upload as a form
+// and an io.Reader as is.
+func serve(handler gin.HandlerFunc, method, pattern, target string, as caller, body interface{}) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Handle(method, pattern, func(c *gin.Context) {
+		c.Set("user_id", as.id)
+		c.Set("user_type", as.userType)
+	}, handler)
+
+	contentType := "application/json"
+	var reader io.Reader
+	switch body := body.(type) {
+	case upload:
+		var buf bytes.Buffer
+		form := multipart.NewWriter(&buf)
+		for name, value := range body.values {
+			form.WriteField(name, value)
+		}
+		part, _ := form.CreateFormFile(body.field, body.filename)
+		part.Write(body.content)
+		form.Close()
+		reader, contentType = &buf, form.FormDataContentType()
+	case io.Reader:
+		// Streamed with no content length, as a chunked upload is
+		reader = body
+	default:
+		var data []byte
+		if body != nil {
+			data, _ = json.Marshal(body)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// decode unmarshals a JSON response body
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding response %q: %v", w.Body, err)
+	}
+}
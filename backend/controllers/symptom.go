@@ -0,0 +1,274 @@
+package controllers
+
+import (
+	"dementicare-backend/config"
+	"dementicare-backend/models"
+	"dementicare-backend/scheduling"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultSymptomTrendWeeks = 8
+	maxSymptomTrendWeeks     = 52
+)
+
+var symptomTypes = map[string]bool{
+	"agitation":      true,
+	"sundowning":     true,
+	"aggression":     true,
+	"hallucinations": true,
+	"wandering":      true,
+	"other":          true,
+}
+
+type SymptomWeek struct {
+	WeekStart       time.Time `json:"week_start"`
+	Episodes        int       `json:"episodes"`
+	AverageSeverity *float64  `json:"average_severity"`
+	TotalMinutes    int       `json:"total_minutes"`
+	ChangePercent   *float64  `json:"change_percent"` // episodes vs previous week
+}
+
+func GetSymptomEpisodes(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var episodes []models.SymptomEpisode
+	if err := symptomQuery(c, patient.ID).Order("onset_at desc").Find(&episodes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch symptom episodes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"episodes": episodes})
+}
+
+func CreateSymptomEpisode(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var episode models.SymptomEpisode
+	if err := c.ShouldBindJSON(&episode); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	episode.ID = 0
+	episode.PatientID = patient.ID
+	episode.RecordedBy = c.GetUint("user_id")
+	if episode.OnsetAt.IsZero() {
+		episode.OnsetAt = time.Now()
+	}
+	if msg := validateSymptomEpisode(episode); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := config.DB.Create(&episode).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save symptom episode"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, episode)
+}
+
+func UpdateSymptomEpisode(c *gin.Context) {
+	var episode models.SymptomEpisode
+	if err := config.DB.First(&episode, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Symptom episode not found"})
+		return
+	}
+
+	if _, ok := findAccessiblePatient(c, episode.PatientID); !ok {
+		return
+	}
+
+	id, patientID, recordedBy := episode.ID, episode.PatientID, episode.RecordedBy
+	if err := c.ShouldBindJSON(&episode); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	episode.ID, episode.PatientID, episode.RecordedBy = id, patientID, recordedBy
+
+	if msg := validateSymptomEpisode(episode); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := config.DB.Save(&episode).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update symptom episode"})
+		return
+	}
+
+	c.JSON(http.StatusOK, episode)
+}
+
+func DeleteSymptomEpisode(c *gin.Context) {
+	var episode models.SymptomEpisode
+	if err := config.DB.First(&episode, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Symptom episode not found"})
+		return
+	}
+
+	if _, ok := findAccessiblePatient(c, episode.PatientID); !ok {
+		return
+	}
+
+	if err := config.DB.Delete(&episode).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete symptom episode"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Symptom episode deleted successfully"})
+}
+
+// GetSymptomTimeOfDay counts episodes by hour of onset and by part of day in
+// the clinic time zone, which shows patterns such as late-afternoon
+// sundowning.
+func GetSymptomTimeOfDay(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var episodes []models.SymptomEpisode
+	if err := symptomQuery(c, patient.ID).Find(&episodes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch symptom episodes"})
+		return
+	}
+
+	byHour := make([]int, 24)
+	byPart := map[string]int{"morning": 0, "afternoon": 0, "evening": 0, "night": 0}
+	bySymptom := map[string][]int{}
+	for _, e := range episodes {
+		hour := e.OnsetAt.In(scheduling.Location()).Hour()
+		byHour[hour]++
+		byPart[partOfDay(hour)]++
+		if bySymptom[e.Symptom] == nil {
+			bySymptom[e.Symptom] = make([]int, 24)
+		}
+		bySymptom[e.Symptom][hour]++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":       len(episodes),
+		"by_hour":     byHour,
+		"by_part":     byPart,
+		"by_symptom":  bySymptom,
+		"peak_hour":   peakIndex(byHour),
+		"part_ranges": gin.H{"morning": "06-12", "afternoon": "12-18", "evening": "18-22", "night": "22-06"},
+	})
+}
+
+// GetSymptomWeeklyTrend returns per-week episode counts and severity for the
+// last N weeks, with the change in episode count against the previous week.
+func GetSymptomWeeklyTrend(c *gin.Context) {
+	patient, ok := findAccessiblePatient(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	weeks, err := strconv.Atoi(c.DefaultQuery("weeks", strconv.Itoa(defaultSymptomTrendWeeks)))
+	if err != nil || weeks <= 0 || weeks > maxSymptomTrendWeeks {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "weeks must be between 1 and 52"})
+		return
+	}
+
+	since := periodStart(time.Now(), "week").AddDate(0, 0, -7*(weeks-1))
+
+	query := config.DB.Where("patient_id = ? AND onset_at >= ?", patient.ID, since)
+	if symptom := c.Query("symptom"); symptom != "" {
+		query = query.Where("symptom = ?", symptom)
+	}
+
+	var episodes []models.SymptomEpisode
+	if err := query.Find(&episodes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch symptom episodes"})
+		return
+	}
+
+	series := make([]SymptomWeek, weeks)
+	severity := make([]averager, weeks)
+	for i := range series {
+		series[i].WeekStart = since.AddDate(0, 0, 7*i)
+	}
+	for _, e := range episodes {
+		i := int(math.Round(periodStart(e.OnsetAt, "week").Sub(since).Hours()/24)) / 7
+		if i < 0 || i >= weeks {
+			continue
+		}
+		series[i].Episodes++
+		series[i].TotalMinutes += e.DurationMinutes
+		severity[i].add(float64(e.Severity))
+	}
+	for i := range series {
+		series[i].AverageSeverity = severity[i].mean()
+		if i > 0 && series[i-1].Episodes > 0 {
+			change := float64(series[i].Episodes-series[i-1].Episodes) / float64(series[i-1].Episodes) * 100
+			series[i].ChangePercent = &change
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"weeks": series, "symptom": c.Query("symptom")})
+}
+
+func symptomQuery(c *gin.Context, patientID uint) *gorm.DB {
+	query := config.DB.Where("patient_id = ?", patientID)
+	if symptom := c.Query("symptom"); symptom != "" {
+		query = query.Where("symptom = ?", symptom)
+	}
+	if from, err := parseDateParam(c.Query("from")); err == nil {
+		query = query.Where("onset_at >= ?", from)
+	}
+	if to, err := parseDateParam(c.Query("to")); err == nil {
+		query = query.Where("onset_at <= ?", to)
+	}
+	return query
+}
+
+func validateSymptomEpisode(episode models.SymptomEpisode) string {
+	if !symptomTypes[episode.Symptom] {
+		return "Symptom must be one of agitation, sundowning, aggression, hallucinations, wandering, other"
+	}
+	if episode.Severity < 1 || episode.Severity > 5 {
+		return "Severity must be between 1 and 5"
+	}
+	if episode.DurationMinutes < 0 {
+		return "Duration cannot be negative"
+	}
+	return ""
+}
+
+func partOfDay(hour int) string {
+	switch {
+	case hour >= 6 && hour < 12:
+		return "morning"
+	case hour >= 12 && hour < 18:
+		return "afternoon"
+	case hour >= 18 && hour < 22:
+		return "evening"
+	}
+	return "night"
+}
+
+func peakIndex(counts []int) *int {
+	peak := -1
+	for i, n := range counts {
+		if n > 0 && (peak < 0 || n > counts[peak]) {
+			peak = i
+		}
+	}
+	if peak < 0 {
+		return nil
+	}
+	return &peak
+}
@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"dementicare-backend/models"
+	"dementicare-backend/scheduling"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCreateSymptomEpisode(t *testing.T) {
+	db := useTestDB(t)
+	patient := models.Patient{UserID: 10, Name: "Edith", CaregiverID: 20}
+	db.Create(&patient)
+	target := "/patients/" + strconv.Itoa(int(patient.ID)) + "/symptoms"
+	asCaregiver := caller{id: 20, userType: "caregiver"}
+
+	tests := []struct {
+		name   string
+		as     caller
+		body   map[string]interface{}
+		status int
+	}{
+		{"unrelated caregiver", caller{id: 21, userType: "caregiver"}, map[string]interface{}{"symptom": "agitation", "severity": 3}, http.StatusForbidden},
+		{"unknown symptom", asCaregiver, map[string]interface{}{"symptom": "fatigue", "severity": 3}, http.StatusBadRequest},
+		{"no severity", asCaregiver, map[string]interface{}{"symptom": "agitation"}, http.StatusBadRequest},
+		{"severity too high", asCaregiver, map[string]interface{}{"symptom": "agitation", "severity": 6}, http.StatusBadRequest},
+		{"negative duration", asCaregiver, map[string]interface{}{"symptom": "agitation", "severity": 3, "duration_minutes": -5}, http.StatusBadRequest},
+		{"valid", asCaregiver, map[string]interface{}{"symptom": "sundowning", "severity": 4, "duration_minutes": 45, "triggers": []string{"noise"}, "interventions": []string{"music"}}, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		w := serve(CreateSymptomEpisode, "POST", "/patients/:id/symptoms", target, tt.as, tt.body)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+		}
+	}
+
+	var episode models.SymptomEpisode
+	db.First(&episode)
+	if episode.OnsetAt.IsZero() || episode.RecordedBy != 20 || len(episode.Triggers) != 1 || len(episode.Interventions) != 1 {
+		t.Errorf("stored %+v, want an onset time, the recorder, a trigger and an intervention", episode)
+	}
+
+	episodeTarget := "/symptoms/" + strconv.Itoa(int(episode.ID))
+	if w := serve(UpdateSymptomEpisode, "PUT", "/symptoms/:id", episodeTarget, caller{id: 21, userType: "caregiver"}, map[string]interface{}{"symptom": "agitation", "severity": 1}); w.Code != http.StatusForbidden {
+		t.Errorf("unrelated caregiver update status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	w := serve(UpdateSymptomEpisode, "PUT", "/symptoms/:id", episodeTarget, caller{id: 1, userType: "doctor"}, map[string]interface{}{"symptom": "sundowning", "severity": 2, "patient_id": 99})
+	if w.Code != http.StatusOK {
+		t.Fatalf("doctor update status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	db.First(&episode, episode.ID)
+	if episode.Severity != 2 || episode.PatientID != patient.ID || episode.RecordedBy != 20 {
+		t.Errorf("updated episode %+v, want severity 2 with the patient and recorder kept", episode)
+	}
+}
+
+func TestSymptomTimeOfDay(t *testing.T) {
+	db := useTestDB(t)
+	patient := models.Patient{UserID: 10, Name: "Edith", CaregiverID: 20}
+	db.Create(&patient)
+	at := func(day, hour int) time.Time { return time.Date(2026, 3, day, hour, 30, 0, 0, scheduling.Location()) }
+	for _, episode := range []models.SymptomEpisode{
+		{Symptom: "sundowning", Severity: 3, OnsetAt: at(2, 17)},
+		{Symptom: "sundowning", Severity: 4, OnsetAt: at(3, 17)},
+		{Symptom: "agitation", Severity: 2, OnsetAt: at(3, 19)},
+		{Symptom: "wandering", Severity: 5, OnsetAt: at(4, 2)},
+		{Symptom: "agitation", Severity: 2, OnsetAt: at(20, 9)},
+	} {
+		episode.PatientID = patient.ID
+		db.Create(&episode)
+	}
+	target := "/patients/" + strconv.Itoa(int(patient.ID)) + "/symptoms/time-of-day"
+
+	w := serve(GetSymptomTimeOfDay, "GET", "/patients/:id/symptoms/time-of-day", target+"?to=2026-03-10", caller{id: 20, userType: "caregiver"}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var resp struct {
+		Total     int              `json:"total"`
+		ByHour    []int            `json:"by_hour"`
+		ByPart    map[string]int   `json:"by_part"`
+		BySymptom map[string][]int `json:"by_symptom"`
+		PeakHour  *int             `json:"peak_hour"`
+	}
+	decode(t, w, &resp)
+	if resp.Total != 4 || resp.ByHour[17] != 2 || resp.ByHour[19] != 1 || resp.ByHour[2] != 1 {
+		t.Errorf("total %d by hour %v, want 4 episodes at 02, 17, 17 and 19", resp.Total, resp.ByHour)
+	}
+	if resp.ByPart["afternoon"] != 2 || resp.ByPart["evening"] != 1 || resp.ByPart["night"] != 1 || resp.ByPart["morning"] != 0 {
+		t.Errorf("by part = %v", resp.ByPart)
+	}
+	if resp.PeakHour == nil || *resp.PeakHour != 17 || resp.BySymptom["sundowning"][17] != 2 {
+		t.Errorf("peak hour %v, sundowning by hour %v, want a peak at 17", resp.PeakHour, resp.BySymptom["sundowning"])
+	}
+
+	if w := serve(GetSymptomTimeOfDay, "GET", "/patients/:id/symptoms/time-of-day", target, caller{id: 11, userType: "patient"}, nil); w.Code != http.StatusForbidden {
+		t.Errorf("other patient status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestSymptomWeeklyTrend(t *testing.T) {
+	db := useTestDB(t)
+	patient := models.Patient{UserID: 10, Name: "Edith", CaregiverID: 20}
+	db.Create(&patient)
+	thisWeek := periodStart(time.Now(), "week")
+	weekAt := func(i int) time.Time { return thisWeek.AddDate(0, 0, 7*(i-2)).Add(time.Hour) }
+	for _, episode := range []models.SymptomEpisode{
+		{Symptom: "agitation", Severity: 2, DurationMinutes: 10, OnsetAt: weekAt(0)},
+		{Symptom: "agitation", Severity: 4, DurationMinutes: 20, OnsetAt: weekAt(0)},
+		{Symptom: "agitation", Severity: 3, DurationMinutes: 15, OnsetAt: weekAt(1)},
+		{Symptom: "wandering", Severity: 5, DurationMinutes: 60, OnsetAt: weekAt(2)},
+		{Symptom: "agitation", Severity: 5, OnsetAt: weekAt(-1)}, // before the window
+	} {
+		episode.PatientID = patient.ID
+		db.Create(&episode)
+	}
+	target := "/patients/" + strconv.Itoa(int(patient.ID)) + "/symptoms/weekly-trend"
+	asDoctor := caller{id: 1, userType: "doctor"}
+
+	for _, query := range []string{"?weeks=0", "?weeks=53", "?weeks=few"} {
+		if w := serve(GetSymptomWeeklyTrend, "GET", "/patients/:id/symptoms/weekly-trend", target+query, asDoctor, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+	}
+
+	pct := func(v float64) *float64 { return &v }
+	tests := []struct {
+		query    string
+		episodes []int
+		change   []*float64
+	}{
+		{"?weeks=3", []int{2, 1, 1}, []*float64{nil, pct(-50), pct(0)}},
+		{"?weeks=3&symptom=agitation", []int{2, 1, 0}, []*float64{nil, pct(-50), pct(-100)}},
+	}
+	for _, tt := range tests {
+		w := serve(GetSymptomWeeklyTrend, "GET", "/patients/:id/symptoms/weekly-trend", target+tt.query, asDoctor, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want %d: %s", tt.query, w.Code, http.StatusOK, w.Body)
+		}
+		var resp struct {
+			Weeks []SymptomWeek `json:"weeks"`
+		}
+		decode(t, w, &resp)
+		if len(resp.Weeks) != len(tt.episodes) {
+			t.Fatalf("%s: got %d weeks, want %d", tt.query, len(resp.Weeks), len(tt.episodes))
+		}
+		for i, week := range resp.Weeks {
+			if week.Episodes != tt.episodes[i] {
+				t.Errorf("%s: week %d has %d episodes, want %d", tt.query, i, week.Episodes, tt.episodes[i])
+			}
+			if got, want := week.ChangePercent, tt.change[i]; (got == nil) != (want == nil) || (got != nil && *got != *want) {
+				t.Errorf("%s: week %d change = %v, want %v", tt.query, i, got, want)
+			}
+		}
+		if first := resp.Weeks[0]; first.TotalMinutes != 30 || first.AverageSeverity == nil || *first.AverageSeverity != 3 {
+			t.Errorf("%s: first week = %+v, want 30 minutes at severity 3", tt.query, first)
+		}
+	}
+}
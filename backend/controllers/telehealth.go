@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"dementicare-backend/config"
+	"dementicare-backend/models"
+	"dementicare-backend/scheduling"
+	"dementicare-backend/settings"
+	"dementicare-backend/telehealth"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+var telehealthHub = telehealth.NewHub()
+
+const (
+	defaultEarlyJoin = 15 * time.Minute
+	defaultJoinGrace = 30 * time.Minute
+	defaultICEServer = "stun:stun.l.google.com:19302"
+)
+
+// JoinVisit issues a room token for a virtual appointment to its doctor
+// (the host), its patient or the patient's caregiver. Tokens can be
+// requested from TELEHEALTH_EARLY_JOIN before the start until
+// TELEHEALTH_GRACE after the end, and expire then.
+func JoinVisit(c *gin.Context) {
+	var appointment models.Appointment
+	if err := config.DB.First(&appointment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	role := appointmentRole(c, appointment)
+	if role != "doctor" && role != "patient" && role != "caregiver" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the doctor, patient or caregiver can join this visit"})
+		return
+	}
+
+	if appointment.Modality != "virtual" {
+		c.JSON(http.StatusConflict, gin.H{"error": "This is not a virtual appointment"})
+		return
+	}
+	if appointment.Status != "confirmed" {
+		c.JSON(http.StatusConflict, gin.H{"error": "The visit can be joined once the appointment is confirmed"})
+		return
+	}
+
+	window := scheduling.AppointmentWindow(appointment)
+	opensAt := window.Start.Add(-settings.Duration("TELEHEALTH_EARLY_JOIN", defaultEarlyJoin))
+	closesAt := window.End.Add(settings.Duration("TELEHEALTH_GRACE", defaultJoinGrace))
+	now := time.Now()
+	if now.Before(opensAt) || now.After(closesAt) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The visit is not open", "opens_at": opensAt, "closes_at": closesAt})
+		return
+	}
+
+	var user models.User
+	config.DB.Select("id, name").First(&user, userID)
+
+	roomRole := telehealth.RoleGuest
+	if role == "doctor" {
+		roomRole = telehealth.RoleHost
+	}
+	claims := telehealth.RoomClaims{
+		Room:          "appointment-" + uintToString(appointment.ID),
+		AppointmentID: appointment.ID,
+		UserID:        userID,
+		Name:          user.Name,
+		Role:          roomRole,
+	}
+	secret := telehealthSecret()
+	if secret == nil {
+		log.Printf("Telehealth token refused - Appointment: %d: neither TELEHEALTH_SECRET nor JWT_SECRET is set", appointment.ID)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Virtual visits are not configured"})
+		return
+	}
+	token, err := telehealth.IssueToken(secret, claims, closesAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue room token"})
+		return
+	}
+
+	log.Printf("Telehealth token issued - Appointment: %d, User: %d, Role: %s", appointment.ID, userID, roomRole)
+	c.JSON(http.StatusOK, gin.H{
+		"token":       token,
+		"room":        claims.Room,
+		"role":        roomRole,
+		"expires_at":  closesAt,
+		"ws_url":      telehealthSocketURL(c, token),
+		"ice_servers": []gin.H{{"urls": iceServers()}},
+	})
+}
+
+// TelehealthSocket is the signaling WebSocket. Browsers cannot send headers
+// when opening a socket, so the room token comes in the query string.
+func TelehealthSocket(c *gin.Context) {
+	secret := telehealthSecret()
+	if secret == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Virtual visits are not configured"})
+		return
+	}
+	claims, err := telehealth.ParseToken(secret, c.Query("token"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired room token"})
+		return
+	}
+
+	// The appointment may have been cancelled since the token was issued
+	var appointment models.Appointment
+	if err := config.DB.First(&appointment, claims.AppointmentID).Error; err != nil ||
+		appointment.Modality != "virtual" || appointment.Status != "confirmed" {
+		c.JSON(http.StatusConflict, gin.H{"error": "This visit is no longer available"})
+		return
+	}
+
+	server := websocket.Server{
+		// The token is the credential; any origin may connect
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler:   func(conn *websocket.Conn) { telehealthHub.Serve(conn, claims) },
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// normalizeModality defaults an empty modality to in_person and rejects
+// unknown ones
+func normalizeModality(modality string) (string, bool) {
+	switch modality = strings.ToLower(strings.TrimSpace(modality)); modality {
+	case "":
+		return "in_person", true
+	case "in_person", "virtual":
+		return modality, true
+	}
+	return modality, false
+}
+
+// telehealthSecret signs room tokens (TELEHEALTH_SECRET, or one derived from
+// JWT_SECRET). It is never the API secret itself, so a room token cannot be
+// used as a login. Without either it returns nil and no tokens are issued or
+// accepted, since a key anyone can guess would let them forge a host token.
+func telehealthSecret() []byte {
+	if secret := os.Getenv("TELEHEALTH_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		return []byte(secret + ":telehealth")
+	}
+	return nil
+}
+
+func telehealthSocketURL(c *gin.Context, token string) string {
+	base := publicBaseURL(c)
+	base = strings.Replace(base, "http", "ws", 1)
+	return base + "/telehealth/ws?token=" + url.QueryEscape(token)
+}
+
+// iceServers lists the STUN/TURN URLs for clients (TELEHEALTH_ICE_SERVERS,
+// comma separated)
+func iceServers() []string {
+	value := os.Getenv("TELEHEALTH_ICE_SERVERS")
+	if value == "" {
+		return []string{defaultICEServer}
+	}
+	var servers []string
+	for _, server := range strings.Split(value, ",") {
+		if server = strings.TrimSpace(server); server != "" {
+			servers = append(servers, server)
+		}
+	}
+	return servers
+}
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"dementicare-backend/models"
+	"dementicare-backend/telehealth"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestJoinVisit(t *testing.T) {
+	db := useTestDB(t)
+	t.Setenv("TELEHEALTH_SECRET", "room-secret")
+	db.Create(&models.Patient{UserID: 10, Name: "Edith", CaregiverID: 20})
+	soon := time.Now().Add(10 * time.Minute)
+	visit := func(modality, status string, start time.Time) string {
+		appointment := models.Appointment{PatientID: 10, DoctorID: 1, StartAt: start, EndAt: start.Add(30 * time.Minute), Modality: modality, Status: status}
+		db.Create(&appointment)
+		return "/appointments/" + strconv.Itoa(int(appointment.ID)) + "/join"
+	}
+	open := visit("virtual", "confirmed", soon)
+
+	tests := []struct {
+		name   string
+		target string
+		as     caller
+		status int
+		role   string
+	}{
+		{"doctor", open, caller{id: 1, userType: "doctor"}, http.StatusOK, telehealth.RoleHost},
+		{"patient", open, caller{id: 10, userType: "patient"}, http.StatusOK, telehealth.RoleGuest},
+		{"caregiver", open, caller{id: 20, userType: "caregiver"}, http.StatusOK, telehealth.RoleGuest},
+		{"other doctor", open, caller{id: 2, userType: "doctor"}, http.StatusForbidden, ""},
+		{"other caregiver", open, caller{id: 21, userType: "caregiver"}, http.StatusForbidden, ""},
+		{"admin", open, caller{id: 99, userType: "admin"}, http.StatusForbidden, ""},
+		{"in person", visit("in_person", "confirmed", soon), caller{id: 10, userType: "patient"}, http.StatusConflict, ""},
+		{"pending", visit("virtual", "pending", soon), caller{id: 10, userType: "patient"}, http.StatusConflict, ""},
+		{"too early", visit("virtual", "confirmed", time.Now().Add(2*time.Hour)), caller{id: 10, userType: "patient"}, http.StatusConflict, ""},
+		{"long over", visit("virtual", "confirmed", time.Now().Add(-2*time.Hour)), caller{id: 10, userType: "patient"}, http.StatusConflict, ""},
+	}
+	for _, tt := range tests {
+		w := serve(JoinVisit, "POST", "/appointments/:id/join", tt.target, tt.as, nil)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		var resp struct {
+			Token string `json:"token"`
+		}
+		decode(t, w, &resp)
+		claims, err := telehealth.ParseToken([]byte("room-secret"), resp.Token)
+		if err != nil || claims.Role != tt.role || claims.UserID != tt.as.id || claims.Room != "appointment-1" {
+			t.Errorf("%s: token claims %+v, %v, want %s of appointment-1", tt.name, claims, err, tt.role)
+		}
+	}
+
+	// Without a secret a forged host token could not be told apart
+	t.Setenv("TELEHEALTH_SECRET", "")
+	t.Setenv("JWT_SECRET", "")
+	if w := serve(JoinVisit, "POST", "/appointments/:id/join", open, caller{id: 1, userType: "doctor"}, nil); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("no secret: status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestTelehealthSocketRefusal(t *testing.T) {
+	db := useTestDB(t)
+	t.Setenv("TELEHEALTH_SECRET", "room-secret")
+	start := time.Now()
+	cancelled := models.Appointment{PatientID: 10, DoctorID: 1, StartAt: start, EndAt: start.Add(30 * time.Minute), Modality: "virtual", Status: "cancelled"}
+	db.Create(&cancelled)
+	token := func(secret string) string {
+		claims := telehealth.RoomClaims{Room: "appointment-1", AppointmentID: cancelled.ID, UserID: 10, Role: telehealth.RoleGuest}
+		signed, _ := telehealth.IssueToken([]byte(secret), claims, time.Now().Add(time.Hour))
+		return signed
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"other secret", token("api-secret"), http.StatusUnauthorized},
+		{"cancelled visit", token("room-secret"), http.StatusConflict},
+	}
+	for _, tt := range tests {
+		w := serve(TelehealthSocket, "GET", "/telehealth/ws", "/telehealth/ws?token="+tt.token, caller{}, nil)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package controllers
+
+import (
+	"dementicare-backend/config"
+	"dementicare-backend/models"
+	"dementicare-backend/scheduling"
+	"dementicare-backend/waitlist"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errOfferClosed = errors.New("offer is no longer open")
+
+type WaitlistRequest struct {
+	DoctorID     uint      `json:"doctor_id" binding:"required"`
+	Type         string    `json:"type"`
+	EarliestDate time.Time `json:"earliest_date" binding:"required"`
+	LatestDate   time.Time `json:"latest_date" binding:"required"`
+	Notes        string    `json:"notes"`
+}
+
+// JoinWaitlist puts the patient on a doctor's waitlist for a date window
+func JoinWaitlist(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if c.GetString("user_type") != "patient" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only patients can join a waitlist"})
+		return
+	}
+
+	var req WaitlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	earliest, latest := localDay(req.EarliestDate), localDay(req.LatestDate)
+	if latest.Before(earliest) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "latest_date must not be before earliest_date"})
+		return
+	}
+	if latest.Before(localDay(time.Now().In(scheduling.Location()))) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The date window is in the past"})
+		return
+	}
+
+	var doctor models.User
+	if err := config.DB.Where("user_type = ?", "doctor").First(&doctor, req.DoctorID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Doctor not found"})
+		return
+	}
+
+	var existing int64
+	config.DB.Model(&models.WaitlistEntry{}).
+		Where("patient_id = ? AND doctor_id = ? AND status IN ?", userID, req.DoctorID, []string{"waiting", "offered"}).
+		Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "You are already on this doctor's waitlist"})
+		return
+	}
+
+	entry := models.WaitlistEntry{
+		PatientID:    userID,
+		DoctorID:     req.DoctorID,
+		Type:         req.Type,
+		EarliestDate: earliest,
+		LatestDate:   latest,
+		Notes:        req.Notes,
+		Status:       "waiting",
+	}
+	if err := config.DB.Create(&entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join waitlist"})
+		return
+	}
+
+	log.Printf("Waitlist entry created - ID: %d, Patient: %d, Doctor: %d", entry.ID, userID, req.DoctorID)
+	c.JSON(http.StatusCreated, entry)
+}
+
+// GetWaitlist lists entries: patients see their own, doctors their waitlist,
+// caregivers those of the patients they care for and admins all
+func GetWaitlist(c *gin.Context) {
+	query, ok := waitlistScope(c, config.DB.Model(&models.WaitlistEntry{}))
+	if !ok {
+		return
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var entries []models.WaitlistEntry
+	if err := query.Order("created_at asc, id asc").Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch waitlist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// LeaveWaitlist removes the patient from the waitlist. An open offer is
+// withdrawn and passed on.
+func LeaveWaitlist(c *gin.Context) {
+	var entry models.WaitlistEntry
+	if err := config.DB.First(&entry, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waitlist entry not found"})
+		return
+	}
+	if entry.PatientID != c.GetUint("user_id") && c.GetString("user_type") != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only leave your own waitlist entries"})
+		return
+	}
+	if entry.Status != "waiting" && entry.Status != "offered" {
+		c.JSON(http.StatusConflict, gin.H{"error": "This waitlist entry is already closed"})
+		return
+	}
+
+	var offers []models.SlotOffer
+	config.DB.Where("entry_id = ? AND status = ?", entry.ID, "offered").Find(&offers)
+	for _, offer := range offers {
+		if _, err := waitlist.CloseOffer(config.DB, offer, "withdrawn"); err != nil {
+			log.Printf("Error withdrawing offer %d: %v", offer.ID, err)
+		}
+	}
+
+	entry.Status = "cancelled"
+	if err := config.DB.Model(&entry).Update("status", entry.Status).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave waitlist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Removed from waitlist"})
+}
+
+// GetWaitlistOffers lists slot offers made to the caller (or, for doctors,
+// on their calendar)
+func GetWaitlistOffers(c *gin.Context) {
+	query, ok := waitlistScope(c, config.DB.Model(&models.SlotOffer{}))
+	if !ok {
+		return
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var offers []models.SlotOffer
+	if err := query.Order("created_at desc").Find(&offers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch offers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"offers": offers})
+}
+
+// AcceptWaitlistOffer books the held slot for the patient
+func AcceptWaitlistOffer(c *gin.Context) {
+	offer, ok := findOwnOffer(c)
+	if !ok {
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	appointment := models.Appointment{
+		PatientID: offer.PatientID,
+		DoctorID:  offer.DoctorID,
+		StartAt:   offer.StartAt,
+		Type:      offer.Type,
+		BookedBy:  c.GetUint("user_id"),
+		Status:    "pending",
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := scheduling.LockDoctor(tx, offer.DoctorID); err != nil {
+			return err
+		}
+
+		// Closing the offer first releases its hold for the booking below
+		result := tx.Model(&models.SlotOffer{}).Where("id = ? AND status = ? AND expires_at > ?", offer.ID, "offered", time.Now()).
+			Updates(map[string]interface{}{"status": "accepted", "responded_at": time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errOfferClosed
+		}
+
+		if err := scheduling.Reserve(tx, &appointment, 0); err != nil {
+			return err
+		}
+		if err := applyNoShowPolicy(tx, &appointment, userID); err != nil {
+			return err
+		}
+		if err := tx.Create(&appointment).Error; err != nil {
+			return err
+		}
+		if err := recordStatusChange(tx, appointment.ID, "", "pending", userID, "Booked from waitlist offer "+uintToString(offer.ID)); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.SlotOffer{}).Where("id = ?", offer.ID).Update("appointment_id", appointment.ID).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.WaitlistEntry{}).Where("id = ?", offer.EntryID).
+			Updates(map[string]interface{}{"status": "booked", "appointment_id": appointment.ID}).Error
+	})
+	if errors.Is(err, errOfferClosed) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This offer has expired or was already answered"})
+		return
+	}
+	if err != nil {
+		respondBookingError(c, err, appointment, "Failed to accept offer")
+		return
+	}
+
+	if appointment.NeedsCaregiver && appointment.CaregiverConfirmBy == nil {
+		requestCaregiverConfirmation(appointment.PatientID, "appointment:"+uintToString(appointment.ID),
+			"an appointment on "+patientTime(appointment.PatientID, appointment.StartAt))
+	}
+
+	log.Printf("Waitlist offer accepted - Offer: %d, Appointment: %d, Patient: %d", offer.ID, appointment.ID, offer.PatientID)
+	c.JSON(http.StatusCreated, appointment)
+}
+
+// DeclineWaitlistOffer turns the offer down; the patient stays on the
+// waitlist and the slot goes to the next patient
+func DeclineWaitlistOffer(c *gin.Context) {
+	offer, ok := findOwnOffer(c)
+	if !ok {
+		return
+	}
+
+	closed, err := waitlist.CloseOffer(config.DB, offer, "declined")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decline offer"})
+		return
+	}
+	if !closed {
+		c.JSON(http.StatusConflict, gin.H{"error": "This offer has expired or was already answered"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Offer declined"})
+}
+
+func findOwnOffer(c *gin.Context) (models.SlotOffer, bool) {
+	var offer models.SlotOffer
+	if err := config.DB.First(&offer, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found"})
+		return offer, false
+	}
+	if offer.PatientID != c.GetUint("user_id") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the patient the slot was offered to can answer"})
+		return offer, false
+	}
+	return offer, true
+}
+
+// waitlistScope limits waitlist entries or offers to the ones the caller may
+// see
+func waitlistScope(c *gin.Context, query *gorm.DB) (*gorm.DB, bool) {
+	userID := c.GetUint("user_id")
+	switch c.GetString("user_type") {
+	case "patient":
+		return query.Where("patient_id = ?", userID), true
+	case "doctor":
+		return query.Where("doctor_id = ?", userID), true
+	case "caregiver":
+		return query.Where("patient_id IN (?)", caredForPatientUsers(userID)), true
+	case "admin":
+		return query, true
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+	return nil, false
+}
+
+// localDay returns midnight in the clinic time zone of t's calendar day, as
+// written, so a date sent as "2026-03-01T00:00:00Z" stays 1 March
+func localDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, scheduling.Location())
+}
@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"dementicare-backend/models"
+	"dementicare-backend/scheduling"
+	"dementicare-backend/waitlist"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestJoinWaitlist(t *testing.T) {
+	db := useTestDB(t)
+	start := openClinic(t, db)
+	window := func(doctorID uint, earliest, latest time.Time) map[string]interface{} {
+		return map[string]interface{}{"doctor_id": doctorID, "earliest_date": earliest, "latest_date": latest}
+	}
+	asPatient := caller{id: 10, userType: "patient"}
+
+	tests := []struct {
+		name   string
+		as     caller
+		body   map[string]interface{}
+		status int
+	}{
+		{"caregiver", caller{id: 20, userType: "caregiver"}, window(1, start, start), http.StatusForbidden},
+		{"backwards window", asPatient, window(1, start, start.AddDate(0, 0, -1)), http.StatusBadRequest},
+		{"past window", asPatient, window(1, start.AddDate(0, 0, -20), start.AddDate(0, 0, -10)), http.StatusBadRequest},
+		{"not a doctor", asPatient, window(10, start, start), http.StatusBadRequest},
+		{"valid", asPatient, window(1, start, start.AddDate(0, 0, 7)), http.StatusCreated},
+		{"already waiting", asPatient, window(1, start, start), http.StatusConflict},
+	}
+	for _, tt := range tests {
+		w := serve(JoinWaitlist, "POST", "/waitlist", "/waitlist", tt.as, tt.body)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+		}
+	}
+}
+
+func TestWaitlistHandOff(t *testing.T) {
+	db := useTestDB(t)
+	start := openClinic(t, db)
+	cancelled := models.Appointment{PatientID: 12, DoctorID: 1, StartAt: start, EndAt: start.Add(30 * time.Minute), Status: "confirmed"}
+	db.Create(&cancelled)
+	for _, patientID := range []uint{10, 11} {
+		body := map[string]interface{}{"doctor_id": 1, "earliest_date": start, "latest_date": start}
+		if w := serve(JoinWaitlist, "POST", "/waitlist", "/waitlist", caller{id: patientID, userType: "patient"}, body); w.Code != http.StatusCreated {
+			t.Fatalf("patient %d join status = %d: %s", patientID, w.Code, w.Body)
+		}
+	}
+	openOffer := func() (models.SlotOffer, bool) {
+		var offer models.SlotOffer
+		found := db.Where("status = ?", "offered").Limit(1).Find(&offer).RowsAffected > 0
+		return offer, found
+	}
+	offerTarget := func(offer models.SlotOffer, action string) string {
+		return "/waitlist/offers/" + strconv.Itoa(int(offer.ID)) + "/" + action
+	}
+
+	// Cancelling offers the slot to the longest waiting patient and holds it
+	target := "/appointments/" + strconv.Itoa(int(cancelled.ID)) + "/cancel"
+	if w := serve(CancelAppointment, "POST", "/appointments/:id/cancel", target, caller{id: 1, userType: "doctor"}, AppointmentReasonRequest{Reason: "Doctor unwell"}); w.Code != http.StatusOK {
+		t.Fatalf("cancel status = %d: %s", w.Code, w.Body)
+	}
+	offer, found := openOffer()
+	if !found || offer.PatientID != 10 || !offer.StartAt.Equal(start) || !offer.ExpiresAt.After(time.Now()) {
+		t.Fatalf("open offer %+v, want a held offer of the slot to patient 10", offer)
+	}
+	var notified int64
+	db.Model(&models.Notification{}).Where("recipient_id = ? AND kind = ?", 10, "waitlist_offer").Count(&notified)
+	if notified != 1 {
+		t.Errorf("got %d offer notifications for patient 10, want 1", notified)
+	}
+	booking := map[string]interface{}{"doctor_id": 1, "start_at": start, "type": "consultation"}
+	if w := serve(CreateAppointment, "POST", "/appointments", "/appointments", caller{id: 13, userType: "patient"}, booking); w.Code != http.StatusConflict {
+		t.Errorf("booking a held slot: status = %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	// Only the patient it was offered to can answer
+	if w := serve(DeclineWaitlistOffer, "POST", "/waitlist/offers/:id/decline", offerTarget(offer, "decline"), caller{id: 11, userType: "patient"}, nil); w.Code != http.StatusForbidden {
+		t.Errorf("other patient decline status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	// Declining passes the slot on; patient 10 stays on the list but is not offered it again
+	if w := serve(DeclineWaitlistOffer, "POST", "/waitlist/offers/:id/decline", offerTarget(offer, "decline"), caller{id: 10, userType: "patient"}, nil); w.Code != http.StatusOK {
+		t.Fatalf("decline status = %d: %s", w.Code, w.Body)
+	}
+	var entry models.WaitlistEntry
+	db.Where("patient_id = ?", 10).First(&entry)
+	if entry.Status != "waiting" {
+		t.Errorf("entry after declining is %s, want waiting", entry.Status)
+	}
+	if w := serve(DeclineWaitlistOffer, "POST", "/waitlist/offers/:id/decline", offerTarget(offer, "decline"), caller{id: 10, userType: "patient"}, nil); w.Code != http.StatusConflict {
+		t.Errorf("second decline status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	offer, found = openOffer()
+	if !found || offer.PatientID != 11 {
+		t.Fatalf("open offer %+v, want the slot offered to patient 11", offer)
+	}
+
+	// An unanswered offer expires and, with nobody left, frees the slot
+	db.Model(&offer).Update("expires_at", time.Now().Add(-time.Minute))
+	if err := waitlist.ExpireOffers(db, time.Now()); err != nil {
+		t.Fatalf("ExpireOffers() error = %v", err)
+	}
+	db.First(&offer, offer.ID)
+	if offer.Status != "expired" {
+		t.Errorf("offer after expiry is %s, want expired", offer.Status)
+	}
+	if offer, found := openOffer(); found {
+		t.Errorf("slot offered again to %d after everyone had it", offer.PatientID)
+	}
+	if w := serve(AcceptWaitlistOffer, "POST", "/waitlist/offers/:id/accept", offerTarget(offer, "accept"), caller{id: 11, userType: "patient"}, nil); w.Code != http.StatusConflict {
+		t.Errorf("accepting an expired offer: status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	if w := serve(CreateAppointment, "POST", "/appointments", "/appointments", caller{id: 13, userType: "patient"}, booking); w.Code != http.StatusCreated {
+		t.Errorf("booking the released slot: status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+}
+
+func TestAcceptWaitlistOffer(t *testing.T) {
+	db := useTestDB(t)
+	start := openClinic(t, db)
+	entry := models.WaitlistEntry{PatientID: 10, DoctorID: 1, Type: "consultation", EarliestDate: start.AddDate(0, 0, -1), LatestDate: start, Status: "waiting"}
+	db.Create(&entry)
+	offer, err := waitlist.OfferSlot(db, 1, start, nil)
+	if err != nil || offer == nil {
+		t.Fatalf("OfferSlot() = %+v, %v, want an offer", offer, err)
+	}
+	target := "/waitlist/offers/" + strconv.Itoa(int(offer.ID)) + "/accept"
+
+	w := serve(AcceptWaitlistOffer, "POST", "/waitlist/offers/:id/accept", target, caller{id: 10, userType: "patient"}, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("accept status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+	var appointment models.Appointment
+	decode(t, w, &appointment)
+	db.First(&entry, entry.ID)
+	db.First(offer, offer.ID)
+	if !appointment.StartAt.Equal(start) || appointment.Status != "pending" || appointment.PatientID != 10 {
+		t.Errorf("booked %+v, want a pending appointment for patient 10 at %s", appointment, start)
+	}
+	if entry.Status != "booked" || entry.AppointmentID == nil || *entry.AppointmentID != appointment.ID {
+		t.Errorf("entry %+v, want booked with the appointment", entry)
+	}
+	if offer.Status != "accepted" || offer.AppointmentID == nil || *offer.AppointmentID != appointment.ID {
+		t.Errorf("offer %+v, want accepted with the appointment", offer)
+	}
+
+	if w := serve(AcceptWaitlistOffer, "POST", "/waitlist/offers/:id/accept", target, caller{id: 10, userType: "patient"}, nil); w.Code != http.StatusConflict {
+		t.Errorf("second accept status = %d, want %d", w.Code, http.StatusConflict)
+	}
+}
+
+func TestExpireWaitlistEntries(t *testing.T) {
+	db := useTestDB(t)
+	today := localDay(time.Now().In(scheduling.Location()))
+	db.Create(&[]models.WaitlistEntry{
+		{PatientID: 10, DoctorID: 1, EarliestDate: today.AddDate(0, 0, -7), LatestDate: today.AddDate(0, 0, -1), Status: "waiting"},
+		{PatientID: 11, DoctorID: 1, EarliestDate: today.AddDate(0, 0, -7), LatestDate: today, Status: "waiting"},
+		{PatientID: 12, DoctorID: 1, EarliestDate: today.AddDate(0, 0, -7), LatestDate: today.AddDate(0, 0, -1), Status: "booked"},
+	})
+
+	if err := waitlist.ExpireEntries(db, time.Now()); err != nil {
+		t.Fatalf("ExpireEntries() error = %v", err)
+	}
+	var entries []models.WaitlistEntry
+	db.Order("patient_id").Find(&entries)
+	for i, want := range []string{"expired", "waiting", "booked"} {
+		if entries[i].Status != want {
+			t.Errorf("entry of patient %d is %s, want %s", entries[i].PatientID, entries[i].Status, want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// Package ical writes iCalendar (RFC 5545) files.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event statuses
+const (
+	StatusTentative = "TENTATIVE"
+	StatusConfirmed = "CONFIRMED"
+	StatusCancelled = "CANCELLED"
+)
+
+// Event is one VEVENT. UID must stay the same for the life of the event and
+// Sequence must grow whenever it changes, so subscribed calendars update the
+// existing entry instead of adding a new one.
+type Event struct {
+	UID          string
+	Sequence     int
+	Start        time.Time
+	End          time.Time
+	Summary      string
+	Description  string
+	Status       string
+	Created      time.Time
+	LastModified time.Time
+}
+
+// Calendar is a VCALENDAR with a display name. With a named Location, such
+// as Europe/London, event times are written in it with a TZID and a matching
+// VTIMEZONE; otherwise they are written in UTC.
+type Calendar struct {
+	Name     string
+	Location *time.Location
+	Events   []Event
+}
+
+const (
+	prodID          = "-//DementiCare//Appointments//EN"
+	timeFormat      = "20060102T150405Z"
+	localTimeFormat = "20060102T150405"
+	maxLine         = 75
+)
+
+// Write encodes the calendar with CRLF line endings and folded long lines
+func (cal Calendar) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		writeFolded(bw, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", prodID)
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if cal.Name != "" {
+		line("X-WR-CALNAME", escape(cal.Name))
+	}
+	zoned := cal.Location != nil && cal.Location.String() != "Local" && cal.Location.String() != "UTC"
+	when := func(name string, t time.Time) {
+		if zoned {
+			line(name+";TZID="+cal.Location.String(), t.In(cal.Location).Format(localTimeFormat))
+			return
+		}
+		line(name, formatTime(t))
+	}
+	if zoned {
+		line("X-WR-TIMEZONE", cal.Location.String())
+		cal.writeTimeZone(line)
+	}
+
+	now := time.Now()
+	for _, e := range cal.Events {
+		line("BEGIN", "VEVENT")
+		line("UID", e.UID)
+		line("SEQUENCE", strconv.Itoa(e.Sequence))
+		line("DTSTAMP", formatTime(now))
+		when("DTSTART", e.Start)
+		when("DTEND", e.End)
+		line("SUMMARY", escape(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION", escape(e.Description))
+		}
+		if e.Status != "" {
+			line("STATUS", e.Status)
+		}
+		if !e.Created.IsZero() {
+			line("CREATED", formatTime(e.Created))
+		}
+		if !e.LastModified.IsZero() {
+			line("LAST-MODIFIED", formatTime(e.LastModified))
+		}
+		line("END", "VEVENT")
+	}
+
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+// writeTimeZone writes a VTIMEZONE for the calendar's location with the
+// observance in force at the first event and every offset change up to the
+// last one
+func (cal Calendar) writeTimeZone(line func(name, value string)) {
+	from, to := time.Now(), time.Now()
+	for i, e := range cal.Events {
+		if i == 0 || e.Start.Before(from) {
+			from = e.Start
+		}
+		if i == 0 || e.End.After(to) {
+			to = e.End
+		}
+	}
+
+	line("BEGIN", "VTIMEZONE")
+	line("TZID", cal.Location.String())
+	for _, t := range Transitions(cal.Location, from, to) {
+		kind := "STANDARD"
+		if t.DST {
+			kind = "DAYLIGHT"
+		}
+		line("BEGIN", kind)
+		line("DTSTART", t.At.In(time.FixedZone("", t.OffsetFrom)).Format(localTimeFormat))
+		line("TZOFFSETFROM", formatOffset(t.OffsetFrom))
+		line("TZOFFSETTO", formatOffset(t.OffsetTo))
+		if t.Name != "" {
+			line("TZNAME", t.Name)
+		}
+		line("END", kind)
+	}
+	line("END", "VTIMEZONE")
+}
+
+// Transition is a change of UTC offset in a time zone. The first transition
+// returned by Transitions is the observance already in force, with equal
+// offsets.
+type Transition struct {
+	At         time.Time
+	OffsetFrom int // seconds east of UTC
+	OffsetTo   int
+	Name       string
+	DST        bool
+}
+
+// Transitions lists the observance of loc in force at from and every offset
+// change until to
+func Transitions(loc *time.Location, from, to time.Time) []Transition {
+	name, offset := from.In(loc).Zone()
+	transitions := []Transition{{At: from, OffsetFrom: offset, OffsetTo: offset, Name: name, DST: from.In(loc).IsDST()}}
+
+	// Offsets change at most a few times a year, so stepping by day and
+	// bisecting each change finds them all
+	for day := from; day.Before(to); day = day.Add(24 * time.Hour) {
+		next := day.Add(24 * time.Hour)
+		if _, nextOffset := next.In(loc).Zone(); nextOffset == offset {
+			continue
+		}
+		lo, hi := day, next
+		for hi.Sub(lo) > time.Second {
+			mid := lo.Add(hi.Sub(lo) / 2)
+			if _, o := mid.In(loc).Zone(); o == offset {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		at := hi.Truncate(time.Second)
+		name, newOffset := at.In(loc).Zone()
+		transitions = append(transitions, Transition{At: at, OffsetFrom: offset, OffsetTo: newOffset, Name: name, DST: at.In(loc).IsDST()})
+		offset = newOffset
+	}
+	return transitions
+}
+
+// formatOffset writes seconds east of UTC as +HHMM
+func formatOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+	return fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds%3600/60)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// writeFolded writes a content line, folding it at 75 octets without
+// splitting a UTF-8 sequence
+func writeFolded(w *bufio.Writer, s string) {
+	limit := maxLine
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(s[cut]) {
+			cut--
+		}
+		w.WriteString(s[:cut])
+		w.WriteString("\r\n ")
+		s = s[cut:]
+		// continuation lines start with a space
+		limit = maxLine - 1
+	}
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
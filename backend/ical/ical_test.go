@@ -0,0 +1,209 @@
+package ical
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Check-up", "Check-up"},
+		{"Memory clinic, room 2", `Memory clinic\, room 2`},
+		{"Bring list; fast from 8am", `Bring list\; fast from 8am`},
+		{`C:\notes`, `C:\\notes`},
+		{"line one\nline two", `line one\nline two`},
+		{"line one\r\nline two", `line one\nline two`},
+	}
+	for _, tt := range tests {
+		if got := escape(tt.in); got != tt.want {
+			t.Errorf("escape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWriteFolded(t *testing.T) {
+	long := strings.Repeat("a", 200)
+	// 40 two-byte characters put a sequence across the 75 octet boundary
+	accented := "SUMMARY:" + strings.Repeat("é", 40)
+
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"short", "SUMMARY:Follow-up"},
+		{"exactly 75 octets", strings.Repeat("b", maxLine)},
+		{"long ASCII", "DESCRIPTION:" + long},
+		{"multi-byte", accented},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		w := bufio.NewWriter(&buf)
+		writeFolded(w, tt.in)
+		w.Flush()
+
+		out := buf.String()
+		if !strings.HasSuffix(out, "\r\n") {
+			t.Errorf("%s: output does not end with CRLF: %q", tt.name, out)
+			continue
+		}
+		lines := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+		for i, line := range lines {
+			if len(line) > maxLine {
+				t.Errorf("%s: line %d is %d octets long", tt.name, i, len(line))
+			}
+			if i > 0 && !strings.HasPrefix(line, " ") {
+				t.Errorf("%s: continuation line %d does not start with a space", tt.name, i)
+			}
+			if !utf8.ValidString(line) {
+				t.Errorf("%s: line %d splits a UTF-8 sequence", tt.name, i)
+			}
+		}
+		// Unfolding gives back the original line
+		if got := strings.ReplaceAll(strings.TrimSuffix(out, "\r\n"), "\r\n ", ""); got != tt.in {
+			t.Errorf("%s: unfolded %q, want %q", tt.name, got, tt.in)
+		}
+	}
+}
+
+func TestFormatOffset(t *testing.T) {
+	tests := []struct {
+		seconds int
+		want    string
+	}{
+		{0, "+0000"},
+		{3600, "+0100"},
+		{-5 * 3600, "-0500"},
+		{5*3600 + 30*60, "+0530"},
+		{-(3*3600 + 30*60), "-0330"},
+	}
+	for _, tt := range tests {
+		if got := formatOffset(tt.seconds); got != tt.want {
+			t.Errorf("formatOffset(%d) = %q, want %q", tt.seconds, got, tt.want)
+		}
+	}
+}
+
+func TestTransitions(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skip("Europe/London time zone data not available")
+	}
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     []Transition
+	}{
+		{
+			name: "no change",
+			from: time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC),
+			to:   time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC),
+			want: []Transition{{OffsetFrom: 3600, OffsetTo: 3600, Name: "BST", DST: true}},
+		},
+		{
+			name: "spring and autumn",
+			from: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			to:   time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC),
+			want: []Transition{
+				{OffsetFrom: 0, OffsetTo: 0, Name: "GMT"},
+				{At: time.Date(2026, 3, 29, 1, 0, 0, 0, time.UTC), OffsetFrom: 0, OffsetTo: 3600, Name: "BST", DST: true},
+				{At: time.Date(2026, 10, 25, 1, 0, 0, 0, time.UTC), OffsetFrom: 3600, OffsetTo: 0, Name: "GMT"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		got := Transitions(london, tt.from, tt.to)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %d transitions %+v, want %d", tt.name, len(got), got, len(tt.want))
+			continue
+		}
+		for i, want := range tt.want {
+			if i == 0 {
+				want.At = tt.from
+			}
+			if !got[i].At.Equal(want.At) || got[i].OffsetFrom != want.OffsetFrom || got[i].OffsetTo != want.OffsetTo ||
+				got[i].Name != want.Name || got[i].DST != want.DST {
+				t.Errorf("%s: transition %d = %+v, want %+v", tt.name, i, got[i], want)
+			}
+		}
+	}
+}
+
+func TestCalendarWrite(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skip("Europe/London time zone data not available")
+	}
+	event := Event{
+		UID:         "appointment-7@dementicare",
+		Sequence:    2,
+		Start:       time.Date(2026, 7, 1, 9, 0, 0, 0, time.UTC),
+		End:         time.Date(2026, 7, 1, 9, 30, 0, 0, time.UTC),
+		Summary:     "Follow-up, memory clinic",
+		Description: "Bring medication list;\nask about sleep",
+		Status:      StatusConfirmed,
+	}
+
+	tests := []struct {
+		name     string
+		calendar Calendar
+		want     []string
+		notWant  []string
+	}{
+		{
+			name:     "UTC",
+			calendar: Calendar{Name: "Dr. Smith", Events: []Event{event}},
+			want: []string{
+				"BEGIN:VCALENDAR\r\n",
+				"X-WR-CALNAME:Dr. Smith\r\n",
+				"UID:appointment-7@dementicare\r\n",
+				"SEQUENCE:2\r\n",
+				"DTSTART:20260701T090000Z\r\n",
+				"DTEND:20260701T093000Z\r\n",
+				`SUMMARY:Follow-up\, memory clinic` + "\r\n",
+				`DESCRIPTION:Bring medication list\;\nask about sleep` + "\r\n",
+				"STATUS:CONFIRMED\r\n",
+				"END:VCALENDAR\r\n",
+			},
+			notWant: []string{"VTIMEZONE", "TZID"},
+		},
+		{
+			name:     "zoned",
+			calendar: Calendar{Name: "Clinic", Location: london, Events: []Event{event}},
+			want: []string{
+				"X-WR-TIMEZONE:Europe/London\r\n",
+				"BEGIN:VTIMEZONE\r\nTZID:Europe/London\r\n",
+				"BEGIN:DAYLIGHT\r\n",
+				"TZOFFSETTO:+0100\r\n",
+				"DTSTART;TZID=Europe/London:20260701T100000\r\n",
+				"DTEND;TZID=Europe/London:20260701T103000\r\n",
+			},
+			notWant: []string{"DTSTART:20260701T090000Z"},
+		},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := tt.calendar.Write(&buf); err != nil {
+			t.Fatalf("%s: Write() error = %v", tt.name, err)
+		}
+		out := buf.String()
+		for _, want := range tt.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s: output is missing %q:\n%s", tt.name, want, out)
+			}
+		}
+		for _, notWant := range tt.notWant {
+			if strings.Contains(out, notWant) {
+				t.Errorf("%s: output should not contain %q:\n%s", tt.name, notWant, out)
+			}
+		}
+		if strings.Contains(strings.ReplaceAll(out, "\r\n", ""), "\n") {
+			t.Errorf("%s: output has a bare LF", tt.name)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Package interactions checks medications against a drug-drug interaction
+// dataset. A small dataset is bundled; larger ones can be imported as CSV or
+// JSON.
+package interactions
+
+import (
+	"bytes"
+	"dementicare-backend/models"
+	"dementicare-backend/spreadsheet"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Severity levels, from least to most serious. Major interactions need an
+// override reason to prescribe.
+const (
+	Minor    = "minor"
+	Moderate = "moderate"
+	Major    = "major"
+)
+
+var severityRank = map[string]int{Minor: 1, Moderate: 2, Major: 3}
+
+//go:embed data/drug_interactions.csv
+var bundled []byte
+
+// Record is one row of an imported dataset
+type Record struct {
+	DrugA       string `json:"drug_a"`
+	DrugB       string `json:"drug_b"`
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+}
+
+// RowError reports an invalid row of an imported dataset
+type RowError struct {
+	Row   int    `json:"row"` // 1-based; the CSV header is row 1
+	Error string `json:"error"`
+}
+
+// Parse reads a dataset in format "csv" (header drug_a, drug_b, severity,
+// description) or "json" (an array of objects with the same keys). Invalid
+// rows are reported and left out.
+func Parse(r io.Reader, format string) ([]models.DrugInteraction, []RowError, error) {
+	var records []Record
+	firstRow := 1
+	switch format {
+	case "csv":
+		rows, err := spreadsheet.ReadCSV(r, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rows) == 0 {
+			return nil, nil, errors.New("file is empty")
+		}
+		columns := make(map[string]int)
+		for i, name := range rows[0] {
+			columns[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		for _, required := range []string{"drug_a", "drug_b", "severity"} {
+			if _, ok := columns[required]; !ok {
+				return nil, nil, fmt.Errorf("missing column %q", required)
+			}
+		}
+		cell := func(row []string, name string) string {
+			if i, ok := columns[name]; ok && i < len(row) {
+				return row[i]
+			}
+			return ""
+		}
+		for _, row := range rows[1:] {
+			records = append(records, Record{
+				DrugA:       cell(row, "drug_a"),
+				DrugB:       cell(row, "drug_b"),
+				Severity:    cell(row, "severity"),
+				Description: cell(row, "description"),
+			})
+		}
+		firstRow = 2
+	case "json":
+		if err := json.NewDecoder(r).Decode(&records); err != nil {
+			return nil, nil, fmt.Errorf("expected a JSON array of interactions: %v", err)
+		}
+	default:
+		return nil, nil, fmt.Errorf("unsupported format %q", format)
+	}
+
+	var interactions []models.DrugInteraction
+	var rowErrors []RowError
+	seen := make(map[[2]string]int)
+	for i, record := range records {
+		interaction, err := normalizeRecord(record)
+		if err != nil {
+			rowErrors = append(rowErrors, RowError{Row: i + firstRow, Error: err.Error()})
+			continue
+		}
+		// A later row for the same pair wins
+		key := [2]string{interaction.DrugA, interaction.DrugB}
+		if j, ok := seen[key]; ok {
+			interactions[j] = interaction
+			continue
+		}
+		seen[key] = len(interactions)
+		interactions = append(interactions, interaction)
+	}
+	return interactions, rowErrors, nil
+}
+
+// Import adds the interactions to the dataset, replacing the severity and
+// description of pairs already present
+func Import(db *gorm.DB, interactions []models.DrugInteraction, source string) error {
+	if len(interactions) == 0 {
+		return nil
+	}
+	for i := range interactions {
+		interactions[i].Source = source
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "drug_a"}, {Name: "drug_b"}},
+		DoUpdates: clause.AssignmentColumns([]string{"severity", "description", "source", "updated_at"}),
+	}).CreateInBatches(&interactions, 200).Error
+}
+
+// SeedBundled loads the bundled dataset into an empty table
+func SeedBundled(db *gorm.DB) (int, error) {
+	var count int64
+	if err := db.Model(&models.DrugInteraction{}).Count(&count).Error; err != nil || count > 0 {
+		return 0, err
+	}
+
+	interactions, rowErrors, err := Parse(bytes.NewReader(bundled), "csv")
+	if err != nil {
+		return 0, err
+	}
+	if len(rowErrors) > 0 {
+		return 0, fmt.Errorf("bundled dataset row %d: %s", rowErrors[0].Row, rowErrors[0].Error)
+	}
+	return len(interactions), Import(db, interactions, "bundled")
+}
+
+// Between returns the interactions between medication and each of others,
+// matching dataset drug names as whole words of the medication names (so
+// "Donepezil 10mg tablets" matches "donepezil"). The result is indexed like
+// others, most severe first within each entry.
+func Between(db *gorm.DB, medication string, others []string) ([][]models.DrugInteraction, error) {
+	result := make([][]models.DrugInteraction, len(others))
+	med := Normalize(medication)
+	if med == "" || len(others) == 0 {
+		return result, nil
+	}
+
+	// Narrow down in SQL, then match on word boundaries
+	var candidates []models.DrugInteraction
+	if err := db.Where("? LIKE CONCAT('%', drug_a, '%') OR ? LIKE CONCAT('%', drug_b, '%')", med, med).
+		Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	for i, other := range others {
+		name := Normalize(other)
+		for _, candidate := range candidates {
+			if (containsWords(med, candidate.DrugA) && containsWords(name, candidate.DrugB)) ||
+				(containsWords(med, candidate.DrugB) && containsWords(name, candidate.DrugA)) {
+				result[i] = append(result[i], candidate)
+			}
+		}
+		sortBySeverity(result[i])
+	}
+	return result, nil
+}
+
+// Rank orders severities from 1 (minor) to 3 (major); unknown ones are 0
+func Rank(severity string) int {
+	return severityRank[severity]
+}
+
+// IsMajor reports whether the interaction needs an override reason
+func IsMajor(interaction models.DrugInteraction) bool {
+	return interaction.Severity == Major
+}
+
+// Normalize lower-cases a drug name and reduces it to words of letters and
+// digits separated by single spaces
+func Normalize(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func normalizeRecord(record Record) (models.DrugInteraction, error) {
+	a, b := Normalize(record.DrugA), Normalize(record.DrugB)
+	severity := strings.ToLower(strings.TrimSpace(record.Severity))
+	switch {
+	case a == "" || b == "":
+		return models.DrugInteraction{}, errors.New("drug_a and drug_b are required")
+	case a == b:
+		return models.DrugInteraction{}, errors.New("drug_a and drug_b must differ")
+	case len(a) > 100 || len(b) > 100:
+		return models.DrugInteraction{}, errors.New("drug names must be at most 100 characters")
+	case severityRank[severity] == 0:
+		return models.DrugInteraction{}, fmt.Errorf("severity must be %s, %s or %s", Minor, Moderate, Major)
+	}
+	if b < a {
+		a, b = b, a
+	}
+	return models.DrugInteraction{
+		DrugA:       a,
+		DrugB:       b,
+		Severity:    severity,
+		Description: strings.TrimSpace(record.Description),
+	}, nil
+}
+
+func containsWords(text, phrase string) bool {
+	return phrase != "" && strings.Contains(" "+text+" ", " "+phrase+" ")
+}
+
+func sortBySeverity(interactions []models.DrugInteraction) {
+	sort.SliceStable(interactions, func(i, j int) bool {
+		return Rank(interactions[i].Severity) > Rank(interactions[j].Severity)
+	})
+}
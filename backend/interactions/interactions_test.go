@@ -0,0 +1,114 @@
+package interactions
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Donepezil", "donepezil"},
+		{"  Donepezil 10mg  tablets ", "donepezil 10mg tablets"},
+		{"co-careldopa (Sinemet)", "co careldopa sinemet"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestContainsWords(t *testing.T) {
+	tests := []struct {
+		text, phrase string
+		want         bool
+	}{
+		{"donepezil 10mg tablets", "donepezil", true},
+		{"st john s wort extract", "st john s wort", true},
+		{"aspirin", "aspirin", true},
+		{"aspirinate", "aspirin", false},
+		{"low dose aspirin", "dose asp", false},
+		{"aspirin", "", false},
+	}
+	for _, tt := range tests {
+		if got := containsWords(tt.text, tt.phrase); got != tt.want {
+			t.Errorf("containsWords(%q, %q) = %v, want %v", tt.text, tt.phrase, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    string
+		in        string
+		pairs     []string // "drug_a+drug_b:severity", in order
+		errorRows []int
+		wantErr   bool
+	}{
+		{
+			name:   "csv with pairs sorted and severity lower-cased",
+			format: "csv",
+			in:     "drug_a,drug_b,severity,description\nWarfarin,Aspirin,MAJOR,Bleeding\nDonepezil,Bisoprolol,moderate,\n",
+			pairs:  []string{"aspirin+warfarin:major", "bisoprolol+donepezil:moderate"},
+		},
+		{
+			name:      "csv invalid rows reported by line",
+			format:    "csv",
+			in:        "drug_b,drug_a,severity\nwarfarin,aspirin,major\naspirin,aspirin,minor\n,x,minor\nx,y,severe\n",
+			pairs:     []string{"aspirin+warfarin:major"},
+			errorRows: []int{3, 4, 5},
+		},
+		{
+			name:   "later row for the same pair wins",
+			format: "csv",
+			in:     "drug_a,drug_b,severity\nwarfarin,aspirin,minor\naspirin,warfarin,major\n",
+			pairs:  []string{"aspirin+warfarin:major"},
+		},
+		{
+			name:      "json",
+			format:    "json",
+			in:        `[{"drug_a": "Memantine", "drug_b": "Amantadine", "severity": "moderate"}, {"drug_a": "x", "severity": "minor"}]`,
+			pairs:     []string{"amantadine+memantine:moderate"},
+			errorRows: []int{2},
+		},
+		{name: "missing column", format: "csv", in: "drug_a,severity\nx,minor\n", wantErr: true},
+		{name: "empty csv", format: "csv", in: "", wantErr: true},
+		{name: "json object", format: "json", in: `{"drug_a": "x"}`, wantErr: true},
+		{name: "unsupported format", format: "xml", in: "<x/>", wantErr: true},
+	}
+	for _, tt := range tests {
+		records, rowErrors, err := Parse(strings.NewReader(tt.in), tt.format)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Parse() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		var pairs []string
+		for _, r := range records {
+			pairs = append(pairs, r.DrugA+"+"+r.DrugB+":"+r.Severity)
+		}
+		if strings.Join(pairs, ",") != strings.Join(tt.pairs, ",") {
+			t.Errorf("%s: Parse() records = %v, want %v", tt.name, pairs, tt.pairs)
+		}
+		var rows []int
+		for _, e := range rowErrors {
+			rows = append(rows, e.Row)
+		}
+		if len(rows) != len(tt.errorRows) {
+			t.Errorf("%s: Parse() errors = %+v, want rows %v", tt.name, rowErrors, tt.errorRows)
+			continue
+		}
+		for i := range rows {
+			if rows[i] != tt.errorRows[i] {
+				t.Errorf("%s: Parse() errors = %+v, want rows %v", tt.name, rowErrors, tt.errorRows)
+				break
+			}
+		}
+	}
+}
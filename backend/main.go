@@ -3,6 +3,7 @@ package main
 import (
 	"dementicare-backend/config"
 	"dementicare-backend/routes"
+	"dementicare-backend/worker"
 	"log"
 	"os"
 
@@ -20,6 +21,13 @@ func main() {
 	// Initialize database
 	config.ConnectDB()
 
+	// Initialize file storage for attachments
+	config.ConnectStorage()
+
+	// Initialize the notification channel and start background jobs
+	config.ConnectNotifier()
+	worker.Start(config.DB, config.Notifier)
+
 	// Create Gin router
 	router := gin.Default()
 
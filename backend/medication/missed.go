@@ -0,0 +1,125 @@
+package medication
+
+import (
+	"dementicare-backend/models"
+	"dementicare-backend/notify"
+	"dementicare-backend/scheduling"
+	"dementicare-backend/settings"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultMissedAfter = time.Hour
+	// How far back the worker looks for unrecorded doses
+	missedLookback = 24 * time.Hour
+)
+
+// MissedAfter is how long after its due time an unrecorded dose counts as
+// missed (MEDICATION_MISSED_AFTER, default 1h)
+func MissedAfter() time.Duration {
+	return settings.Duration("MEDICATION_MISSED_AFTER", defaultMissedAfter)
+}
+
+// MarkMissedDoses records scheduled doses that nobody marked given, skipped
+// or refused in time as missed, and tells the patient's caregivers and care
+// team. Doses due before a schedule was created or its doses last changed are
+// never marked.
+func MarkMissedDoses(db *gorm.DB, now time.Time) error {
+	to := now.Add(-MissedAfter())
+	from := to.Add(-missedLookback)
+
+	var schedules []models.MedicationSchedule
+	if err := db.Where("as_needed = ? AND prescription_id IN (?)", false,
+		db.Model(&models.Prescription{}).Select("id")).Find(&schedules).Error; err != nil {
+		return err
+	}
+
+	for _, schedule := range schedules {
+		// Doses due before the schedule existed or last changed were never
+		// expected
+		start := from
+		for _, t := range []time.Time{schedule.CreatedAt, schedule.EffectiveFrom} {
+			if t.After(start) {
+				start = t
+			}
+		}
+		due := DueTimes(schedule, start, to)
+		if len(due) == 0 {
+			continue
+		}
+
+		// Insert only the doses nobody recorded yet; a no-op insert would
+		// still use up an auto-increment ID
+		var recorded []time.Time
+		if err := db.Model(&models.MedicationAdministration{}).
+			Where("schedule_id = ? AND due_at >= ? AND due_at <= ?", schedule.ID, due[0], due[len(due)-1]).
+			Pluck("due_at", &recorded).Error; err != nil {
+			return err
+		}
+		seen := make(map[int64]bool, len(recorded))
+		for _, t := range recorded {
+			seen[t.Unix()] = true
+		}
+
+		for _, at := range due {
+			if seen[at.Unix()] {
+				continue
+			}
+			at := at
+			dose := models.MedicationAdministration{
+				ScheduleID:     schedule.ID,
+				DueAt:          &at,
+				PrescriptionID: schedule.PrescriptionID,
+				PatientID:      schedule.PatientID,
+				Status:         "missed",
+			}
+			// A dose recorded since the lookup above wins
+			result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&dose)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+			notifyMissedDose(db, dose)
+		}
+	}
+	return nil
+}
+
+func notifyMissedDose(db *gorm.DB, dose models.MedicationAdministration) {
+	recipients, err := notify.CaregiverRecipients(db, dose.PatientID)
+	if err != nil {
+		log.Printf("Error finding caregivers of patient %d: %v", dose.PatientID, err)
+		return
+	}
+
+	var patient models.Patient
+	var prescription models.Prescription
+	db.Select("name").First(&patient, dose.PatientID)
+	db.Unscoped().Select("medication, dosage").First(&prescription, dose.PrescriptionID)
+
+	medication := prescription.Medication
+	if prescription.Dosage != "" {
+		medication += " " + prescription.Dosage
+	}
+	when := dose.DueAt.In(scheduling.Location()).Format("Monday 2 January at 15:04")
+
+	for _, recipientID := range recipients {
+		err := notify.Enqueue(db, models.Notification{
+			RecipientID: recipientID,
+			Kind:        "missed_dose",
+			Subject:     "Missed dose for " + patient.Name,
+			Body:        fmt.Sprintf("%s's dose of %s due %s has not been recorded as given.", patient.Name, medication, when),
+			DedupeKey:   fmt.Sprintf("missed-dose:%d:%d", dose.ID, recipientID),
+		})
+		if err != nil {
+			log.Printf("Error queueing missed dose notification for user %d: %v", recipientID, err)
+		}
+	}
+}
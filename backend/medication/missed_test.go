@@ -0,0 +1,99 @@
+package medication
+
+import (
+	"dementicare-backend/models"
+	"dementicare-backend/scheduling"
+	"dementicare-backend/testdb"
+	"testing"
+	"time"
+)
+
+func TestMarkMissedDoses(t *testing.T) {
+	loc := scheduling.Location()
+	at := func(day, hour int) time.Time { return time.Date(2026, 3, day, hour, 0, 0, 0, loc) }
+	// Doses due more than an hour before now, within the last day, are missed
+	now := at(10, 15)
+
+	tests := []struct {
+		name          string
+		effectiveFrom time.Time
+		recorded      []time.Time
+		want          []time.Time
+	}{
+		{"unchanged schedule", time.Time{}, nil, []time.Time{at(10, 8), at(10, 13)}},
+		{"recorded doses are kept", time.Time{}, []time.Time{at(10, 8)}, []time.Time{at(10, 13)}},
+		{"times changed this morning", at(10, 10), nil, []time.Time{at(10, 13)}},
+		{"times changed after the last due dose", at(10, 14), nil, nil},
+	}
+	for _, tt := range tests {
+		db := testdb.Open(t, &models.Prescription{}, &models.MedicationSchedule{}, &models.MedicationAdministration{},
+			&models.Patient{}, &models.CareTeamMember{}, &models.Notification{})
+		patient := models.Patient{Name: "Edith", CaregiverID: 5}
+		db.Create(&patient)
+		prescription := models.Prescription{PatientID: patient.ID, Medication: "Memantine", Frequency: "twice daily"}
+		db.Create(&prescription)
+		schedule := models.MedicationSchedule{
+			PrescriptionID: prescription.ID,
+			PatientID:      patient.ID,
+			Times:          "08:00,13:00",
+			StartDate:      time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+			EffectiveFrom:  tt.effectiveFrom,
+			CreatedAt:      at(1, 9),
+		}
+		db.Create(&schedule)
+		for _, due := range tt.recorded {
+			due := due
+			db.Create(&models.MedicationAdministration{ScheduleID: schedule.ID, DueAt: &due, PrescriptionID: prescription.ID,
+				PatientID: patient.ID, Status: "given"})
+		}
+
+		if err := MarkMissedDoses(db, now); err != nil {
+			t.Fatalf("%s: MarkMissedDoses() error = %v", tt.name, err)
+		}
+
+		var missed []models.MedicationAdministration
+		db.Where("status = ?", "missed").Order("due_at").Find(&missed)
+		if len(missed) != len(tt.want) {
+			t.Errorf("%s: %d doses marked missed, want %d", tt.name, len(missed), len(tt.want))
+			continue
+		}
+		for i, dose := range missed {
+			if !dose.DueAt.Equal(tt.want[i]) {
+				t.Errorf("%s: missed dose %d due %s, want %s", tt.name, i, dose.DueAt, tt.want[i])
+			}
+		}
+		var notifications int64
+		db.Model(&models.Notification{}).Where("kind = ? AND recipient_id = ?", "missed_dose", 5).Count(&notifications)
+		if int(notifications) != len(tt.want) {
+			t.Errorf("%s: %d caregiver notifications, want %d", tt.name, notifications, len(tt.want))
+		}
+	}
+}
+
+func TestDosesChanged(t *testing.T) {
+	end := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	later := end.AddDate(0, 0, 7)
+	base := models.MedicationSchedule{Times: "08:00", StartDate: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), EndDate: &end}
+
+	tests := []struct {
+		name   string
+		change func(s *models.MedicationSchedule)
+		want   bool
+	}{
+		{"nothing", func(s *models.MedicationSchedule) {}, false},
+		{"same end date, other pointer", func(s *models.MedicationSchedule) { e := end; s.EndDate = &e }, false},
+		{"times", func(s *models.MedicationSchedule) { s.Times = "09:00" }, true},
+		{"weekdays", func(s *models.MedicationSchedule) { s.Weekdays = "1" }, true},
+		{"as needed", func(s *models.MedicationSchedule) { s.AsNeeded = true }, true},
+		{"start date", func(s *models.MedicationSchedule) { s.StartDate = s.StartDate.AddDate(0, 0, -7) }, true},
+		{"end date extended", func(s *models.MedicationSchedule) { s.EndDate = &later }, true},
+		{"end date removed", func(s *models.MedicationSchedule) { s.EndDate = nil }, true},
+	}
+	for _, tt := range tests {
+		after := base
+		tt.change(&after)
+		if got := DosesChanged(base, after); got != tt.want {
+			t.Errorf("%s: DosesChanged() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
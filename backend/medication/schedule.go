@@ -0,0 +1,331 @@
+// Package medication turns prescriptions into dosing schedules and keeps the
+// medication administration record up to date.
+package medication
+
+import (
+	"dementicare-backend/models"
+	"dementicare-backend/scheduling"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Dose times used when a schedule is derived from the prescription text
+var (
+	morning    = []string{"08:00"}
+	evening    = []string{"18:00"}
+	night      = []string{"21:00"}
+	twiceDaily = []string{"08:00", "20:00"}
+	threeTimes = []string{"08:00", "14:00", "20:00"}
+	fourTimes  = []string{"08:00", "12:00", "16:00", "20:00"}
+)
+
+var (
+	everyHours = regexp.MustCompile(`\b(?:every|q)\s*(\d+)\s*(?:hours?|hrs?|h)\b`)
+	duration   = regexp.MustCompile(`(\d+)\s*(day|week|month)s?`)
+)
+
+// frequencyPatterns maps common ways of writing a frequency, including the
+// usual Latin abbreviations, to dose times. The first match wins.
+var frequencyPatterns = []struct {
+	pattern *regexp.Regexp
+	times   []string
+}{
+	{regexp.MustCompile(`\b(four times|4 times|4x|qds|qid)\b`), fourTimes},
+	{regexp.MustCompile(`\b(three times|3 times|3x|tds|tid)\b`), threeTimes},
+	{regexp.MustCompile(`\b(twice|two times|2 times|2x|bd|bid)\b`), twiceDaily},
+	{regexp.MustCompile(`\b(at night|bedtime|nocte)\b`), night},
+	{regexp.MustCompile(`\b(evening)\b`), evening},
+	{regexp.MustCompile(`\b(morning|mane)\b`), morning},
+	{regexp.MustCompile(`\b(once|daily|every day|a day|od|qd)\b`), morning},
+}
+
+var (
+	asNeeded = regexp.MustCompile(`\b(as needed|as required|when required|when needed|prn)\b`)
+	// "weekly", "every week", "twice a week", "3x per week"; not "daily for a week"
+	weekly = regexp.MustCompile(`\b(weekly|(every|each|per) week)\b|` +
+		`\b(once|twice|\d+\s*(times|x)|(one|two|three|four|five|six|seven) times)\s*(a|per|every|each)\s+week\b`)
+	// More than one dose a week; which days cannot be told from the text
+	severalPerWeek = regexp.MustCompile(`\b(twice|([2-9]|\d{2,})\s*(times|x)|(two|three|four|five|six|seven) times)\b`)
+)
+
+// ErrUnknownFrequency is returned when a prescription's frequency cannot be
+// read as a schedule
+var ErrUnknownFrequency = errors.New("frequency not recognised; set the schedule explicitly")
+
+// Derive builds the schedule of a prescription from its free-text frequency
+// and duration, starting on the calendar day of start in clinic time.
+func Derive(prescription models.Prescription, start time.Time) (models.MedicationSchedule, error) {
+	local := start.In(scheduling.Location())
+	schedule := models.MedicationSchedule{
+		PrescriptionID: prescription.ID,
+		PatientID:      prescription.PatientID,
+		StartDate:      time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC),
+		Derived:        true,
+	}
+
+	frequency := strings.ToLower(prescription.Frequency)
+	switch {
+	case asNeeded.MatchString(frequency):
+		schedule.AsNeeded = true
+	case everyHours.MatchString(frequency):
+		hours, _ := strconv.Atoi(everyHours.FindStringSubmatch(frequency)[1])
+		if hours < 1 || hours > 24 {
+			return schedule, ErrUnknownFrequency
+		}
+		schedule.Times = strings.Join(everyNHours(hours), ",")
+	case weekly.MatchString(frequency):
+		// Checked before the daily patterns, which "3 times a week" would
+		// otherwise match
+		if severalPerWeek.MatchString(frequency) {
+			return schedule, ErrUnknownFrequency
+		}
+		schedule.Times = strings.Join(morning, ",")
+		schedule.Weekdays = strconv.Itoa(int(local.Weekday()))
+	default:
+		for _, p := range frequencyPatterns {
+			if p.pattern.MatchString(frequency) {
+				schedule.Times = strings.Join(p.times, ",")
+				break
+			}
+		}
+		if schedule.Times == "" {
+			return schedule, ErrUnknownFrequency
+		}
+	}
+
+	// "7 days", "2 weeks", "1 month"; anything else is open-ended
+	if m := duration.FindStringSubmatch(strings.ToLower(prescription.Duration)); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		if n > 0 {
+			var end time.Time
+			switch m[2] {
+			case "day":
+				end = schedule.StartDate.AddDate(0, 0, n)
+			case "week":
+				end = schedule.StartDate.AddDate(0, 0, 7*n)
+			case "month":
+				end = schedule.StartDate.AddDate(0, n, 0)
+			}
+			end = end.AddDate(0, 0, -1)
+			schedule.EndDate = &end
+		}
+	}
+	return schedule, nil
+}
+
+// everyNHours spreads doses n hours apart from 08:00 over one day
+func everyNHours(n int) []string {
+	var hours []int
+	for h := 8; h < 8+24; h += n {
+		hours = append(hours, h%24)
+	}
+	sort.Ints(hours)
+
+	times := make([]string, len(hours))
+	for i, h := range hours {
+		times[i] = fmt.Sprintf("%02d:00", h)
+	}
+	return times
+}
+
+// SyncDerived derives the prescription's schedule from its frequency and
+// duration, replacing an earlier derived one; a new schedule starts on the
+// day of start. Schedules a doctor set explicitly are kept.
+func SyncDerived(db *gorm.DB, prescription models.Prescription, start time.Time, userID uint) error {
+	var existing models.MedicationSchedule
+	result := db.Where("prescription_id = ?", prescription.ID).Limit(1).Find(&existing)
+	if result.Error != nil {
+		return result.Error
+	}
+	found := result.RowsAffected > 0
+	if found && !existing.Derived {
+		if existing.PatientID != prescription.PatientID {
+			return db.Model(&existing).Update("patient_id", prescription.PatientID).Error
+		}
+		return nil
+	}
+
+	if found {
+		// Keep the original first day
+		start, _ = scheduling.StartFromClock(existing.StartDate, "12:00")
+	}
+	schedule, err := Derive(prescription, start)
+	if err != nil {
+		log.Printf("No schedule derived for prescription %d (%q): %v", prescription.ID, prescription.Frequency, err)
+		if found {
+			// A soft-deleted row would keep its place in the unique index on
+			// prescription_id and block any later schedule
+			return db.Unscoped().Delete(&existing).Error
+		}
+		return nil
+	}
+
+	if found {
+		schedule.ID, schedule.CreatedBy, schedule.CreatedAt = existing.ID, existing.CreatedBy, existing.CreatedAt
+		schedule.EffectiveFrom = existing.EffectiveFrom
+	} else {
+		schedule.CreatedBy = userID
+	}
+	if !found || DosesChanged(existing, schedule) {
+		schedule.EffectiveFrom = time.Now()
+	}
+	return db.Save(&schedule).Error
+}
+
+// DosesChanged reports whether after has other doses due than before, so
+// that the new ones are not marked missed for the time before the change.
+func DosesChanged(before, after models.MedicationSchedule) bool {
+	sameEnd := (before.EndDate == nil) == (after.EndDate == nil) &&
+		(before.EndDate == nil || before.EndDate.Equal(*after.EndDate))
+	return before.Times != after.Times || before.Weekdays != after.Weekdays || before.AsNeeded != after.AsNeeded ||
+		!before.StartDate.Equal(after.StartDate) || !sameEnd
+}
+
+// Validate checks the schedule's dose times, weekdays and dates, and
+// normalizes Times and Weekdays to a sorted list without duplicates.
+func Validate(schedule *models.MedicationSchedule) error {
+	if schedule.AsNeeded {
+		schedule.Times, schedule.Weekdays = "", ""
+	} else {
+		clocks, err := parseTimes(schedule.Times)
+		if err != nil {
+			return err
+		}
+		if len(clocks) == 0 {
+			return errors.New("times is required unless as_needed is set")
+		}
+		days, err := parseWeekdays(schedule.Weekdays)
+		if err != nil {
+			return err
+		}
+		schedule.Times = strings.Join(clocks, ",")
+		schedule.Weekdays = joinInts(days)
+	}
+
+	if schedule.StartDate.IsZero() {
+		return errors.New("start_date is required")
+	}
+	if schedule.EndDate != nil && schedule.EndDate.Before(schedule.StartDate) {
+		return errors.New("end_date must not be before start_date")
+	}
+	return nil
+}
+
+// DueTimes lists the doses due in [from, to). As-needed schedules have none.
+func DueTimes(schedule models.MedicationSchedule, from, to time.Time) []time.Time {
+	if schedule.AsNeeded {
+		return nil
+	}
+	clocks, err := parseTimes(schedule.Times)
+	if err != nil {
+		return nil
+	}
+	days, err := parseWeekdays(schedule.Weekdays)
+	if err != nil {
+		return nil
+	}
+	onDay := make(map[time.Weekday]bool, len(days))
+	for _, d := range days {
+		onDay[time.Weekday(d)] = true
+	}
+
+	loc := scheduling.Location()
+	local := from.In(loc)
+	var due []time.Time
+	for day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc); day.Before(to); day = day.AddDate(0, 0, 1) {
+		date := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+		if date.Before(calendarDay(schedule.StartDate)) {
+			continue
+		}
+		if schedule.EndDate != nil && date.After(calendarDay(*schedule.EndDate)) {
+			break
+		}
+		if len(onDay) > 0 && !onDay[day.Weekday()] {
+			continue
+		}
+		for _, clock := range clocks {
+			at, err := scheduling.StartFromClock(day, clock)
+			if err == nil && !at.Before(from) && at.Before(to) {
+				due = append(due, at)
+			}
+		}
+	}
+	return due
+}
+
+// IsDue reports whether a dose of the schedule is due at exactly at
+func IsDue(schedule models.MedicationSchedule, at time.Time) bool {
+	for _, due := range DueTimes(schedule, at, at.Add(time.Minute)) {
+		if due.Equal(at) {
+			return true
+		}
+	}
+	return false
+}
+
+// calendarDay is the day of t as written, at midnight UTC
+func calendarDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func parseTimes(value string) ([]string, error) {
+	seen := make(map[int]bool)
+	var minutes []int
+	for _, part := range strings.Split(value, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		m, err := scheduling.ParseClock(part)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[m] {
+			seen[m] = true
+			minutes = append(minutes, m)
+		}
+	}
+	sort.Ints(minutes)
+
+	clocks := make([]string, len(minutes))
+	for i, m := range minutes {
+		clocks[i] = fmt.Sprintf("%02d:%02d", m/60, m%60)
+	}
+	return clocks, nil
+}
+
+func parseWeekdays(value string) ([]int, error) {
+	seen := make(map[int]bool)
+	var days []int
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		d, err := strconv.Atoi(part)
+		if err != nil || d < 0 || d > 6 {
+			return nil, fmt.Errorf("invalid weekday %q, expected 0 (Sunday) to 6 (Saturday)", part)
+		}
+		if !seen[d] {
+			seen[d] = true
+			days = append(days, d)
+		}
+	}
+	sort.Ints(days)
+	return days, nil
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,212 @@
+package medication
+
+import (
+	"dementicare-backend/models"
+	"dementicare-backend/scheduling"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// Dose times are read in the clinic zone; use one with DST
+	os.Setenv("CLINIC_TIMEZONE", "Europe/London")
+	os.Exit(m.Run())
+}
+
+func TestDerive(t *testing.T) {
+	// A Wednesday afternoon in clinic time
+	start := time.Date(2026, 3, 4, 15, 0, 0, 0, scheduling.Location())
+	day := func(year int, month time.Month, d int) *time.Time {
+		date := time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+		return &date
+	}
+
+	tests := []struct {
+		frequency, duration string
+		times, weekdays     string
+		asNeeded            bool
+		endDate             *time.Time
+		wantErr             bool
+	}{
+		{frequency: "Once daily", times: "08:00"},
+		{frequency: "BD", times: "08:00,20:00"},
+		{frequency: "three times a day", times: "08:00,14:00,20:00"},
+		{frequency: "QID", times: "08:00,12:00,16:00,20:00"},
+		{frequency: "at night", times: "21:00"},
+		{frequency: "every evening", times: "18:00"},
+		{frequency: "every 8 hours", times: "00:00,08:00,16:00"},
+		{frequency: "q6h", times: "02:00,08:00,14:00,20:00"},
+		{frequency: "once a week", times: "08:00", weekdays: "3"},
+		{frequency: "Weekly", times: "08:00", weekdays: "3"},
+		{frequency: "1x per week", times: "08:00", weekdays: "3"},
+		{frequency: "once daily for a week", times: "08:00"},
+		{frequency: "3 times a week", wantErr: true},
+		{frequency: "twice a week", wantErr: true},
+		{frequency: "twice weekly", wantErr: true},
+		{frequency: "2x per week", wantErr: true},
+		{frequency: "three times weekly", wantErr: true},
+		{frequency: "PRN", asNeeded: true},
+		{frequency: "as needed for agitation", asNeeded: true},
+		{frequency: "daily", duration: "7 days", times: "08:00", endDate: day(2026, 3, 10)},
+		{frequency: "daily", duration: "2 weeks", times: "08:00", endDate: day(2026, 3, 17)},
+		{frequency: "daily", duration: "1 month", times: "08:00", endDate: day(2026, 4, 3)},
+		{frequency: "daily", duration: "ongoing", times: "08:00"},
+		{frequency: "every 30 hours", wantErr: true},
+		{frequency: "with meals", wantErr: true},
+	}
+	for _, tt := range tests {
+		prescription := models.Prescription{ID: 3, PatientID: 9, Frequency: tt.frequency, Duration: tt.duration}
+		schedule, err := Derive(prescription, start)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Derive(%q) error = %v, wantErr %v", tt.frequency, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if schedule.Times != tt.times || schedule.Weekdays != tt.weekdays || schedule.AsNeeded != tt.asNeeded {
+			t.Errorf("Derive(%q) = times %q weekdays %q as needed %v, want %q %q %v",
+				tt.frequency, schedule.Times, schedule.Weekdays, schedule.AsNeeded, tt.times, tt.weekdays, tt.asNeeded)
+		}
+		if !schedule.StartDate.Equal(*day(2026, 3, 4)) || !schedule.Derived || schedule.PrescriptionID != 3 || schedule.PatientID != 9 {
+			t.Errorf("Derive(%q) = start %s derived %v prescription %d patient %d",
+				tt.frequency, schedule.StartDate, schedule.Derived, schedule.PrescriptionID, schedule.PatientID)
+		}
+		switch {
+		case tt.endDate == nil && schedule.EndDate != nil:
+			t.Errorf("Derive(%q, %q) end date = %s, want none", tt.frequency, tt.duration, schedule.EndDate)
+		case tt.endDate != nil && (schedule.EndDate == nil || !schedule.EndDate.Equal(*tt.endDate)):
+			t.Errorf("Derive(%q, %q) end date = %v, want %s", tt.frequency, tt.duration, schedule.EndDate, tt.endDate)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	start := time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC)
+	before := start.AddDate(0, 0, -1)
+
+	tests := []struct {
+		name            string
+		schedule        models.MedicationSchedule
+		times, weekdays string
+		wantErr         bool
+	}{
+		{"sorted without duplicates", models.MedicationSchedule{Times: "20:00, 08:00,08:00", Weekdays: "5,1,1", StartDate: start}, "08:00,20:00", "1,5", false},
+		{"as needed clears times", models.MedicationSchedule{AsNeeded: true, Times: "08:00", Weekdays: "1", StartDate: start}, "", "", false},
+		{"no times", models.MedicationSchedule{StartDate: start}, "", "", true},
+		{"bad time", models.MedicationSchedule{Times: "8am", StartDate: start}, "", "", true},
+		{"bad weekday", models.MedicationSchedule{Times: "08:00", Weekdays: "7", StartDate: start}, "", "", true},
+		{"no start date", models.MedicationSchedule{Times: "08:00"}, "", "", true},
+		{"ends before it starts", models.MedicationSchedule{Times: "08:00", StartDate: start, EndDate: &before}, "", "", true},
+	}
+	for _, tt := range tests {
+		schedule := tt.schedule
+		err := Validate(&schedule)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (schedule.Times != tt.times || schedule.Weekdays != tt.weekdays) {
+			t.Errorf("%s: normalized to %q %q, want %q %q", tt.name, schedule.Times, schedule.Weekdays, tt.times, tt.weekdays)
+		}
+	}
+}
+
+func TestDueTimes(t *testing.T) {
+	loc := scheduling.Location()
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2026, month, day, hour, 0, 0, 0, loc)
+	}
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(2026, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	end := date(3, 3)
+
+	tests := []struct {
+		name     string
+		schedule models.MedicationSchedule
+		from, to time.Time
+		want     []time.Time
+	}{
+		{
+			name:     "twice daily",
+			schedule: models.MedicationSchedule{Times: "08:00,20:00", StartDate: date(3, 1)},
+			from:     at(3, 2, 0),
+			to:       at(3, 4, 0),
+			want:     []time.Time{at(3, 2, 8), at(3, 2, 20), at(3, 3, 8), at(3, 3, 20)},
+		},
+		{
+			name:     "from is inclusive and to exclusive",
+			schedule: models.MedicationSchedule{Times: "08:00,20:00", StartDate: date(3, 1)},
+			from:     at(3, 2, 8),
+			to:       at(3, 2, 20),
+			want:     []time.Time{at(3, 2, 8)},
+		},
+		{
+			name:     "not before the start date",
+			schedule: models.MedicationSchedule{Times: "08:00", StartDate: date(3, 3)},
+			from:     at(3, 1, 0),
+			to:       at(3, 5, 0),
+			want:     []time.Time{at(3, 3, 8), at(3, 4, 8)},
+		},
+		{
+			name:     "end date is inclusive",
+			schedule: models.MedicationSchedule{Times: "08:00", StartDate: date(3, 1), EndDate: &end},
+			from:     at(3, 1, 0),
+			to:       at(3, 10, 0),
+			want:     []time.Time{at(3, 1, 8), at(3, 2, 8), at(3, 3, 8)},
+		},
+		{
+			// 2026-03-02 is a Monday
+			name:     "weekdays only",
+			schedule: models.MedicationSchedule{Times: "09:00", Weekdays: "1,3", StartDate: date(3, 1)},
+			from:     at(3, 1, 0),
+			to:       at(3, 8, 0),
+			want:     []time.Time{at(3, 2, 9), at(3, 4, 9)},
+		},
+		{
+			name:     "clock time kept across the DST change",
+			schedule: models.MedicationSchedule{Times: "08:00", StartDate: date(3, 1)},
+			from:     at(3, 28, 0),
+			to:       at(3, 30, 0),
+			want:     []time.Time{at(3, 28, 8), at(3, 29, 8)},
+		},
+		{
+			name:     "as needed",
+			schedule: models.MedicationSchedule{AsNeeded: true, StartDate: date(3, 1)},
+			from:     at(3, 1, 0),
+			to:       at(3, 8, 0),
+		},
+	}
+	for _, tt := range tests {
+		got := DueTimes(tt.schedule, tt.from, tt.to)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %d doses %v, want %v", tt.name, len(got), got, tt.want)
+			continue
+		}
+		for i := range got {
+			if !got[i].Equal(tt.want[i]) {
+				t.Errorf("%s: dose %d = %s, want %s", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestIsDue(t *testing.T) {
+	loc := scheduling.Location()
+	schedule := models.MedicationSchedule{Times: "08:00", StartDate: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)}
+	tests := []struct {
+		at   time.Time
+		want bool
+	}{
+		{time.Date(2026, 3, 2, 8, 0, 0, 0, loc), true},
+		{time.Date(2026, 3, 2, 8, 1, 0, 0, loc), false},
+		{time.Date(2026, 2, 28, 8, 0, 0, 0, loc), false},
+	}
+	for _, tt := range tests {
+		if got := IsDue(schedule, tt.at); got != tt.want {
+			t.Errorf("IsDue(%s) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package medication
+
+import (
+	"dementicare-backend/models"
+	"dementicare-backend/testdb"
+	"testing"
+	"time"
+)
+
+func TestSyncDerived(t *testing.T) {
+	db := testdb.Open(t, &models.Prescription{}, &models.MedicationSchedule{})
+	start := time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC)
+	prescription := models.Prescription{PatientID: 9, DoctorID: 2, Medication: "Donepezil", Frequency: "once daily"}
+	if err := db.Create(&prescription).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	schedule := func() (models.MedicationSchedule, bool) {
+		var s models.MedicationSchedule
+		found := db.Where("prescription_id = ?", prescription.ID).Limit(1).Find(&s).RowsAffected > 0
+		return s, found
+	}
+
+	steps := []struct {
+		frequency string
+		times     string // "" when there should be no schedule
+	}{
+		{"once daily", "08:00"},
+		{"twice daily", "08:00,20:00"},
+		// An unrecognised frequency removes the schedule...
+		{"with meals", ""},
+		// ...and a recognised one later gets a new one
+		{"at night", "21:00"},
+	}
+	for _, step := range steps {
+		prescription.Frequency = step.frequency
+		if err := SyncDerived(db, prescription, start, 2); err != nil {
+			t.Fatalf("SyncDerived(%q) error = %v", step.frequency, err)
+		}
+		s, found := schedule()
+		if step.times == "" {
+			if found {
+				t.Errorf("SyncDerived(%q) kept schedule %q", step.frequency, s.Times)
+			}
+			continue
+		}
+		if !found || s.Times != step.times {
+			t.Errorf("SyncDerived(%q) = %q (found %v), want %q", step.frequency, s.Times, found, step.times)
+		}
+	}
+
+	// A schedule set by a doctor is left alone
+	db.Model(&models.MedicationSchedule{}).Where("prescription_id = ?", prescription.ID).
+		Updates(map[string]interface{}{"derived": false, "times": "09:00"})
+	prescription.Frequency = "twice daily"
+	if err := SyncDerived(db, prescription, start, 2); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := schedule(); s.Times != "09:00" {
+		t.Errorf("explicit schedule changed to %q", s.Times)
+	}
+}
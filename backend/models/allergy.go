@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Allergy struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	PatientID  uint           `gorm:"index" json:"patient_id"`
+	Substance  string         `json:"substance"`
+	Reaction   string         `json:"reaction"`
+	Severity   string         `json:"severity"` // mild, moderate, severe
+	Notes      string         `json:"notes"`
+	RecordedBy uint           `json:"recorded_by"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+}
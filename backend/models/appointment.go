@@ -7,15 +7,41 @@ import (
 )
 
 type Appointment struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	PatientID uint           `json:"patient_id"`
-	DoctorID  uint           `json:"doctor_id"`
-	Date      time.Time      `json:"date"`
-	Time      string         `json:"time"`
-	Type      string         `json:"type"`                            // consultation, follow-up, emergency
-	Status    string         `json:"status" gorm:"default:'pending'"` // pending, confirmed, cancelled, completed
-	Notes     string         `json:"notes"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                 uint           `gorm:"primaryKey" json:"id"`
+	PatientID          uint           `json:"patient_id"`
+	DoctorID           uint           `json:"doctor_id"`
+	BookedBy           uint           `gorm:"index" json:"booked_by"` // user who made the booking: the patient, a caregiver or care-team member
+	StartAt            time.Time      `gorm:"index" json:"start_at"`  // canonical start, UTC
+	EndAt              time.Time      `json:"end_at"`
+	TimeZone           string         `json:"time_zone"`                           // clinic IANA time zone at booking
+	Date               time.Time      `json:"date"`                                // calendar day in clinic time, kept for older clients
+	Time               string         `json:"time"`                                // HH:MM in clinic time, kept for older clients
+	Type               string         `json:"type"`                                // consultation, follow-up, emergency
+	DurationMinutes    int            `json:"duration_minutes"`                    // defaults to the slot length of the type
+	Modality           string         `json:"modality" gorm:"default:'in_person'"` // in_person, virtual
+	Status             string         `json:"status" gorm:"default:'pending'"`     // pending, confirmed, completed, cancelled, no_show, rescheduled
+	CancellationReason string         `json:"cancellation_reason"`
+	LateCancellation   bool           `json:"late_cancellation"`            // cancelled by the patient's side shortly before the start
+	NeedsCaregiver     bool           `json:"needs_caregiver_confirmation"` // booked under the no-show policy; the doctor confirms after a caregiver
+	CaregiverConfirmBy *uint          `json:"caregiver_confirmed_by"`
+	CaregiverConfirmAt *time.Time     `json:"caregiver_confirmed_at"`
+	RescheduledFromID  *uint          `json:"rescheduled_from_id"` // original appointment when this one replaces it
+	SeriesID           *uint          `gorm:"index" json:"series_id"`
+	SeriesIndex        int            `json:"series_index"` // position within the series, from 0
+	Notes              string         `json:"notes"`
+	Sequence           int            `json:"sequence"` // iCalendar SEQUENCE, bumped on every change
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// AppointmentStatusChange is one entry in an appointment's status history
+type AppointmentStatusChange struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	AppointmentID uint      `gorm:"index" json:"appointment_id"`
+	FromStatus    string    `json:"from_status"`
+	ToStatus      string    `json:"to_status"`
+	ChangedBy     uint      `json:"changed_by"`
+	Reason        string    `json:"reason"`
+	CreatedAt     time.Time `json:"created_at"`
 }
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AppointmentSeries is a recurring set of appointments, such as monthly
+// follow-ups. Each occurrence is a regular Appointment with SeriesID set.
+type AppointmentSeries struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	PatientID uint           `gorm:"index" json:"patient_id"`
+	DoctorID  uint           `gorm:"index" json:"doctor_id"`
+	Type      string         `json:"type"`
+	Modality  string         `json:"modality"`   // in_person, virtual
+	StartDate time.Time      `json:"start_date"` // first occurrence
+	Time      string         `json:"time"`       // HH:MM in clinic time
+	TimeZone  string         `json:"time_zone"`
+	Frequency string         `json:"frequency"` // weekly, monthly
+	Interval  int            `json:"interval"`
+	Count     int            `json:"count"`
+	Until     *time.Time     `json:"until"`
+	Notes     string         `json:"notes"`
+	Status    string         `json:"status" gorm:"default:'active'"` // active, cancelled
+	CreatedBy uint           `json:"created_by"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Attachment struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	PatientID   uint           `gorm:"index" json:"patient_id"`
+	UploadedBy  uint           `json:"uploaded_by"`
+	Category    string         `json:"category"` // scan, discharge_letter, mri_report, photo, other
+	FileName    string         `json:"file_name"`
+	ContentType string         `json:"content_type"`
+	Size        int64          `json:"size"`
+	Checksum    string         `gorm:"size:64" json:"checksum"` // SHA-256, hex encoded
+	StorageKey  string         `gorm:"size:255" json:"-"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// CalendarFeed holds the secret token in a user's iCalendar subscription URL.
+// Rotating the token revokes the old URL.
+type CalendarFeed struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"uniqueIndex" json:"user_id"`
+	Token     string    `gorm:"size:64;uniqueIndex" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CareLogEntry is one caregiver journal entry. Only the structured fields that
+// belong to the entry's category are expected to be set.
+type CareLogEntry struct {
+	ID               uint           `gorm:"primaryKey" json:"id"`
+	PatientID        uint           `gorm:"index:idx_care_log_patient_logged" json:"patient_id"`
+	AuthorID         uint           `json:"author_id"`
+	Category         string         `gorm:"size:20;index" json:"category"` // meal, mood, sleep, bathing, incident, other
+	LoggedAt         time.Time      `gorm:"index:idx_care_log_patient_logged" json:"logged_at"`
+	Mood             *int           `json:"mood"`          // 1 (very low) - 5 (very good)
+	SleepQuality     *int           `json:"sleep_quality"` // 1 (very poor) - 5 (very good)
+	SleepHours       *float64       `json:"sleep_hours"`
+	MealType         string         `json:"meal_type"`    // breakfast, lunch, dinner, snack
+	MealPortion      string         `json:"meal_portion"` // none, some, half, most, all
+	BathingAssisted  *bool          `json:"bathing_assisted"`
+	IncidentType     string         `json:"incident_type"`     // fall, wandering, injury, aggression, other
+	IncidentSeverity string         `json:"incident_severity"` // minor, moderate, serious
+	Notes            string         `gorm:"type:text" json:"notes"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
+}
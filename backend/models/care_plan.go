@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type CarePlan struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	PatientID  uint           `gorm:"index" json:"patient_id"`
+	Title      string         `json:"title"`
+	Status     string         `json:"status" gorm:"default:'active'"` // active, completed, archived
+	Version    int            `json:"version"`
+	ReviewDate *time.Time     `json:"review_date"`
+	CreatedBy  uint           `json:"created_by"`
+	Goals      []CarePlanGoal `json:"goals"`
+	Tasks      []CarePlanTask `json:"tasks"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+type CarePlanGoal struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	CarePlanID  uint           `gorm:"index" json:"care_plan_id"`
+	Description string         `json:"description"`
+	TargetDate  *time.Time     `json:"target_date"`
+	Status      string         `json:"status" gorm:"default:'open'"` // open, achieved, dropped
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// CarePlanTask is an intervention assigned to a care-team member
+type CarePlanTask struct {
+	ID           uint           `gorm:"primaryKey" json:"id"`
+	CarePlanID   uint           `gorm:"index" json:"care_plan_id"`
+	GoalID       *uint          `json:"goal_id"`
+	GoalIndex    *int           `gorm:"-" json:"goal_index,omitempty"` // position of the goal in a new plan's request
+	Intervention string         `json:"intervention"`
+	Description  string         `json:"description"`
+	AssignedTo   uint           `gorm:"index" json:"assigned_to"`
+	DueDate      *time.Time     `json:"due_date"`
+	Status       string         `json:"status" gorm:"default:'pending'"` // pending, in_progress, done, cancelled
+	CompletedAt  *time.Time     `json:"completed_at"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// CarePlanRevision is a snapshot of a care plan, including goals and tasks,
+// taken after every change.
+type CarePlanRevision struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	CarePlanID uint      `gorm:"index" json:"care_plan_id"`
+	Version    int       `json:"version"`
+	Snapshot   CarePlan  `gorm:"serializer:json;type:json" json:"snapshot"`
+	ChangedBy  uint      `json:"changed_by"`
+	ChangeNote string    `json:"change_note"`
+	CreatedAt  time.Time `json:"created_at"`
+}
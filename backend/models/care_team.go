@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CareTeamMember links another user, such as a family member or visiting
+// nurse, to a patient alongside the primary caregiver (Patient.CaregiverID).
+// Members can see the patient and manage their appointments.
+type CareTeamMember struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	PatientID uint           `gorm:"index" json:"patient_id"`
+	UserID    uint           `gorm:"index" json:"user_id"`
+	Role      string         `json:"role"` // family, nurse, social_worker, other
+	AddedBy   uint           `json:"added_by"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+// DataMigration records a one-off data migration that has been applied
+type DataMigration struct {
+	Name      string    `gorm:"primaryKey;size:100" json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
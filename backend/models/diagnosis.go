@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Diagnosis is one entry in a patient's diagnosis and staging history.
+// Patient.Diagnosis mirrors the condition of the most recent entry.
+type Diagnosis struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	PatientID  uint           `gorm:"index" json:"patient_id"`
+	Condition  string         `json:"condition"`
+	ICD10Code  string         `gorm:"column:icd10_code;size:10" json:"icd10_code"`
+	StageScale string         `gorm:"size:10" json:"stage_scale"` // GDS, CDR
+	Stage      string         `gorm:"size:10" json:"stage"`
+	AssessedBy uint           `json:"assessed_by"`
+	AssessedAt time.Time      `json:"assessed_at"`
+	Notes      string         `json:"notes"`
+	Source     string         `json:"source" gorm:"default:'assessment'"` // assessment, intake, legacy
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// DrugInteraction is one entry of the drug-drug interaction dataset. Drug
+// names are stored normalized (lower case, single spaces) with DrugA sorting
+// before DrugB, so each pair is stored once.
+type DrugInteraction struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	DrugA       string    `gorm:"size:100;uniqueIndex:idx_drug_pair" json:"drug_a"`
+	DrugB       string    `gorm:"size:100;uniqueIndex:idx_drug_pair" json:"drug_b"`
+	Severity    string    `gorm:"index" json:"severity"` // minor, moderate, major
+	Description string    `gorm:"type:text" json:"description"`
+	Source      string    `json:"source"` // bundled, or the name of the imported file
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
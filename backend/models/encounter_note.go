@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+// EncounterNote is the doctor's clinical note for a completed appointment in
+// SOAP sections. Once signed it is locked; corrections go in addenda.
+// Summary is the plain-language part shown to the patient.
+type EncounterNote struct {
+	ID            uint                `gorm:"primaryKey" json:"id"`
+	AppointmentID uint                `gorm:"uniqueIndex" json:"appointment_id"`
+	DoctorID      uint                `gorm:"index" json:"doctor_id"`
+	Subjective    string              `gorm:"type:text" json:"subjective"`
+	Objective     string              `gorm:"type:text" json:"objective"`
+	Assessment    string              `gorm:"type:text" json:"assessment"`
+	Plan          string              `gorm:"type:text" json:"plan"`
+	Summary       string              `gorm:"type:text" json:"summary"`
+	Status        string              `gorm:"index" json:"status"` // draft, signed
+	SignedBy      uint                `json:"signed_by"`
+	SignedAt      *time.Time          `json:"signed_at"`
+	ContentHash   string              `json:"content_hash"` // sha256 of the sections at signing
+	Addenda       []EncounterAddendum `gorm:"foreignKey:NoteID" json:"addenda"`
+	CreatedAt     time.Time           `json:"created_at"`
+	UpdatedAt     time.Time           `json:"updated_at"`
+}
+
+// EncounterAddendum is appended to a signed note and can never be changed
+type EncounterAddendum struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	NoteID    uint      `gorm:"index" json:"note_id"`
+	AuthorID  uint      `json:"author_id"`
+	Content   string    `gorm:"type:text" json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type GeoPoint struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type SafeZone struct {
+	ID           uint           `gorm:"primaryKey" json:"id"`
+	PatientID    uint           `gorm:"index" json:"patient_id"`
+	Name         string         `json:"name"`
+	Shape        string         `json:"shape"` // circle, polygon
+	CenterLat    float64        `json:"center_lat"`
+	CenterLng    float64        `json:"center_lng"`
+	RadiusMeters float64        `json:"radius_meters"`
+	Polygon      []GeoPoint     `gorm:"serializer:json;type:json" json:"polygon"`
+	Active       bool           `json:"active"`
+	CreatedBy    uint           `json:"created_by"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// LocationPing is a single position report from a patient's phone or tracker.
+// Pings are pruned after the configured retention period.
+type LocationPing struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	PatientID  uint      `gorm:"index:idx_location_pings_patient_recorded" json:"patient_id"`
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	Accuracy   float64   `json:"accuracy"` // meters
+	RecordedAt time.Time `gorm:"index:idx_location_pings_patient_recorded" json:"recorded_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type LocationAlert struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	PatientID      uint       `gorm:"index" json:"patient_id"`
+	SafeZoneID     uint       `json:"safe_zone_id"`
+	PingID         uint       `json:"ping_id"`
+	Type           string     `json:"type"` // left_zone
+	Latitude       float64    `json:"latitude"`
+	Longitude      float64    `json:"longitude"`
+	Status         string     `json:"status" gorm:"default:'open'"` // open, acknowledged
+	AcknowledgedBy uint       `json:"acknowledged_by"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
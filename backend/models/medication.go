@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MedicationSchedule is the structured dosing schedule of a prescription.
+// It is derived from the prescription's free-text frequency and duration
+// unless a doctor sets it explicitly. EffectiveFrom is when its doses last
+// changed; doses due earlier are never marked missed.
+type MedicationSchedule struct {
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	PrescriptionID uint           `gorm:"uniqueIndex" json:"prescription_id"`
+	PatientID      uint           `gorm:"index" json:"patient_id"`
+	Times          string         `json:"times"`     // comma-separated HH:MM dose times in clinic time, e.g. "08:00,20:00"
+	Weekdays       string         `json:"weekdays"`  // comma-separated days the doses are due (0 = Sunday); empty for every day
+	AsNeeded       bool           `json:"as_needed"` // PRN: given when needed, never due or missed
+	StartDate      time.Time      `json:"start_date"`
+	EndDate        *time.Time     `json:"end_date"` // last day, inclusive; open-ended without
+	Derived        bool           `json:"derived"`  // derived from the prescription text, re-derived when it changes
+	EffectiveFrom  time.Time      `json:"effective_from"`
+	Prescription   *Prescription  `gorm:"foreignKey:PrescriptionID" json:"prescription,omitempty"`
+	CreatedBy      uint           `json:"created_by"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// MedicationAdministration is one entry in a patient's medication
+// administration record: a scheduled or as-needed dose that was given,
+// skipped or refused, or a scheduled dose the worker found missed.
+type MedicationAdministration struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	ScheduleID     uint       `gorm:"uniqueIndex:idx_schedule_dose" json:"schedule_id"`
+	DueAt          *time.Time `gorm:"uniqueIndex:idx_schedule_dose" json:"due_at"` // scheduled dose; empty for as-needed doses
+	PrescriptionID uint       `gorm:"index" json:"prescription_id"`
+	PatientID      uint       `gorm:"index" json:"patient_id"`
+	Status         string     `gorm:"index" json:"status"` // given, skipped, refused, missed
+	AdministeredAt *time.Time `json:"administered_at"`
+	RecordedBy     uint       `json:"recorded_by"` // 0 for doses marked missed by the worker
+	Notes          string     `json:"notes"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
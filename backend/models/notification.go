@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// Notification is an outbox entry. Rows are written first and delivered by
+// the background worker, so a failing channel never blocks a request and
+// delivery is retried with backoff.
+type Notification struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	RecipientID   uint       `gorm:"index" json:"recipient_id"` // users.id
+	Kind          string     `json:"kind"`                      // appointment_reminder, ...
+	Subject       string     `json:"subject"`
+	Body          string     `gorm:"type:text" json:"body"`
+	AppointmentID *uint      `gorm:"index" json:"appointment_id"`
+	StartsAt      *time.Time `json:"starts_at"` // appointment start the message was written for
+	DedupeKey     string     `gorm:"size:191;uniqueIndex" json:"-"`
+	Status        string     `gorm:"index" json:"status"` // pending, sending, sent, failed, cancelled
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"index" json:"next_attempt_at"`
+	LastError     string     `json:"last_error"`
+	SentAt        *time.Time `json:"sent_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
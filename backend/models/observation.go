@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Observation struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	PatientID  uint           `gorm:"index:idx_observations_patient_type_measured" json:"patient_id"`
+	Type       string         `gorm:"size:50;index:idx_observations_patient_type_measured" json:"type"` // blood_pressure_systolic, blood_pressure_diastolic, weight, heart_rate, sleep_hours, blood_glucose
+	Value      float64        `json:"value"`
+	Unit       string         `json:"unit"`
+	MeasuredAt time.Time      `gorm:"index:idx_observations_patient_type_measured" json:"measured_at"`
+	RecordedBy uint           `json:"recorded_by"`
+	Notes      string         `json:"notes"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// ObservationThreshold raises an alert when a new observation of the given
+// type falls outside [Min, Max]. Either bound may be left empty.
+type ObservationThreshold struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	PatientID uint           `gorm:"index" json:"patient_id"`
+	Type      string         `gorm:"size:50" json:"type"`
+	Min       *float64       `json:"min"`
+	Max       *float64       `json:"max"`
+	CreatedBy uint           `json:"created_by"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+type ObservationAlert struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	PatientID      uint       `gorm:"index" json:"patient_id"`
+	ObservationID  uint       `json:"observation_id"`
+	ThresholdID    uint       `json:"threshold_id"`
+	Type           string     `json:"type"`
+	Value          float64    `json:"value"`
+	Message        string     `json:"message"`
+	Status         string     `json:"status" gorm:"default:'open'"` // open, acknowledged
+	AcknowledgedBy uint       `json:"acknowledged_by"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
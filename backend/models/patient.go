@@ -8,6 +8,7 @@ import (
 
 type Patient struct {
 	ID          uint           `gorm:"primaryKey" json:"id"`
+	UserID      uint           `gorm:"index" json:"user_id"` // patient's own login, if any
 	Name        string         `json:"name"`
 	Age         int            `json:"age"`
 	Gender      string         `json:"gender"`
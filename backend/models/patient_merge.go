@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// PatientMerge records a merge of a duplicate patient into a surviving record.
+// It keeps enough state to undo the merge. FilledFields lists the survivor
+// fields that were blank and taken from the duplicate. DroppedRecords holds
+// rows of the duplicate that were soft-deleted rather than moved, such as
+// care-team members the survivor already had.
+type PatientMerge struct {
+	ID                uint              `gorm:"primaryKey" json:"id"`
+	SurvivorID        uint              `gorm:"index" json:"survivor_id"`
+	DuplicateID       uint              `gorm:"index" json:"duplicate_id"`
+	MergedBy          uint              `json:"merged_by"`
+	SurvivorSnapshot  Patient           `gorm:"serializer:json;type:json" json:"survivor_snapshot"`
+	DuplicateSnapshot Patient           `gorm:"serializer:json;type:json" json:"duplicate_snapshot"`
+	MovedRecords      map[string][]uint `gorm:"serializer:json;type:json" json:"moved_records"` // table name -> row IDs
+	FromUserID        uint              `json:"from_user_id"`                                   // appointments re-pointed from this patient user
+	ToUserID          uint              `json:"to_user_id"`
+	FilledFields      []string          `gorm:"serializer:json;type:json" json:"filled_fields"`
+	DroppedRecords    map[string][]uint `gorm:"serializer:json;type:json" json:"dropped_records"`
+	Status            string            `json:"status" gorm:"default:'merged'"` // merged, reverted
+	RevertedBy        uint              `json:"reverted_by"`
+	RevertedAt        *time.Time        `json:"reverted_at"`
+	CreatedAt         time.Time         `json:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at"`
+}
@@ -7,15 +7,28 @@ import (
 )
 
 type Prescription struct {
-	ID           uint           `gorm:"primaryKey" json:"id"`
-	PatientID    uint           `json:"patient_id"`
-	DoctorID     uint           `json:"doctor_id"`
-	Medication   string         `json:"medication"`
-	Dosage       string         `json:"dosage"`
-	Frequency    string         `json:"frequency"`
-	Duration     string         `json:"duration"`
-	Instructions string         `json:"instructions"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                        uint           `gorm:"primaryKey" json:"id"`
+	PatientID                 uint           `json:"patient_id"`
+	DoctorID                  uint           `json:"doctor_id"`
+	Medication                string         `json:"medication"`
+	Dosage                    string         `json:"dosage"`
+	Frequency                 string         `json:"frequency"`
+	Duration                  string         `json:"duration"`
+	Instructions              string         `json:"instructions"`
+	AllergyOverrideReason     string         `json:"allergy_override_reason"`     // required when the medication matches a recorded allergy
+	InteractionOverrideReason string         `json:"interaction_override_reason"` // required when the medication has a major interaction
+	CreatedAt                 time.Time      `json:"created_at"`
+	UpdatedAt                 time.Time      `json:"updated_at"`
+	DeletedAt                 gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Interactions with the patient's other medications found when saving
+	Interactions []InteractionMatch `gorm:"-" json:"interactions,omitempty"`
+}
+
+// InteractionMatch is an interaction between a prescription and another of
+// the patient's current prescriptions
+type InteractionMatch struct {
+	PrescriptionID uint            `json:"prescription_id"` // the other prescription
+	Medication     string          `json:"medication"`
+	Interaction    DrugInteraction `json:"interaction"`
 }
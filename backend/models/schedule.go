@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DoctorSchedule is one block of weekly working hours. A doctor may have
+// several blocks on the same weekday, e.g. a morning and an afternoon shift.
+type DoctorSchedule struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	DoctorID  uint           `gorm:"index" json:"doctor_id"`
+	Weekday   int            `json:"weekday"`    // 0 = Sunday ... 6 = Saturday
+	StartTime string         `json:"start_time"` // HH:MM
+	EndTime   string         `json:"end_time"`   // HH:MM
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// ScheduleException blocks out time in a doctor's schedule, such as vacation,
+// a public holiday or a conference.
+type ScheduleException struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	DoctorID  uint           `gorm:"index" json:"doctor_id"`
+	StartAt   time.Time      `json:"start_at"`
+	EndAt     time.Time      `json:"end_at"`
+	Reason    string         `json:"reason"` // vacation, holiday, leave, other
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// AppointmentTypeDuration overrides the default slot length of an
+// appointment type for one doctor.
+type AppointmentTypeDuration struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	DoctorID        uint      `gorm:"uniqueIndex:idx_doctor_appointment_type" json:"doctor_id"`
+	Type            string    `gorm:"size:50;uniqueIndex:idx_doctor_appointment_type" json:"type"`
+	DurationMinutes int       `json:"duration_minutes"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SymptomEpisode is one behavioral or psychological symptom episode
+type SymptomEpisode struct {
+	ID                    uint           `gorm:"primaryKey" json:"id"`
+	PatientID             uint           `gorm:"index:idx_symptom_patient_onset" json:"patient_id"`
+	RecordedBy            uint           `json:"recorded_by"`
+	Symptom               string         `gorm:"size:30;index" json:"symptom"` // agitation, sundowning, aggression, hallucinations, wandering, other
+	OnsetAt               time.Time      `gorm:"index:idx_symptom_patient_onset" json:"onset_at"`
+	DurationMinutes       int            `json:"duration_minutes"`
+	Severity              int            `json:"severity"` // 1 (mild) - 5 (severe)
+	Triggers              []string       `gorm:"serializer:json;type:json" json:"triggers"`
+	Interventions         []string       `gorm:"serializer:json;type:json" json:"interventions"`
+	InterventionEffective *bool          `json:"intervention_effective"`
+	Notes                 string         `gorm:"type:text" json:"notes"`
+	CreatedAt             time.Time      `json:"created_at"`
+	UpdatedAt             time.Time      `json:"updated_at"`
+	DeletedAt             gorm.DeletedAt `gorm:"index" json:"-"`
+}
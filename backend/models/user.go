@@ -13,6 +13,7 @@ type User struct {
 	UserType  string         `gorm:"not null;default:'doctor'" json:"user_type"` // doctor, caregiver, patient
 	Name      string         `json:"name"`
 	Phone     string         `json:"phone"`
+	TimeZone  string         `json:"time_zone"` // IANA name, used to show times to the user
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
@@ -29,6 +30,7 @@ type RegisterRequest struct {
 	UserType string `json:"user_type" binding:"required"`
 	Name     string `json:"name" binding:"required"`
 	Phone    string `json:"phone"`
+	TimeZone string `json:"time_zone"`
 }
 
 type AuthResponse struct {
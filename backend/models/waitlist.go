@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WaitlistEntry is a patient waiting for an earlier appointment with a
+// doctor, somewhere between EarliestDate and LatestDate.
+type WaitlistEntry struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	PatientID     uint           `gorm:"index" json:"patient_id"` // users.id, as in appointments
+	DoctorID      uint           `gorm:"index" json:"doctor_id"`
+	Type          string         `json:"type"`
+	EarliestDate  time.Time      `json:"earliest_date"`
+	LatestDate    time.Time      `json:"latest_date"`
+	Notes         string         `json:"notes"`
+	Status        string         `gorm:"index" json:"status"` // waiting, offered, booked, cancelled, expired
+	AppointmentID *uint          `json:"appointment_id"`      // set once an offer is accepted
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// SlotOffer holds a freed slot for one waitlisted patient until ExpiresAt.
+// While the offer is open the slot counts as busy for everyone else.
+type SlotOffer struct {
+	ID                  uint       `gorm:"primaryKey" json:"id"`
+	EntryID             uint       `gorm:"index" json:"entry_id"`
+	PatientID           uint       `gorm:"index" json:"patient_id"`
+	DoctorID            uint       `gorm:"index" json:"doctor_id"`
+	Type                string     `json:"type"`
+	StartAt             time.Time  `gorm:"index" json:"start_at"`
+	EndAt               time.Time  `json:"end_at"`
+	ExpiresAt           time.Time  `gorm:"index" json:"expires_at"`
+	Status              string     `gorm:"index" json:"status"`   // offered, accepted, declined, expired, withdrawn
+	SourceAppointmentID *uint      `json:"source_appointment_id"` // the cancelled appointment that freed the slot
+	AppointmentID       *uint      `json:"appointment_id"`        // booked when accepted
+	RespondedAt         *time.Time `json:"responded_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,224 @@
+package notify
+
+import (
+	"dementicare-backend/models"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxAttempts is how many times delivery is tried before a notification is
+// marked failed
+const MaxAttempts = 6
+
+// Message is what a channel delivers to one recipient
+type Message struct {
+	RecipientID uint
+	Name        string
+	Email       string
+	Phone       string
+	Subject     string
+	Body        string
+}
+
+// Notifier delivers messages over one channel (log, email, SMS, push, ...)
+type Notifier interface {
+	Send(msg Message) error
+}
+
+// LogNotifier writes messages to the server log. It is the default channel
+// and is useful in development.
+type LogNotifier struct{}
+
+func (LogNotifier) Send(msg Message) error {
+	log.Printf("Notification to user %d <%s> - %s: %s", msg.RecipientID, msg.Email, msg.Subject, msg.Body)
+	return nil
+}
+
+// Enqueue adds a notification to the outbox. A notification whose dedupe key
+// is already queued is ignored, so callers can enqueue on every run.
+func Enqueue(db *gorm.DB, n models.Notification) error {
+	if n.Status == "" {
+		n.Status = "pending"
+	}
+	if n.NextAttemptAt.IsZero() {
+		n.NextAttemptAt = time.Now()
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&n).Error
+}
+
+// CaregiverRecipients returns the primary caregiver and care-team members
+// of a patient record (patients.id)
+func CaregiverRecipients(db *gorm.DB, patientID uint) ([]uint, error) {
+	var caregivers, members []uint
+	if err := db.Model(&models.Patient{}).
+		Where("id = ? AND caregiver_id <> 0", patientID).
+		Pluck("caregiver_id", &caregivers).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.CareTeamMember{}).
+		Where("patient_id = ?", patientID).
+		Distinct().Pluck("user_id", &members).Error; err != nil {
+		return nil, err
+	}
+
+	var recipients []uint
+	seen := make(map[uint]bool)
+	for _, id := range append(caregivers, members...) {
+		if !seen[id] {
+			seen[id] = true
+			recipients = append(recipients, id)
+		}
+	}
+	return recipients, nil
+}
+
+// PatientRecipients returns the users told about a patient's appointments:
+// the patient's own login, the caregivers of their patient records and their
+// care-team members.
+func PatientRecipients(db *gorm.DB, patientUserID uint) ([]uint, error) {
+	records := db.Model(&models.Patient{}).Select("id").Where("user_id = ?", patientUserID)
+
+	var caregivers, members []uint
+	if err := db.Model(&models.Patient{}).
+		Where("user_id = ? AND caregiver_id <> 0", patientUserID).
+		Distinct().Pluck("caregiver_id", &caregivers).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.CareTeamMember{}).
+		Where("patient_id IN (?)", records).
+		Distinct().Pluck("user_id", &members).Error; err != nil {
+		return nil, err
+	}
+
+	recipients := []uint{patientUserID}
+	seen := map[uint]bool{patientUserID: true}
+	for _, id := range append(caregivers, members...) {
+		if !seen[id] {
+			seen[id] = true
+			recipients = append(recipients, id)
+		}
+	}
+	return recipients, nil
+}
+
+// Validator reports whether a queued notification should still be sent.
+// Returning false cancels it.
+type Validator func(n models.Notification) (bool, error)
+
+// DeliverDue sends up to limit notifications that are due, retrying failures
+// with exponential backoff. Each row is claimed before it is sent, so
+// several workers never deliver the same notification.
+func DeliverDue(db *gorm.DB, notifier Notifier, valid Validator, now time.Time, limit int) error {
+	var due []models.Notification
+	if err := db.Where("status IN ? AND next_attempt_at <= ?", []string{"pending", "sending"}, now).
+		Order("next_attempt_at asc").Limit(limit).Find(&due).Error; err != nil {
+		return err
+	}
+
+	for _, n := range due {
+		lease := now.Add(ClaimLease).Truncate(time.Second)
+		claimed, err := claim(db, n.ID, now, lease)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+
+		if valid != nil {
+			ok, err := valid(n)
+			if err != nil {
+				log.Printf("Error checking notification %d: %v", n.ID, err)
+				if err := finish(db, n.ID, lease, map[string]interface{}{"status": "pending", "next_attempt_at": now.Add(Backoff(1))}); err != nil {
+					return err
+				}
+				continue
+			}
+			if !ok {
+				if err := finish(db, n.ID, lease, map[string]interface{}{"status": "cancelled"}); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		var recipient models.User
+		if err := db.First(&recipient, n.RecipientID).Error; err != nil {
+			if err := finish(db, n.ID, lease, map[string]interface{}{"status": "failed", "last_error": "recipient not found"}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		err = notifier.Send(Message{
+			RecipientID: recipient.ID,
+			Name:        recipient.Name,
+			Email:       recipient.Email,
+			Phone:       recipient.Phone,
+			Subject:     n.Subject,
+			Body:        n.Body,
+		})
+		if err == nil {
+			if err := finish(db, n.ID, lease, map[string]interface{}{"status": "sent", "sent_at": now, "attempts": n.Attempts + 1, "last_error": ""}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		attempts := n.Attempts + 1
+		updates := map[string]interface{}{"attempts": attempts, "last_error": err.Error()}
+		if attempts >= MaxAttempts {
+			updates["status"] = "failed"
+		} else {
+			updates["status"] = "pending"
+			updates["next_attempt_at"] = now.Add(Backoff(attempts))
+		}
+		log.Printf("Notification %d delivery failed (attempt %d): %v", n.ID, attempts, err)
+		if err := finish(db, n.ID, lease, updates); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClaimLease is how long a claimed notification stays reserved for the
+// worker sending it. A worker that dies mid-send leaves the row "sending";
+// it is picked up again once the lease has run out.
+const ClaimLease = 10 * time.Minute
+
+// claim marks a due notification as being sent until lease. The update only
+// matches while the row is still due, so only one worker wins it.
+func claim(db *gorm.DB, id uint, now, lease time.Time) (bool, error) {
+	result := db.Model(&models.Notification{}).
+		Where("id = ? AND status IN ? AND next_attempt_at <= ?", id, []string{"pending", "sending"}, now).
+		Updates(map[string]interface{}{"status": "sending", "next_attempt_at": lease})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// finish records the outcome of a notification claimed until lease. A claim
+// that expired and was taken over by another worker is left alone.
+func finish(db *gorm.DB, id uint, lease time.Time, updates map[string]interface{}) error {
+	err := db.Model(&models.Notification{}).
+		Where("id = ? AND status = ? AND next_attempt_at = ?", id, "sending", lease).
+		Updates(updates).Error
+	if err != nil {
+		return fmt.Errorf("recording outcome of notification %d: %w", id, err)
+	}
+	return nil
+}
+
+// Backoff is the wait before the next attempt: 1m, 2m, 4m, ... up to 1h
+func Backoff(attempts int) time.Duration {
+	wait := time.Minute << uint(attempts-1)
+	if wait <= 0 || wait > time.Hour {
+		wait = time.Hour
+	}
+	return wait
+}
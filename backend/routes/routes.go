@@ -20,8 +20,15 @@ func SetupRoutes(router *gin.Engine) {
 		auth.POST("/login", controllers.Login)
 		auth.POST("/user/login", controllers.Login) // Alternative endpoint
 		auth.POST("/change-password", middleware.AuthMiddleware(), controllers.ChangePassword)
+		auth.PUT("/time-zone", middleware.AuthMiddleware(), controllers.UpdateTimeZone)
 	}
 
+	// iCalendar subscription feeds, authenticated by the secret token in the URL
+	router.GET("/calendar/:token", controllers.ServeCalendarFeed)
+
+	// Telehealth signaling socket, authenticated by the room token
+	router.GET("/telehealth/ws", controllers.TelehealthSocket)
+
 	// Protected routes
 	api := router.Group("/api")
 	api.Use(middleware.AuthMiddleware())
@@ -30,20 +37,191 @@ func SetupRoutes(router *gin.Engine) {
 		patients := api.Group("/patients")
 		{
 			patients.GET("", controllers.GetPatients)
+			patients.GET("/export", controllers.ExportPatients)
+			patients.POST("/import", controllers.ImportPatients)
+			patients.GET("/duplicates", controllers.FindDuplicatePatients)
+			patients.GET("/merges", controllers.GetPatientMerges)
+			patients.POST("/merge", controllers.MergePatients)
+			patients.POST("/merges/:id/revert", controllers.RevertPatientMerge)
 			patients.GET("/:id", controllers.GetPatient)
 			patients.POST("", controllers.CreatePatient)
 			patients.PUT("/:id", controllers.UpdatePatient)
 			patients.DELETE("/:id", controllers.DeletePatient)
+			patients.PUT("/:id/user", controllers.LinkPatientUser)
+
+			// Care team: caregivers who may act for the patient
+			patients.GET("/:id/care-team", controllers.GetCareTeam)
+			patients.POST("/:id/care-team", controllers.AddCareTeamMember)
+			patients.DELETE("/:id/care-team/:memberId", controllers.RemoveCareTeamMember)
+
+			// Signed encounter notes of the patient's appointments
+			patients.GET("/:id/encounter-notes", controllers.GetPatientEncounterNotes)
+
+			// Attendance history and no-show policy state
+			patients.GET("/:id/attendance", controllers.GetPatientAttendance)
+
+			// Medication schedules and administration record
+			patients.GET("/:id/medication-schedules", controllers.GetMedicationSchedules)
+			patients.GET("/:id/medication-doses", controllers.GetMedicationDoses)
+			patients.GET("/:id/medication-administrations", controllers.GetMedicationAdministrations)
+			patients.POST("/:id/medication-administrations", controllers.RecordMedicationAdministration)
+			patients.GET("/:id/interactions", controllers.GetPatientInteractions)
+
+			// Patient attachments
+			patients.GET("/:id/attachments", controllers.GetAttachments)
+			patients.POST("/:id/attachments", controllers.UploadAttachment)
+
+			// Wandering safe zones and location tracking
+			patients.GET("/:id/safe-zones", controllers.GetSafeZones)
+			patients.POST("/:id/safe-zones", controllers.CreateSafeZone)
+			patients.GET("/:id/locations", controllers.GetLocationHistory)
+			patients.POST("/:id/locations", controllers.RecordLocation)
+
+			// Vital signs and health observations
+			patients.GET("/:id/observations", controllers.GetObservations)
+			patients.POST("/:id/observations", controllers.CreateObservations)
+			patients.GET("/:id/observations/summary", controllers.GetObservationSummary)
+			patients.GET("/:id/observation-thresholds", controllers.GetObservationThresholds)
+			patients.POST("/:id/observation-thresholds", controllers.CreateObservationThreshold)
+
+			// Diagnosis and staging history
+			patients.GET("/:id/diagnoses", controllers.GetDiagnoses)
+			patients.GET("/:id/diagnoses/current", controllers.GetCurrentDiagnosis)
+			patients.POST("/:id/diagnoses", controllers.CreateDiagnosis)
+
+			// Allergies
+			patients.GET("/:id/allergies", controllers.GetAllergies)
+			patients.POST("/:id/allergies", controllers.CreateAllergy)
+
+			// Caregiver care journal
+			patients.GET("/:id/care-log", controllers.GetCareLog)
+			patients.POST("/:id/care-log", controllers.CreateCareLogEntry)
+			patients.GET("/:id/care-log/summary", controllers.GetCareLogSummary)
+
+			// Behavioral and psychological symptoms
+			patients.GET("/:id/symptoms", controllers.GetSymptomEpisodes)
+			patients.POST("/:id/symptoms", controllers.CreateSymptomEpisode)
+			patients.GET("/:id/symptoms/time-of-day", controllers.GetSymptomTimeOfDay)
+			patients.GET("/:id/symptoms/weekly-trend", controllers.GetSymptomWeeklyTrend)
+
+			// Care plans
+			patients.GET("/:id/care-plans", controllers.GetCarePlans)
+			patients.POST("/:id/care-plans", controllers.CreateCarePlan)
+		}
+
+		// Attachment routes
+		attachments := api.Group("/attachments")
+		{
+			attachments.GET("/:id/download", controllers.DownloadAttachment)
+			attachments.DELETE("/:id", controllers.DeleteAttachment)
+		}
+
+		// Safe zone routes
+		safeZones := api.Group("/safe-zones")
+		{
+			safeZones.PUT("/:id", controllers.UpdateSafeZone)
+			safeZones.DELETE("/:id", controllers.DeleteSafeZone)
+		}
+
+		// Location alert routes
+		locationAlerts := api.Group("/location-alerts")
+		{
+			locationAlerts.GET("", controllers.GetLocationAlerts)
+			locationAlerts.POST("/:id/acknowledge", controllers.AcknowledgeLocationAlert)
+		}
+
+		// Observation threshold and alert routes
+		api.DELETE("/observation-thresholds/:id", controllers.DeleteObservationThreshold)
+		observationAlerts := api.Group("/observation-alerts")
+		{
+			observationAlerts.GET("", controllers.GetObservationAlerts)
+			observationAlerts.POST("/:id/acknowledge", controllers.AcknowledgeObservationAlert)
 		}
 
+		// Allergy routes
+		allergies := api.Group("/allergies")
+		{
+			allergies.PUT("/:id", controllers.UpdateAllergy)
+			allergies.DELETE("/:id", controllers.DeleteAllergy)
+		}
+
+		// Care log routes
+		careLog := api.Group("/care-log")
+		{
+			careLog.PUT("/:id", controllers.UpdateCareLogEntry)
+			careLog.DELETE("/:id", controllers.DeleteCareLogEntry)
+		}
+
+		// Symptom episode routes
+		symptoms := api.Group("/symptoms")
+		{
+			symptoms.PUT("/:id", controllers.UpdateSymptomEpisode)
+			symptoms.DELETE("/:id", controllers.DeleteSymptomEpisode)
+		}
+
+		// Care plan routes
+		carePlans := api.Group("/care-plans")
+		{
+			carePlans.GET("/:id", controllers.GetCarePlan)
+			carePlans.PUT("/:id", controllers.UpdateCarePlan)
+			carePlans.GET("/:id/revisions", controllers.GetCarePlanRevisions)
+			carePlans.POST("/:id/goals", controllers.CreateCarePlanGoal)
+			carePlans.POST("/:id/tasks", controllers.CreateCarePlanTask)
+		}
+		api.PUT("/care-plan-goals/:id", controllers.UpdateCarePlanGoal)
+		api.PUT("/care-plan-tasks/:id", controllers.UpdateCarePlanTask)
+		api.GET("/my-tasks", controllers.GetMyTasks)
+
 		// Appointment routes
 		appointments := api.Group("/appointments")
 		{
 			appointments.GET("", controllers.GetAppointments)
+			appointments.GET("/calendar", controllers.GetAppointmentCalendar)
 			appointments.GET("/:id", controllers.GetAppointment)
 			appointments.POST("", controllers.CreateAppointment)
 			appointments.PUT("/:id", controllers.UpdateAppointment)
 			appointments.DELETE("/:id", controllers.DeleteAppointment)
+
+			// Status transitions
+			appointments.GET("/:id/history", controllers.GetAppointmentHistory)
+			appointments.GET("/:id/ics", controllers.GetAppointmentICS)
+			appointments.POST("/:id/join", controllers.JoinVisit)
+			appointments.POST("/:id/confirm", controllers.ConfirmAppointment)
+			appointments.POST("/:id/caregiver-confirm", controllers.CaregiverConfirmAppointment)
+			appointments.POST("/:id/complete", controllers.CompleteAppointment)
+			appointments.POST("/:id/cancel", controllers.CancelAppointment)
+			appointments.POST("/:id/no-show", controllers.MarkAppointmentNoShow)
+			appointments.POST("/:id/reschedule", controllers.RescheduleAppointment)
+
+			// Clinical encounter notes (SOAP)
+			appointments.GET("/:id/note", controllers.GetEncounterNote)
+			appointments.PUT("/:id/note", controllers.SaveEncounterNote)
+			appointments.POST("/:id/note/sign", controllers.SignEncounterNote)
+			appointments.POST("/:id/note/addenda", controllers.AddEncounterAddendum)
+		}
+
+		// Doctor waitlists and slot offers
+		waitlists := api.Group("/waitlist")
+		{
+			waitlists.GET("", controllers.GetWaitlist)
+			waitlists.POST("", controllers.JoinWaitlist)
+			waitlists.DELETE("/:id", controllers.LeaveWaitlist)
+			waitlists.GET("/offers", controllers.GetWaitlistOffers)
+			waitlists.POST("/offers/:id/accept", controllers.AcceptWaitlistOffer)
+			waitlists.POST("/offers/:id/decline", controllers.DeclineWaitlistOffer)
+		}
+
+		// Calendar subscription URL
+		api.GET("/calendar/feed", controllers.GetCalendarFeed)
+		api.POST("/calendar/feed/rotate", controllers.RotateCalendarFeed)
+
+		// Recurring appointment series
+		series := api.Group("/appointment-series")
+		{
+			series.POST("", controllers.CreateAppointmentSeries)
+			series.GET("/:id", controllers.GetAppointmentSeries)
+			series.PUT("/:id", controllers.UpdateAppointmentSeries)
+			series.POST("/:id/cancel", controllers.CancelAppointmentSeries)
 		}
 
 		// Prescription routes
@@ -54,8 +232,14 @@ func SetupRoutes(router *gin.Engine) {
 			prescriptions.POST("", controllers.CreatePrescription)
 			prescriptions.PUT("/:id", controllers.UpdatePrescription)
 			prescriptions.DELETE("/:id", controllers.DeletePrescription)
+			prescriptions.GET("/:id/schedule", controllers.GetPrescriptionSchedule)
+			prescriptions.PUT("/:id/schedule", controllers.UpdatePrescriptionSchedule)
 		}
 
+		// Drug-drug interaction dataset
+		api.GET("/drug-interactions", controllers.GetDrugInteractions)
+		api.POST("/drug-interactions/import", controllers.ImportDrugInteractions)
+
 		// Quiz routes
 		quiz := api.Group("/quiz")
 		{
@@ -78,6 +262,19 @@ func SetupRoutes(router *gin.Engine) {
 
 		// Doctors list for appointment booking
 		api.GET("/doctors", controllers.GetDoctors)
+
+		// Doctor availability and bookable slots
+		doctors := api.Group("/doctors")
+		{
+			doctors.GET("/:id/slots", controllers.GetDoctorSlots)
+			doctors.GET("/:id/schedule", controllers.GetDoctorSchedule)
+			doctors.PUT("/:id/schedule", controllers.UpdateDoctorSchedule)
+			doctors.PUT("/:id/appointment-types", controllers.UpdateAppointmentTypeDuration)
+			doctors.GET("/:id/exceptions", controllers.GetScheduleExceptions)
+			doctors.POST("/:id/exceptions", controllers.CreateScheduleException)
+			doctors.DELETE("/:id/exceptions/:exceptionId", controllers.DeleteScheduleException)
+			doctors.GET("/:id/attendance", controllers.GetDoctorAttendance)
+		}
 	}
 
 	// Contact form (public)
@@ -0,0 +1,286 @@
+package scheduling
+
+import (
+	"dementicare-backend/models"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrSlotUnavailable is returned when a requested time is outside the
+	// doctor's working hours or does not line up with a slot
+	ErrSlotUnavailable = errors.New("requested time is not an available slot")
+	// ErrSlotTaken is returned when the slot clashes with another booking
+	ErrSlotTaken = errors.New("requested slot is already booked")
+)
+
+// Statuses of appointments that no longer occupy the doctor's time
+var InactiveStatuses = []string{"cancelled", "no_show", "rescheduled"}
+
+var (
+	locationOnce sync.Once
+	location     *time.Location
+)
+
+// Location is the clinic time zone (CLINIC_TIMEZONE, an IANA name such as
+// "Europe/London") that working hours, appointment dates and HH:MM times are
+// interpreted in. Without it the server's zone is used.
+func Location() *time.Location {
+	locationOnce.Do(func() {
+		location = time.Local
+		if name := os.Getenv("CLINIC_TIMEZONE"); name != "" {
+			loc, err := time.LoadLocation(name)
+			if err != nil {
+				log.Printf("Invalid CLINIC_TIMEZONE %q, using the server time zone: %v", name, err)
+				return
+			}
+			location = loc
+		}
+	})
+	return location
+}
+
+// ZoneName returns the IANA name of the clinic time zone, or "" when the
+// server's unnamed local zone is used.
+func ZoneName() string {
+	if name := Location().String(); name != "Local" {
+		return name
+	}
+	return ""
+}
+
+// AppointmentStart returns the instant the appointment starts. Rows without
+// StartAt fall back to the calendar day of Date with the HH:MM Time in the
+// clinic time zone, and to Date as is without a valid Time.
+func AppointmentStart(a models.Appointment) time.Time {
+	if !a.StartAt.IsZero() {
+		return a.StartAt
+	}
+	start, err := StartFromClock(a.Date, a.Time)
+	if err != nil {
+		return a.Date
+	}
+	return start
+}
+
+// StartFromClock combines the calendar day of date, as written, with an
+// HH:MM clock time in the clinic time zone.
+func StartFromClock(date time.Time, clock string) (time.Time, error) {
+	minutes, err := ParseClock(clock)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return clockOn(date, minutes, Location()), nil
+}
+
+// SetStart stores start as the appointment's canonical UTC instant and fills
+// in the clinic-time Date (the calendar day, at midnight UTC), Time and
+// TimeZone kept for older clients.
+func SetStart(a *models.Appointment, start time.Time) {
+	local := start.In(Location())
+	a.StartAt = start.UTC()
+	a.Date = time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	a.Time = local.Format("15:04")
+	a.TimeZone = ZoneName()
+}
+
+// AppointmentWindow returns the time an appointment occupies
+func AppointmentWindow(a models.Appointment) Window {
+	start := AppointmentStart(a)
+	if !a.StartAt.IsZero() && a.EndAt.After(a.StartAt) {
+		return Window{Start: start, End: a.EndAt}
+	}
+
+	minutes := a.DurationMinutes
+	if minutes <= 0 {
+		minutes = DefaultDurations[NormalizeType(a.Type)]
+	}
+	if minutes <= 0 {
+		minutes = DefaultDurationMinutes
+	}
+	return Window{Start: start, End: start.Add(time.Duration(minutes) * time.Minute)}
+}
+
+// Duration returns the slot length for an appointment type with a doctor,
+// preferring the doctor's own setting over the default.
+func Duration(db *gorm.DB, doctorID uint, appointmentType string) time.Duration {
+	appointmentType = NormalizeType(appointmentType)
+
+	var custom models.AppointmentTypeDuration
+	if db.Where("doctor_id = ? AND type = ?", doctorID, appointmentType).Limit(1).Find(&custom).RowsAffected > 0 && custom.DurationMinutes > 0 {
+		return time.Duration(custom.DurationMinutes) * time.Minute
+	}
+	if minutes, ok := DefaultDurations[appointmentType]; ok {
+		return time.Duration(minutes) * time.Minute
+	}
+	return DefaultDurationMinutes * time.Minute
+}
+
+// BusyWindows returns the time taken by the doctor's active appointments,
+// schedule exceptions and held waitlist offers between from and to. excludeID skips one appointment,
+// which lets an appointment be moved within its own time.
+func BusyWindows(db *gorm.DB, doctorID uint, from, to time.Time, excludeID uint) ([]Window, error) {
+	var appointments []models.Appointment
+	query := db.Where("doctor_id = ? AND start_at < ? AND end_at > ?", doctorID, to, from).
+		Where("status NOT IN ?", InactiveStatuses)
+	if excludeID != 0 {
+		query = query.Where("id <> ?", excludeID)
+	}
+	if err := query.Find(&appointments).Error; err != nil {
+		return nil, err
+	}
+
+	var exceptions []models.ScheduleException
+	if err := db.Where("doctor_id = ? AND start_at < ? AND end_at > ?", doctorID, to, from).Find(&exceptions).Error; err != nil {
+		return nil, err
+	}
+
+	// Slots held for a waitlisted patient are busy until the offer ends
+	var holds []models.SlotOffer
+	if err := db.Where("doctor_id = ? AND status = ? AND expires_at > ? AND start_at < ? AND end_at > ?",
+		doctorID, "offered", time.Now(), to, from).Find(&holds).Error; err != nil {
+		return nil, err
+	}
+
+	windows := make([]Window, 0, len(appointments)+len(exceptions)+len(holds))
+	for _, a := range appointments {
+		windows = append(windows, AppointmentWindow(a))
+	}
+	for _, e := range exceptions {
+		windows = append(windows, Window{Start: e.StartAt, End: e.EndAt})
+	}
+	for _, h := range holds {
+		windows = append(windows, Window{Start: h.StartAt, End: h.EndAt})
+	}
+	return windows, nil
+}
+
+// FreeSlots lists the doctor's free slots for an appointment type between
+// from and to. Slots in the past are never returned.
+func FreeSlots(db *gorm.DB, doctorID uint, appointmentType string, from, to time.Time) ([]Slot, error) {
+	if now := time.Now(); from.Before(now) {
+		from = now
+	}
+
+	var templates []models.DoctorSchedule
+	if err := db.Where("doctor_id = ?", doctorID).Find(&templates).Error; err != nil {
+		return nil, err
+	}
+
+	busy, err := BusyWindows(db, doctorID, from, to, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return GenerateSlots(templates, busy, from, to, Duration(db, doctorID, appointmentType), Location()), nil
+}
+
+// CheckBookable returns nil if start is the beginning of one of the doctor's
+// free slots for the appointment type, ErrSlotTaken if the slot exists but
+// clashes with a booking or held offer, and ErrSlotUnavailable otherwise,
+// including when the doctor has time off then. excludeID is ignored when
+// looking for clashes.
+func CheckBookable(db *gorm.DB, doctorID uint, appointmentType string, start time.Time, excludeID uint) error {
+	if !start.After(time.Now()) {
+		return ErrSlotUnavailable
+	}
+
+	duration := Duration(db, doctorID, appointmentType)
+
+	var templates []models.DoctorSchedule
+	if err := db.Where("doctor_id = ? AND weekday = ?", doctorID, int(start.In(Location()).Weekday())).Find(&templates).Error; err != nil {
+		return err
+	}
+
+	from, to := start, start.Add(duration)
+	if !containsStart(GenerateSlots(templates, nil, from, to, duration, Location()), start) {
+		return ErrSlotUnavailable
+	}
+
+	var exceptions int64
+	if err := db.Model(&models.ScheduleException{}).
+		Where("doctor_id = ? AND start_at < ? AND end_at > ?", doctorID, to, from).Count(&exceptions).Error; err != nil {
+		return err
+	}
+	if exceptions > 0 {
+		return ErrSlotUnavailable
+	}
+
+	busy, err := BusyWindows(db, doctorID, from, to, excludeID)
+	if err != nil {
+		return err
+	}
+	if overlapsAny(Window{Start: from, End: to}, busy) {
+		return ErrSlotTaken
+	}
+	return nil
+}
+
+// Reserve locks the doctor's calendar until tx ends, checks that the
+// appointment starts at a free slot and sets its start, end and duration. Concurrent
+// bookings with the same doctor wait on the lock, so only one of two
+// requests for the same slot can succeed.
+func Reserve(tx *gorm.DB, a *models.Appointment, excludeID uint) error {
+	if err := LockDoctor(tx, a.DoctorID); err != nil {
+		return err
+	}
+
+	start := AppointmentStart(*a)
+	if err := CheckBookable(tx, a.DoctorID, a.Type, start, excludeID); err != nil {
+		return err
+	}
+
+	duration := Duration(tx, a.DoctorID, a.Type)
+	SetStart(a, start)
+	a.EndAt = a.StartAt.Add(duration)
+	a.DurationMinutes = int(duration / time.Minute)
+	return nil
+}
+
+// LockDoctor locks the doctor's calendar until tx ends by locking their user
+// row. It fails with gorm.ErrRecordNotFound if the user is not a doctor.
+func LockDoctor(tx *gorm.DB, doctorID uint) error {
+	var doctor models.User
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+		Where("user_type = ?", "doctor").First(&doctor, doctorID).Error
+}
+
+// NearestSlots returns up to n free slots closest to the requested time,
+// looking a few days back and two weeks ahead.
+func NearestSlots(db *gorm.DB, doctorID uint, appointmentType string, around time.Time, n int) ([]Slot, error) {
+	slots, err := FreeSlots(db, doctorID, appointmentType, around.AddDate(0, 0, -3), around.AddDate(0, 0, 14))
+	if err != nil {
+		return nil, err
+	}
+
+	distance := func(s Slot) time.Duration {
+		d := s.Start.Sub(around)
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+	sort.SliceStable(slots, func(i, j int) bool { return distance(slots[i]) < distance(slots[j]) })
+
+	if len(slots) > n {
+		slots = slots[:n]
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i].Start.Before(slots[j].Start) })
+	return slots, nil
+}
+
+func containsStart(slots []Slot, start time.Time) bool {
+	for _, slot := range slots {
+		if slot.Start.Equal(start) {
+			return true
+		}
+	}
+	return false
+}
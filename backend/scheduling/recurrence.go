@@ -0,0 +1,69 @@
+package scheduling
+
+import (
+	"errors"
+	"time"
+)
+
+// MaxOccurrences caps the size of a single appointment series
+const MaxOccurrences = 52
+
+// Rule is an RRULE-style recurrence: FREQ, INTERVAL and either COUNT or
+// UNTIL. Frequencies are "weekly" and "monthly".
+type Rule struct {
+	Frequency string     `json:"frequency"`
+	Interval  int        `json:"interval"`
+	Count     int        `json:"count"`
+	Until     *time.Time `json:"until"`
+}
+
+func (r Rule) Validate() error {
+	if r.Frequency != "weekly" && r.Frequency != "monthly" {
+		return errors.New("frequency must be weekly or monthly")
+	}
+	if r.Interval < 1 || r.Interval > 12 {
+		return errors.New("interval must be between 1 and 12")
+	}
+	if (r.Count == 0) == (r.Until == nil) {
+		return errors.New("give exactly one of count or until")
+	}
+	if r.Count < 0 || r.Count > MaxOccurrences {
+		return errors.New("count must be between 1 and 52")
+	}
+	return nil
+}
+
+// Occurrences expands the rule from the first start. Every occurrence is
+// computed from the first one, so monthly series on the 31st land on the last
+// day of shorter months instead of drifting.
+func (r Rule) Occurrences(first time.Time) []time.Time {
+	var out []time.Time
+	for i := 0; len(out) < MaxOccurrences; i++ {
+		if r.Count > 0 && i >= r.Count {
+			break
+		}
+
+		var next time.Time
+		if r.Frequency == "weekly" {
+			next = first.AddDate(0, 0, 7*r.Interval*i)
+		} else {
+			next = addMonthsClamped(first, r.Interval*i)
+		}
+
+		if r.Until != nil && next.After(*r.Until) {
+			break
+		}
+		out = append(out, next)
+	}
+	return out
+}
+
+func addMonthsClamped(t time.Time, months int) time.Time {
+	firstOfMonth := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return firstOfMonth.AddDate(0, 0, day-1)
+}
@@ -0,0 +1,116 @@
+package scheduling
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRuleValidate(t *testing.T) {
+	until := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		rule    Rule
+		wantErr bool
+	}{
+		{"weekly count", Rule{Frequency: "weekly", Interval: 1, Count: 4}, false},
+		{"monthly until", Rule{Frequency: "monthly", Interval: 2, Until: &until}, false},
+		{"daily", Rule{Frequency: "daily", Interval: 1, Count: 4}, true},
+		{"zero interval", Rule{Frequency: "weekly", Count: 4}, true},
+		{"interval too large", Rule{Frequency: "weekly", Interval: 13, Count: 4}, true},
+		{"neither count nor until", Rule{Frequency: "weekly", Interval: 1}, true},
+		{"both count and until", Rule{Frequency: "weekly", Interval: 1, Count: 4, Until: &until}, true},
+		{"count too large", Rule{Frequency: "weekly", Interval: 1, Count: MaxOccurrences + 1}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.rule.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRuleOccurrences(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 10, 0, 0, 0, time.UTC)
+	}
+	until := date(2026, 2, 1)
+	tests := []struct {
+		name  string
+		rule  Rule
+		first time.Time
+		want  []time.Time
+	}{
+		{
+			name:  "weekly by count",
+			rule:  Rule{Frequency: "weekly", Interval: 1, Count: 3},
+			first: date(2026, 1, 5),
+			want:  []time.Time{date(2026, 1, 5), date(2026, 1, 12), date(2026, 1, 19)},
+		},
+		{
+			name:  "fortnightly until, inclusive",
+			rule:  Rule{Frequency: "weekly", Interval: 2, Until: &until},
+			first: date(2026, 1, 4),
+			want:  []time.Time{date(2026, 1, 4), date(2026, 1, 18), date(2026, 2, 1)},
+		},
+		{
+			name:  "monthly on the 31st clamps without drifting",
+			rule:  Rule{Frequency: "monthly", Interval: 1, Count: 4},
+			first: date(2026, 1, 31),
+			want:  []time.Time{date(2026, 1, 31), date(2026, 2, 28), date(2026, 3, 31), date(2026, 4, 30)},
+		},
+		{
+			name:  "quarterly",
+			rule:  Rule{Frequency: "monthly", Interval: 3, Count: 2},
+			first: date(2026, 11, 15),
+			want:  []time.Time{date(2026, 11, 15), date(2027, 2, 15)},
+		},
+	}
+	for _, tt := range tests {
+		got := tt.rule.Occurrences(tt.first)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %d occurrences %v, want %v", tt.name, len(got), got, tt.want)
+			continue
+		}
+		for i := range got {
+			if !got[i].Equal(tt.want[i]) {
+				t.Errorf("%s: occurrence %d = %s, want %s", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestRuleOccurrencesCapped(t *testing.T) {
+	until := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	rule := Rule{Frequency: "weekly", Interval: 1, Until: &until}
+	if got := rule.Occurrences(time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)); len(got) != MaxOccurrences {
+		t.Errorf("got %d occurrences, want the cap of %d", len(got), MaxOccurrences)
+	}
+}
+
+func TestAddMonthsClamped(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skip("Europe/London time zone data not available")
+	}
+	tests := []struct {
+		name   string
+		t      time.Time
+		months int
+		want   time.Time
+	}{
+		{"same day", time.Date(2026, 1, 15, 9, 0, 0, 0, time.UTC), 1, time.Date(2026, 2, 15, 9, 0, 0, 0, time.UTC)},
+		{"month end", time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC), 1, time.Date(2026, 2, 28, 9, 0, 0, 0, time.UTC)},
+		{"leap year", time.Date(2028, 1, 31, 9, 0, 0, 0, time.UTC), 1, time.Date(2028, 2, 29, 9, 0, 0, 0, time.UTC)},
+		{"30-day month", time.Date(2026, 3, 31, 9, 0, 0, 0, time.UTC), 1, time.Date(2026, 4, 30, 9, 0, 0, 0, time.UTC)},
+		{"across the year", time.Date(2026, 12, 31, 9, 0, 0, 0, time.UTC), 2, time.Date(2027, 2, 28, 9, 0, 0, 0, time.UTC)},
+		{"zero months", time.Date(2026, 5, 31, 9, 0, 0, 0, time.UTC), 0, time.Date(2026, 5, 31, 9, 0, 0, 0, time.UTC)},
+		// The wall-clock time is kept when the UTC offset changes in between
+		{"across DST", time.Date(2026, 3, 10, 9, 0, 0, 0, london), 1, time.Date(2026, 4, 10, 9, 0, 0, 0, london)},
+		{"back from DST", time.Date(2026, 10, 20, 9, 0, 0, 0, london), 1, time.Date(2026, 11, 20, 9, 0, 0, 0, london)},
+	}
+	for _, tt := range tests {
+		got := addMonthsClamped(tt.t, tt.months)
+		if !got.Equal(tt.want) {
+			t.Errorf("%s: addMonthsClamped(%s, %d) = %s, want %s", tt.name, tt.t, tt.months, got, tt.want)
+		}
+	}
+}
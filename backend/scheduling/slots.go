@@ -0,0 +1,123 @@
+// Package scheduling turns doctors' weekly working hours, schedule
+// exceptions and existing bookings into bookable appointment slots.
+package scheduling
+
+import (
+	"dementicare-backend/models"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const DefaultDurationMinutes = 30
+
+// Default slot length per appointment type, in minutes
+var DefaultDurations = map[string]int{
+	"consultation": 30,
+	"follow-up":    20,
+	"checkup":      20,
+	"emergency":    15,
+}
+
+// Window is a half-open time range [Start, End)
+type Window struct {
+	Start time.Time
+	End   time.Time
+}
+
+func (w Window) Overlaps(o Window) bool {
+	return w.Start.Before(o.End) && o.Start.Before(w.End)
+}
+
+type Slot struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// NormalizeType lower-cases and trims an appointment type so that
+// "Follow-up" and "follow-up " share a duration.
+func NormalizeType(appointmentType string) string {
+	return strings.ToLower(strings.TrimSpace(appointmentType))
+}
+
+// ParseClock parses an HH:MM time of day into minutes after midnight
+func ParseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ValidateSchedule checks a weekly template block
+func ValidateSchedule(s models.DoctorSchedule) error {
+	if s.Weekday < 0 || s.Weekday > 6 {
+		return errors.New("weekday must be between 0 (Sunday) and 6 (Saturday)")
+	}
+	start, err := ParseClock(s.StartTime)
+	if err != nil {
+		return err
+	}
+	end, err := ParseClock(s.EndTime)
+	if err != nil {
+		return err
+	}
+	if end <= start {
+		return errors.New("end_time must be after start_time")
+	}
+	return nil
+}
+
+// GenerateSlots lays slots of the given duration end to end across every
+// working block between from and to, then drops slots that overlap an
+// exception or a busy window. Working hours are read in loc.
+func GenerateSlots(templates []models.DoctorSchedule, blocked []Window, from, to time.Time, duration time.Duration, loc *time.Location) []Slot {
+	slots := []Slot{}
+	if duration <= 0 || !from.Before(to) {
+		return slots
+	}
+
+	byWeekday := map[time.Weekday][]models.DoctorSchedule{}
+	for _, t := range templates {
+		byWeekday[time.Weekday(t.Weekday)] = append(byWeekday[time.Weekday(t.Weekday)], t)
+	}
+
+	first := from.In(loc)
+	day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc)
+	for ; day.Before(to); day = day.AddDate(0, 0, 1) {
+		for _, t := range byWeekday[day.Weekday()] {
+			startMin, err1 := ParseClock(t.StartTime)
+			endMin, err2 := ParseClock(t.EndTime)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			blockEnd := clockOn(day, endMin, loc)
+			for start := clockOn(day, startMin, loc); !start.Add(duration).After(blockEnd); start = start.Add(duration) {
+				slot := Window{Start: start, End: start.Add(duration)}
+				if start.Before(from) || slot.End.After(to) || overlapsAny(slot, blocked) {
+					continue
+				}
+				slots = append(slots, Slot{Start: slot.Start, End: slot.End})
+			}
+		}
+	}
+
+	sort.Slice(slots, func(i, j int) bool { return slots[i].Start.Before(slots[j].Start) })
+	return slots
+}
+
+// clockOn returns the instant minutes after midnight on day, in loc
+func clockOn(day time.Time, minutes int, loc *time.Location) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), minutes/60, minutes%60, 0, 0, loc)
+}
+
+func overlapsAny(w Window, windows []Window) bool {
+	for _, o := range windows {
+		if w.Overlaps(o) {
+			return true
+		}
+	}
+	return false
+}
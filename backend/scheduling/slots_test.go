@@ -0,0 +1,183 @@
+package scheduling
+
+import (
+	"dementicare-backend/models"
+	"testing"
+	"time"
+)
+
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"00:00", 0, false},
+		{"09:30", 570, false},
+		{" 23:59 ", 1439, false},
+		{"24:00", 0, true},
+		{"9am", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseClock(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseClock(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseClock(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule models.DoctorSchedule
+		wantErr  bool
+	}{
+		{"valid", models.DoctorSchedule{Weekday: 1, StartTime: "09:00", EndTime: "17:00"}, false},
+		{"weekday out of range", models.DoctorSchedule{Weekday: 7, StartTime: "09:00", EndTime: "17:00"}, true},
+		{"bad start", models.DoctorSchedule{Weekday: 1, StartTime: "9", EndTime: "17:00"}, true},
+		{"end before start", models.DoctorSchedule{Weekday: 1, StartTime: "17:00", EndTime: "09:00"}, true},
+		{"empty block", models.DoctorSchedule{Weekday: 1, StartTime: "09:00", EndTime: "09:00"}, true},
+	}
+	for _, tt := range tests {
+		if err := ValidateSchedule(tt.schedule); (err != nil) != tt.wantErr {
+			t.Errorf("%s: ValidateSchedule() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestGenerateSlots(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skip("Europe/London time zone data not available")
+	}
+	utc := func(s string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	// 2026-03-02 is a Monday; 2026-03-29 is the Sunday clocks go forward in London
+	monday := models.DoctorSchedule{Weekday: 1, StartTime: "09:00", EndTime: "10:30"}
+	sunday := models.DoctorSchedule{Weekday: 0, StartTime: "09:00", EndTime: "10:00"}
+
+	tests := []struct {
+		name      string
+		templates []models.DoctorSchedule
+		blocked   []Window
+		from, to  string
+		duration  time.Duration
+		loc       *time.Location
+		want      []string
+	}{
+		{
+			name:      "block split into slots",
+			templates: []models.DoctorSchedule{monday},
+			from:      "2026-03-02T00:00:00Z",
+			to:        "2026-03-03T00:00:00Z",
+			duration:  30 * time.Minute,
+			loc:       time.UTC,
+			want:      []string{"2026-03-02T09:00:00Z", "2026-03-02T09:30:00Z", "2026-03-02T10:00:00Z"},
+		},
+		{
+			name:      "slot that does not fit the block is dropped",
+			templates: []models.DoctorSchedule{monday},
+			from:      "2026-03-02T00:00:00Z",
+			to:        "2026-03-03T00:00:00Z",
+			duration:  40 * time.Minute,
+			loc:       time.UTC,
+			want:      []string{"2026-03-02T09:00:00Z", "2026-03-02T09:40:00Z"},
+		},
+		{
+			name:      "busy window removes overlapping slots",
+			templates: []models.DoctorSchedule{monday},
+			blocked:   []Window{{Start: utc("2026-03-02T09:15:00Z"), End: utc("2026-03-02T09:45:00Z")}},
+			from:      "2026-03-02T00:00:00Z",
+			to:        "2026-03-03T00:00:00Z",
+			duration:  30 * time.Minute,
+			loc:       time.UTC,
+			want:      []string{"2026-03-02T10:00:00Z"},
+		},
+		{
+			name:      "window touching a slot does not block it",
+			templates: []models.DoctorSchedule{monday},
+			blocked:   []Window{{Start: utc("2026-03-02T08:00:00Z"), End: utc("2026-03-02T09:00:00Z")}},
+			from:      "2026-03-02T00:00:00Z",
+			to:        "2026-03-03T00:00:00Z",
+			duration:  30 * time.Minute,
+			loc:       time.UTC,
+			want:      []string{"2026-03-02T09:00:00Z", "2026-03-02T09:30:00Z", "2026-03-02T10:00:00Z"},
+		},
+		{
+			name:      "slots before from and after to are left out",
+			templates: []models.DoctorSchedule{monday},
+			from:      "2026-03-02T09:10:00Z",
+			to:        "2026-03-02T10:00:00Z",
+			duration:  30 * time.Minute,
+			loc:       time.UTC,
+			want:      []string{"2026-03-02T09:30:00Z"},
+		},
+		{
+			name:      "working hours follow the clinic zone across the DST change",
+			templates: []models.DoctorSchedule{sunday},
+			from:      "2026-03-22T00:00:00Z",
+			to:        "2026-03-30T00:00:00Z",
+			duration:  30 * time.Minute,
+			loc:       london,
+			want: []string{
+				"2026-03-22T09:00:00Z", "2026-03-22T09:30:00Z",
+				"2026-03-29T08:00:00Z", "2026-03-29T08:30:00Z",
+			},
+		},
+		{
+			name:     "no templates",
+			from:     "2026-03-02T00:00:00Z",
+			to:       "2026-03-03T00:00:00Z",
+			duration: 30 * time.Minute,
+			loc:      time.UTC,
+		},
+		{
+			name:      "empty range",
+			templates: []models.DoctorSchedule{monday},
+			from:      "2026-03-03T00:00:00Z",
+			to:        "2026-03-02T00:00:00Z",
+			duration:  30 * time.Minute,
+			loc:       time.UTC,
+		},
+	}
+	for _, tt := range tests {
+		slots := GenerateSlots(tt.templates, tt.blocked, utc(tt.from), utc(tt.to), tt.duration, tt.loc)
+		if len(slots) != len(tt.want) {
+			t.Errorf("%s: got %d slots %v, want %v", tt.name, len(slots), slots, tt.want)
+			continue
+		}
+		for i, slot := range slots {
+			if want := utc(tt.want[i]); !slot.Start.Equal(want) || !slot.End.Equal(want.Add(tt.duration)) {
+				t.Errorf("%s: slot %d = %s-%s, want start %s", tt.name, i, slot.Start.UTC(), slot.End.UTC(), want)
+			}
+		}
+	}
+}
+
+func TestWindowOverlaps(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2026, 3, 2, hour, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		a, b Window
+		want bool
+	}{
+		{Window{at(9), at(10)}, Window{at(9), at(10)}, true},
+		{Window{at(9), at(11)}, Window{at(10), at(12)}, true},
+		{Window{at(9), at(10)}, Window{at(10), at(11)}, false},
+		{Window{at(11), at(12)}, Window{at(9), at(10)}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Overlaps(tt.b); got != tt.want {
+			t.Errorf("%v.Overlaps(%v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
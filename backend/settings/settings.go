@@ -0,0 +1,31 @@
+// Package settings reads tunables from the environment.
+package settings
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// Duration reads a Go duration such as "90m" from the environment variable
+// name. An unset variable gives fallback; a malformed or negative one is
+// logged and gives fallback too.
+func Duration(name string, fallback time.Duration) time.Duration {
+	return duration(name, fallback, 0)
+}
+
+// PositiveDuration is Duration for settings where zero makes no sense, such
+// as ticker intervals.
+func PositiveDuration(name string, fallback time.Duration) time.Duration {
+	return duration(name, fallback, time.Nanosecond)
+}
+
+func duration(name string, fallback, min time.Duration) time.Duration {
+	if value := os.Getenv(name); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d >= min {
+			return d
+		}
+		log.Printf("Invalid %s %q, using %s", name, value, fallback)
+	}
+	return fallback
+}
@@ -0,0 +1,290 @@
+// Package spreadsheet reads tabular uploads (CSV and XLSX) into rows of
+// strings. Only the first worksheet of an XLSX workbook is read.
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxColumns is the widest sheet Excel allows (column XFD)
+	maxColumns = 16384
+	// maxHeaderColumns caps the width of an XLSX header row, and so of every
+	// row, as rows are padded with empty cells up to their last value
+	maxHeaderColumns = 256
+	// maxPartSize caps the decompressed size of each workbook part read
+	maxPartSize = 64 << 20
+)
+
+// ErrTooManyRows is returned once a file has more rows than the caller allows
+var ErrTooManyRows = errors.New("spreadsheet: too many rows")
+
+// ReadCSV reads the records, stripping a UTF-8 byte order mark if present. It
+// stops with ErrTooManyRows after maxRows records; 0 means no limit.
+func ReadCSV(r io.Reader, maxRows int) ([][]string, error) {
+	buffered := bufio.NewReader(r)
+	if bom, err := buffered.Peek(3); err == nil && bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		buffered.Discard(3)
+	}
+
+	reader := csv.NewReader(buffered)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	var rows [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if maxRows > 0 && len(rows) == maxRows {
+			return nil, ErrTooManyRows
+		}
+		rows = append(rows, record)
+	}
+}
+
+type sharedStrings struct {
+	Items []struct {
+		Text string `xml:"t"`
+		Runs []struct {
+			Text string `xml:"t"`
+		} `xml:"r"`
+	} `xml:"si"`
+}
+
+type workbook struct {
+	Sheets []struct {
+		RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type relationships struct {
+	Items []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type worksheetRow struct {
+	Cells []struct {
+		Ref    string `xml:"r,attr"`
+		Type   string `xml:"t,attr"`
+		Value  string `xml:"v"`
+		Inline struct {
+			Text string `xml:"t"`
+		} `xml:"is"`
+	} `xml:"c"`
+}
+
+// ReadXLSX reads the first worksheet of an XLSX workbook. Cell values are
+// returned as stored; dates therefore come back as serial numbers. The first
+// row is the header: it may be at most 256 columns wide, and cells of later
+// rows beyond its width are dropped. Reading stops with ErrTooManyRows after
+// maxRows rows; 0 means no limit.
+func ReadXLSX(r io.ReaderAt, size int64, maxRows int) ([][]string, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("spreadsheet: not an xlsx file: %w", err)
+	}
+
+	var strs sharedStrings
+	if err := decodeZipXML(archive, "xl/sharedStrings.xml", &strs); err != nil && !errors.Is(err, errMissingPart) {
+		return nil, err
+	}
+	shared := make([]string, len(strs.Items))
+	for i, item := range strs.Items {
+		if item.Text != "" || len(item.Runs) == 0 {
+			shared[i] = item.Text
+			continue
+		}
+		var b strings.Builder
+		for _, run := range item.Runs {
+			b.WriteString(run.Text)
+		}
+		shared[i] = b.String()
+	}
+
+	sheetPath, err := firstSheetPath(archive)
+	if err != nil {
+		return nil, err
+	}
+	// Rows are decoded one at a time so that the row limit is enforced before
+	// the whole sheet is in memory
+	var rows [][]string
+	err = readZipXML(archive, sheetPath, func(decoder *xml.Decoder) error {
+		for {
+			token, err := decoder.Token()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			start, ok := token.(xml.StartElement)
+			if !ok || start.Name.Local != "row" {
+				continue
+			}
+			if maxRows > 0 && len(rows) == maxRows {
+				return ErrTooManyRows
+			}
+			var row worksheetRow
+			if err := decoder.DecodeElement(&row, &start); err != nil {
+				return err
+			}
+			width := maxHeaderColumns
+			if len(rows) > 0 {
+				width = len(rows[0])
+			}
+			values, err := rowValues(row, shared, width, len(rows) == 0)
+			if err != nil {
+				return err
+			}
+			rows = append(rows, values)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// rowValues lays a row's cells out by column, padding gaps with empty cells.
+// Cells at or beyond width are an error in the header and dropped elsewhere.
+func rowValues(row worksheetRow, shared []string, width int, header bool) ([]string, error) {
+	var values []string
+	for i, cell := range row.Cells {
+		col, err := columnIndex(cell.Ref)
+		if err != nil {
+			return nil, err
+		}
+		if col < 0 {
+			col = i
+		}
+		if col >= width {
+			if header {
+				return nil, fmt.Errorf("spreadsheet: header row is wider than %d columns", width)
+			}
+			continue
+		}
+		for len(values) <= col {
+			values = append(values, "")
+		}
+
+		switch cell.Type {
+		case "s":
+			idx, err := strconv.Atoi(cell.Value)
+			if err != nil || idx < 0 || idx >= len(shared) {
+				return nil, fmt.Errorf("spreadsheet: bad shared string reference in %s", cell.Ref)
+			}
+			values[col] = shared[idx]
+		case "inlineStr":
+			values[col] = cell.Inline.Text
+		default:
+			values[col] = cell.Value
+		}
+	}
+	return values, nil
+}
+
+// firstSheetPath resolves the part holding the workbook's first sheet through
+// xl/workbook.xml and its relationships, as the part names vary between
+// spreadsheet programs
+func firstSheetPath(archive *zip.Reader) (string, error) {
+	var book workbook
+	if err := decodeZipXML(archive, "xl/workbook.xml", &book); err != nil {
+		if errors.Is(err, errMissingPart) {
+			return "xl/worksheets/sheet1.xml", nil
+		}
+		return "", err
+	}
+	if len(book.Sheets) == 0 {
+		return "", errors.New("spreadsheet: workbook has no sheets")
+	}
+
+	var rels relationships
+	if err := decodeZipXML(archive, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return "", err
+	}
+	for _, rel := range rels.Items {
+		if rel.ID != book.Sheets[0].RelID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return "", errors.New("spreadsheet: first sheet not found in workbook relationships")
+}
+
+var (
+	errMissingPart  = errors.New("spreadsheet: missing workbook part")
+	errPartTooLarge = errors.New("spreadsheet: workbook part too large")
+)
+
+func decodeZipXML(archive *zip.Reader, name string, v interface{}) error {
+	return readZipXML(archive, name, func(decoder *xml.Decoder) error {
+		return decoder.Decode(v)
+	})
+}
+
+// readZipXML hands a decoder over the named part to read
+func readZipXML(archive *zip.Reader, name string, read func(*xml.Decoder) error) error {
+	for _, f := range archive.File {
+		if f.Name != name {
+			continue
+		}
+		if f.UncompressedSize64 > maxPartSize {
+			return fmt.Errorf("%w: %s", errPartTooLarge, name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		// The declared size can be forged, so the reader is capped as well
+		limited := &io.LimitedReader{R: rc, N: maxPartSize + 1}
+		if err := read(xml.NewDecoder(limited)); err != nil {
+			if limited.N <= 0 {
+				return fmt.Errorf("%w: %s", errPartTooLarge, name)
+			}
+			return err
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %s", errMissingPart, name)
+}
+
+// columnIndex converts a cell reference such as "C7" to a zero-based column.
+// It returns -1 when the reference has no column letters.
+func columnIndex(ref string) (int, error) {
+	col := 0
+	n := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+		n++
+		if col > maxColumns {
+			return 0, fmt.Errorf("spreadsheet: column of cell %s is out of range", ref)
+		}
+	}
+	if n == 0 {
+		return -1, nil
+	}
+	return col - 1, nil
+}
@@ -0,0 +1,177 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestColumnIndex(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    int
+		wantErr bool
+	}{
+		{"A1", 0, false},
+		{"C7", 2, false},
+		{"Z10", 25, false},
+		{"AA1", 26, false},
+		{"XFD1", maxColumns - 1, false},
+		{"XFE1", 0, true},
+		{"XFDZZZZ1", 0, true},
+		{"12", -1, false},
+		{"", -1, false},
+	}
+	for _, tt := range tests {
+		got, err := columnIndex(tt.ref)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("columnIndex(%q) error = %v, wantErr %v", tt.ref, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("columnIndex(%q) = %d, want %d", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		maxRows int
+		want    [][]string
+		wantErr error
+	}{
+		{"plain", "a,b\n1,2\n", 0, [][]string{{"a", "b"}, {"1", "2"}}, nil},
+		{"byte order mark", "\xef\xbb\xbfa,b\n", 0, [][]string{{"a", "b"}}, nil},
+		{"ragged rows", "a,b,c\n1\n", 0, [][]string{{"a", "b", "c"}, {"1"}}, nil},
+		{"leading spaces", "a, b\n", 0, [][]string{{"a", "b"}}, nil},
+		{"empty", "", 0, nil, nil},
+		{"at the row limit", "a\n1\n", 2, [][]string{{"a"}, {"1"}}, nil},
+		{"over the row limit", "a\n1\n2\n", 2, nil, ErrTooManyRows},
+	}
+	for _, tt := range tests {
+		got, err := ReadCSV(strings.NewReader(tt.in), tt.maxRows)
+		if err != tt.wantErr {
+			t.Errorf("%s: ReadCSV() error = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ReadCSV() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+const (
+	testWorkbook = `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Data" sheetId="1" r:id="rId3"/><sheet name="Other" sheetId="2" r:id="rId1"/></sheets></workbook>`
+	testRels = `<Relationships>` +
+		`<Relationship Id="rId1" Target="worksheets/sheet1.xml"/>` +
+		`<Relationship Id="rId3" Target="worksheets/data.xml"/></Relationships>`
+	testShared = `<sst><si><t>drug_a</t></si><si><r><t>done</t></r><r><t>pezil</t></r></si></sst>`
+	testSheet  = `<worksheet><sheetData>` +
+		`<row><c r="A1" t="s"><v>0</v></c><c r="C1" t="inlineStr"><is><t>note</t></is></c></row>` +
+		`<row><c r="A2" t="s"><v>1</v></c><c r="B2"><v>42</v></c></row>` +
+		`</sheetData></worksheet>`
+)
+
+func xlsx(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range parts {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadXLSX(t *testing.T) {
+	tests := []struct {
+		name    string
+		parts   map[string]string
+		maxRows int
+		want    [][]string
+		wantErr bool
+	}{
+		{
+			name: "first sheet resolved through the workbook",
+			parts: map[string]string{
+				"xl/workbook.xml":            testWorkbook,
+				"xl/_rels/workbook.xml.rels": testRels,
+				"xl/sharedStrings.xml":       testShared,
+				"xl/worksheets/data.xml":     testSheet,
+				"xl/worksheets/sheet1.xml":   `<worksheet><sheetData><row><c r="A1"><v>wrong sheet</v></c></row></sheetData></worksheet>`,
+			},
+			want: [][]string{{"drug_a", "", "note"}, {"donepezil", "42"}},
+		},
+		{
+			name:  "sheet1 without a workbook part",
+			parts: map[string]string{"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row><c><v>1</v></c><c><v>2</v></c></row></sheetData></worksheet>`},
+			want:  [][]string{{"1", "2"}},
+		},
+		{
+			name: "cells beyond the header are dropped",
+			parts: map[string]string{"xl/worksheets/sheet1.xml": `<worksheet><sheetData>` +
+				`<row><c r="A1"><v>a</v></c><c r="B1"><v>b</v></c></row>` +
+				`<row><c r="A2"><v>1</v></c><c r="C2"><v>3</v></c><c r="XFD2"><v>4</v></c></row>` +
+				`</sheetData></worksheet>`},
+			want: [][]string{{"a", "b"}, {"1"}},
+		},
+		{
+			name:    "header too wide",
+			parts:   map[string]string{"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row><c r="IW1"><v>1</v></c></row></sheetData></worksheet>`},
+			wantErr: true,
+		},
+		{
+			name:    "at the row limit",
+			parts:   map[string]string{"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row><c><v>a</v></c></row><row/></sheetData></worksheet>`},
+			maxRows: 2,
+			want:    [][]string{{"a"}, nil},
+		},
+		{
+			name:    "over the row limit",
+			parts:   map[string]string{"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row/><row/><row/></sheetData></worksheet>`},
+			maxRows: 2,
+			wantErr: true,
+		},
+		{
+			name:    "bad shared string reference",
+			parts:   map[string]string{"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row><c r="A1" t="s"><v>5</v></c></row></sheetData></worksheet>`},
+			wantErr: true,
+		},
+		{
+			name:    "column out of range",
+			parts:   map[string]string{"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row><c r="XFDZZZZ1"><v>1</v></c></row></sheetData></worksheet>`},
+			wantErr: true,
+		},
+		{
+			name:    "no sheet",
+			parts:   map[string]string{"xl/styles.xml": `<styleSheet/>`},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		data := xlsx(t, tt.parts)
+		got, err := ReadXLSX(bytes.NewReader(data), int64(len(data)), tt.maxRows)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: ReadXLSX() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ReadXLSX() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := ReadXLSX(strings.NewReader("a,b"), 3, 0); err == nil {
+		t.Error("ReadXLSX() of a CSV file succeeded, want an error")
+	}
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	s, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := s.Save("patients/1/abc", strings.NewReader("scan"))
+	if err != nil || n != 4 {
+		t.Fatalf("Save() = %d, %v, want 4 bytes", n, err)
+	}
+	if _, err := s.Save("patients/1/abc", strings.NewReader("other")); err == nil {
+		t.Error("Save() over an existing key succeeded, want an error")
+	}
+
+	r, err := s.Open("patients/1/abc")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "scan" {
+		t.Errorf("Open() read %q, want %q", data, "scan")
+	}
+
+	if err := s.Delete("patients/1/abc"); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if err := s.Delete("patients/1/abc"); err != nil {
+		t.Errorf("Delete() of a missing key error = %v, want nil", err)
+	}
+	if _, err := s.Open("patients/1/abc"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open() after Delete() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLocalStorageKeys(t *testing.T) {
+	s, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"", "/", "../outside", "patients/../../outside", "patients/1/.."} {
+		if _, err := s.Save(key, strings.NewReader("x")); err == nil {
+			t.Errorf("Save(%q) succeeded, want an invalid key error", key)
+		}
+	}
+}
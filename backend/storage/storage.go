@@ -0,0 +1,17 @@
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned when a stored object does not exist
+var ErrNotFound = errors.New("storage: object not found")
+
+// Storage is the backend used to keep uploaded file contents.
+// Keys are slash-separated paths such as "patients/12/3f9a...".
+type Storage interface {
+	Save(key string, r io.Reader) (int64, error)
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
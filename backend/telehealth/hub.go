@@ -0,0 +1,262 @@
+package telehealth
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// Message is the JSON envelope exchanged over the signaling socket.
+//
+// Clients send "offer", "answer" and "ice-candidate" with a "to" peer and an
+// opaque payload, which is relayed as is. The host also sends "admit",
+// "deny" and "remove" naming a peer. The server sends "waiting", "admitted"
+// (with the peers already in the call), "denied", "removed", "waiting-room"
+// (to the host), "peer-joined", "peer-left" and "error".
+type Message struct {
+	Type    string          `json:"type"`
+	From    string          `json:"from,omitempty"`
+	To      string          `json:"to,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+	Peers   []PeerInfo      `json:"peers,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// PeerInfo describes a participant to the others
+type PeerInfo struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+type peer struct {
+	PeerInfo
+	conn *websocket.Conn
+	send chan Message
+}
+
+type room struct {
+	admitted map[string]*peer
+	waiting  map[string]*peer
+}
+
+// Hub keeps the rooms of the video visits in progress. Rooms live in memory
+// and disappear when the last participant leaves.
+type Hub struct {
+	mu    sync.Mutex
+	rooms map[string]*room
+}
+
+func NewHub() *Hub {
+	return &Hub{rooms: map[string]*room{}}
+}
+
+// sendBuffer is how many messages may queue for a slow client before it is
+// dropped
+const sendBuffer = 64
+
+// Serve runs one participant's socket until it closes or the token expires.
+// The host is admitted straight away; guests wait until the host admits them.
+func (h *Hub) Serve(conn *websocket.Conn, claims RoomClaims) {
+	p := &peer{
+		PeerInfo: PeerInfo{ID: strconv.FormatUint(uint64(claims.UserID), 10), Name: claims.Name, Role: claims.Role},
+		conn:     conn,
+		send:     make(chan Message, sendBuffer),
+	}
+
+	done := make(chan struct{})
+	go p.writeLoop(done)
+
+	h.join(claims.Room, p)
+	log.Printf("Telehealth peer joined - Room: %s, User: %s, Role: %s", claims.Room, p.ID, p.Role)
+
+	if claims.ExpiresAt != nil {
+		timer := time.AfterFunc(time.Until(claims.ExpiresAt.Time), func() { conn.Close() })
+		defer timer.Stop()
+	}
+
+	for {
+		var msg Message
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			break
+		}
+		h.handle(claims.Room, p, msg)
+	}
+
+	h.leave(claims.Room, p)
+	close(done)
+	conn.Close()
+	log.Printf("Telehealth peer left - Room: %s, User: %s", claims.Room, p.ID)
+}
+
+func (p *peer) writeLoop(done chan struct{}) {
+	for {
+		select {
+		case msg := <-p.send:
+			if err := websocket.JSON.Send(p.conn, msg); err != nil {
+				p.conn.Close()
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// deliver queues a message without blocking the hub; a client that cannot
+// keep up is disconnected
+func (p *peer) deliver(msg Message) {
+	select {
+	case p.send <- msg:
+	default:
+		p.conn.Close()
+	}
+}
+
+func (h *Hub) join(name string, p *peer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := h.rooms[name]
+	if r == nil {
+		r = &room{admitted: map[string]*peer{}, waiting: map[string]*peer{}}
+		h.rooms[name] = r
+	}
+
+	// A reconnect replaces the previous socket and keeps the admission
+	wasAdmitted := false
+	if old := r.admitted[p.ID]; old != nil {
+		wasAdmitted = true
+		old.conn.Close()
+	}
+	if old := r.waiting[p.ID]; old != nil {
+		old.conn.Close()
+	}
+	delete(r.waiting, p.ID)
+	delete(r.admitted, p.ID)
+
+	if p.Role == RoleHost || wasAdmitted {
+		r.admit(p)
+	} else {
+		r.waiting[p.ID] = p
+		p.deliver(Message{Type: "waiting"})
+	}
+	r.notifyHost()
+}
+
+func (h *Hub) leave(name string, p *peer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := h.rooms[name]
+	if r == nil {
+		return
+	}
+	// Ignore sockets that were already replaced by a reconnect
+	if r.admitted[p.ID] == p {
+		delete(r.admitted, p.ID)
+		r.broadcast(Message{Type: "peer-left", From: p.ID}, p.ID)
+	}
+	if r.waiting[p.ID] == p {
+		delete(r.waiting, p.ID)
+	}
+	r.notifyHost()
+
+	if len(r.admitted) == 0 && len(r.waiting) == 0 {
+		delete(h.rooms, name)
+	}
+}
+
+func (h *Hub) handle(name string, p *peer, msg Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := h.rooms[name]
+	if r == nil || r.admitted[p.ID] != p {
+		p.deliver(Message{Type: "error", Error: "Wait for the doctor to admit you"})
+		return
+	}
+
+	switch msg.Type {
+	case "offer", "answer", "ice-candidate":
+		target := r.admitted[msg.To]
+		if target == nil {
+			p.deliver(Message{Type: "error", Error: "Unknown participant " + msg.To})
+			return
+		}
+		target.deliver(Message{Type: msg.Type, From: p.ID, To: msg.To, Payload: msg.Payload})
+
+	case "admit", "deny", "remove":
+		if p.Role != RoleHost {
+			p.deliver(Message{Type: "error", Error: "Only the doctor can manage participants"})
+			return
+		}
+		r.manage(msg.Type, msg.To)
+
+	default:
+		p.deliver(Message{Type: "error", Error: "Unknown message type " + msg.Type})
+	}
+}
+
+// manage applies a host's admit, deny or remove to a participant
+func (r *room) manage(action, id string) {
+	switch action {
+	case "admit":
+		if guest := r.waiting[id]; guest != nil {
+			delete(r.waiting, id)
+			r.admit(guest)
+		}
+	case "deny":
+		if guest := r.waiting[id]; guest != nil {
+			delete(r.waiting, id)
+			guest.deliver(Message{Type: "denied"})
+		}
+	case "remove":
+		if guest := r.admitted[id]; guest != nil && guest.Role != RoleHost {
+			delete(r.admitted, id)
+			guest.deliver(Message{Type: "removed"})
+			r.broadcast(Message{Type: "peer-left", From: id}, id)
+		}
+	}
+	r.notifyHost()
+}
+
+// admit moves a peer into the call, tells them who is there and tells the
+// others they joined. The newcomer is expected to send the offers.
+func (r *room) admit(p *peer) {
+	r.admitted[p.ID] = p
+	p.deliver(Message{Type: "admitted", Peers: r.peers(r.admitted, p.ID)})
+	r.broadcast(Message{Type: "peer-joined", From: p.ID, Peers: []PeerInfo{p.PeerInfo}}, p.ID)
+}
+
+func (r *room) broadcast(msg Message, except string) {
+	for id, other := range r.admitted {
+		if id != except {
+			other.deliver(msg)
+		}
+	}
+}
+
+// notifyHost sends the current waiting room to the hosts
+func (r *room) notifyHost() {
+	waiting := r.peers(r.waiting, "")
+	for _, p := range r.admitted {
+		if p.Role == RoleHost {
+			p.deliver(Message{Type: "waiting-room", Peers: waiting})
+		}
+	}
+}
+
+func (r *room) peers(set map[string]*peer, except string) []PeerInfo {
+	list := make([]PeerInfo, 0, len(set))
+	for id, p := range set {
+		if id != except {
+			list = append(list, p.PeerInfo)
+		}
+	}
+	return list
+}
@@ -0,0 +1,121 @@
+package telehealth
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// dial connects to the hub as the participant the claims describe
+func dial(t *testing.T, hub *Hub, claims RoomClaims) *websocket.Conn {
+	t.Helper()
+	server := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) { hub.Serve(conn, claims) }))
+	t.Cleanup(server.Close)
+	conn, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http"), "", server.URL)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// expect reads the next message and checks its type
+func expect(t *testing.T, conn *websocket.Conn, want string) Message {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg Message
+	if err := websocket.JSON.Receive(conn, &msg); err != nil {
+		t.Fatalf("waiting for %s: %v", want, err)
+	}
+	if msg.Type != want {
+		t.Fatalf("got %+v, want a %s message", msg, want)
+	}
+	return msg
+}
+
+func TestHubWaitingRoom(t *testing.T) {
+	hub := NewHub()
+	host := dial(t, hub, RoomClaims{Room: "appointment-1", UserID: 1, Name: "Dr Smith", Role: RoleHost})
+	expect(t, host, "admitted")
+	expect(t, host, "waiting-room")
+
+	// Guests wait and cannot signal until the host admits them
+	guest := dial(t, hub, RoomClaims{Room: "appointment-1", UserID: 10, Name: "Edith", Role: RoleGuest})
+	expect(t, guest, "waiting")
+	if room := expect(t, host, "waiting-room"); len(room.Peers) != 1 || room.Peers[0].ID != "10" {
+		t.Fatalf("waiting room %+v, want the guest", room.Peers)
+	}
+	websocket.JSON.Send(guest, Message{Type: "offer", To: "1"})
+	expect(t, guest, "error")
+
+	websocket.JSON.Send(host, Message{Type: "admit", To: "10"})
+	if admitted := expect(t, guest, "admitted"); len(admitted.Peers) != 1 || admitted.Peers[0].Role != RoleHost {
+		t.Errorf("admitted with peers %+v, want the host", admitted.Peers)
+	}
+	if joined := expect(t, host, "peer-joined"); joined.From != "10" {
+		t.Errorf("peer-joined from %q, want the guest", joined.From)
+	}
+	if room := expect(t, host, "waiting-room"); len(room.Peers) != 0 {
+		t.Errorf("waiting room %+v, want it empty", room.Peers)
+	}
+
+	// Admitted guests signal the host but cannot manage the room
+	websocket.JSON.Send(guest, Message{Type: "offer", To: "1", Payload: []byte(`{"sdp":"v=0"}`)})
+	if offer := expect(t, host, "offer"); offer.From != "10" || string(offer.Payload) != `{"sdp":"v=0"}` {
+		t.Errorf("relayed %+v, want the guest's offer", offer)
+	}
+	websocket.JSON.Send(guest, Message{Type: "remove", To: "1"})
+	expect(t, guest, "error")
+
+	websocket.JSON.Send(host, Message{Type: "remove", To: "10"})
+	expect(t, guest, "removed")
+}
+
+func TestHubDeny(t *testing.T) {
+	hub := NewHub()
+	host := dial(t, hub, RoomClaims{Room: "appointment-1", UserID: 1, Role: RoleHost})
+	expect(t, host, "admitted")
+	expect(t, host, "waiting-room")
+	guest := dial(t, hub, RoomClaims{Room: "appointment-1", UserID: 10, Role: RoleGuest})
+	expect(t, guest, "waiting")
+	expect(t, host, "waiting-room")
+
+	websocket.JSON.Send(host, Message{Type: "deny", To: "10"})
+	expect(t, guest, "denied")
+	if room := expect(t, host, "waiting-room"); len(room.Peers) != 0 {
+		t.Errorf("waiting room %+v, want it empty", room.Peers)
+	}
+}
+
+func TestParseToken(t *testing.T) {
+	secret := []byte("room-secret")
+	claims := RoomClaims{Room: "appointment-1", AppointmentID: 1, UserID: 10, Role: RoleGuest}
+	valid, _ := IssueToken(secret, claims, time.Now().Add(time.Hour))
+	expired, _ := IssueToken(secret, claims, time.Now().Add(-time.Minute))
+	noRole, _ := IssueToken(secret, RoomClaims{Room: "appointment-1", UserID: 10}, time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name   string
+		secret []byte
+		token  string
+		valid  bool
+	}{
+		{"valid", secret, valid, true},
+		{"other secret", []byte("api-secret"), valid, false},
+		{"expired", secret, expired, false},
+		{"no role", secret, noRole, false},
+		{"garbage", secret, "not-a-token", false},
+	}
+	for _, tt := range tests {
+		got, err := ParseToken(tt.secret, tt.token)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: error = %v, want valid %v", tt.name, err, tt.valid)
+		}
+		if tt.valid && (got.Room != claims.Room || got.UserID != 10 || got.Role != RoleGuest) {
+			t.Errorf("%s: claims = %+v, want %+v", tt.name, got, claims)
+		}
+	}
+}
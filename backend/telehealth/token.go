@@ -0,0 +1,58 @@
+// Package telehealth hosts WebRTC signaling for video visits: room tokens
+// and a WebSocket hub with a waiting room.
+package telehealth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Roles in a room. The host (the appointment's doctor) admits guests from
+// the waiting room.
+const (
+	RoleHost  = "host"
+	RoleGuest = "guest"
+)
+
+// RoomClaims identify who may join which room, and until when
+type RoomClaims struct {
+	Room          string `json:"room"`
+	AppointmentID uint   `json:"appointment_id"`
+	UserID        uint   `json:"uid"`
+	Name          string `json:"name"`
+	Role          string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+const tokenAudience = "telehealth-room"
+
+// IssueToken signs a room token that expires at expiresAt. The secret must
+// differ from the API token secret so room tokens cannot call the API.
+func IssueToken(secret []byte, claims RoomClaims, expiresAt time.Time) (string, error) {
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		Audience:  jwt.ClaimStrings{tokenAudience},
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+}
+
+// ParseToken verifies a room token and returns its claims
+func ParseToken(secret []byte, token string) (RoomClaims, error) {
+	var claims RoomClaims
+	parsed, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return secret, nil
+	}, jwt.WithAudience(tokenAudience), jwt.WithExpirationRequired())
+	if err != nil {
+		return claims, err
+	}
+	if !parsed.Valid || claims.Room == "" || (claims.Role != RoleHost && claims.Role != RoleGuest) {
+		return claims, errors.New("invalid room token")
+	}
+	return claims, nil
+}
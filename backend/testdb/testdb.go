@@ -0,0 +1,36 @@
+// Package testdb opens throwaway SQLite databases for tests, so handlers and
+// jobs can run against real queries without a MySQL server.
+package testdb
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open creates an empty database in the test's temporary directory with the
+// tables of the given models. WAL mode lets a query outside a transaction
+// read while the transaction writes, and times are passed in UTC, as with
+// MySQL.
+func Open(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_journal_mode=WAL&_busy_timeout=5000"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("opening test database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("opening test database: %v", err)
+	}
+	db.ConnPool = utcPool{sqlDB}
+	db.Statement.ConnPool = db.ConnPool
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("migrating test database: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
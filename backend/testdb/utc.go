@@ -0,0 +1,86 @@
+package testdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// utcPool passes times to SQLite in UTC, as the MySQL connection's loc=UTC
+// does. SQLite stores times as text with their offset and compares them as
+// text, so a bound written in another zone would compare wrongly.
+type utcPool struct {
+	db *sql.DB
+}
+
+func (p utcPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return p.db.PrepareContext(ctx, query)
+}
+
+func (p utcPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return p.db.ExecContext(ctx, query, inUTC(args)...)
+}
+
+func (p utcPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return p.db.QueryContext(ctx, query, inUTC(args)...)
+}
+
+func (p utcPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return p.db.QueryRowContext(ctx, query, inUTC(args)...)
+}
+
+func (p utcPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	tx, err := p.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &utcTx{tx}, nil
+}
+
+func (p utcPool) GetDBConn() (*sql.DB, error) {
+	return p.db, nil
+}
+
+type utcTx struct {
+	*sql.Tx
+}
+
+func (t *utcTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return t.Tx.ExecContext(ctx, query, inUTC(args)...)
+}
+
+func (t *utcTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return t.Tx.QueryContext(ctx, query, inUTC(args)...)
+}
+
+func (t *utcTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return t.Tx.QueryRowContext(ctx, query, inUTC(args)...)
+}
+
+// inUTC converts time arguments, including those behind a driver.Valuer
+// such as gorm.DeletedAt, to UTC
+func inUTC(args []interface{}) []interface{} {
+	out := make([]interface{}, len(args))
+	for i, arg := range args {
+		if valuer, ok := arg.(driver.Valuer); ok {
+			if value, err := valuer.Value(); err == nil {
+				if t, ok := value.(time.Time); ok {
+					arg = t
+				}
+			}
+		}
+		switch v := arg.(type) {
+		case time.Time:
+			arg = v.UTC()
+		case *time.Time:
+			if v != nil {
+				arg = v.UTC()
+			}
+		}
+		out[i] = arg
+	}
+	return out
+}
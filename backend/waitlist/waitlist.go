@@ -0,0 +1,205 @@
+// Package waitlist offers freed appointment slots to waitlisted patients.
+package waitlist
+
+import (
+	"dementicare-backend/models"
+	"dementicare-backend/notify"
+	"dementicare-backend/scheduling"
+	"dementicare-backend/settings"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const defaultHold = 2 * time.Hour
+
+// Hold is how long a patient has to accept an offer (WAITLIST_HOLD,
+// default 2h). Offers never outlast the slot itself.
+func Hold() time.Duration {
+	return settings.PositiveDuration("WAITLIST_HOLD", defaultHold)
+}
+
+// OfferFreedSlot offers the time of a cancelled or rescheduled appointment to
+// the waitlist. Call it after the cancellation is committed.
+func OfferFreedSlot(db *gorm.DB, appointment models.Appointment) {
+	start := scheduling.AppointmentStart(appointment)
+	if _, err := OfferSlot(db, appointment.DoctorID, start, &appointment.ID); err != nil {
+		log.Printf("Error offering slot of appointment %d to the waitlist: %v", appointment.ID, err)
+	}
+}
+
+// OfferSlot holds the slot starting at start for the longest waiting patient
+// whose date window covers it and whose appointment type fits. Patients who
+// were already offered this slot are skipped. It returns nil if nobody can
+// take the slot.
+func OfferSlot(db *gorm.DB, doctorID uint, start time.Time, sourceAppointmentID *uint) (*models.SlotOffer, error) {
+	now := time.Now()
+	if !start.After(now) {
+		return nil, nil
+	}
+
+	loc := scheduling.Location()
+	local := start.In(loc)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+
+	var offer *models.SlotOffer
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := scheduling.LockDoctor(tx, doctorID); err != nil {
+			return err
+		}
+
+		var entries []models.WaitlistEntry
+		if err := tx.Where("doctor_id = ? AND status = ? AND earliest_date <= ? AND latest_date >= ?", doctorID, "waiting", start, day).
+			Order("created_at asc, id asc").Find(&entries).Error; err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			var previous int64
+			if err := tx.Model(&models.SlotOffer{}).Where("entry_id = ? AND start_at = ?", entry.ID, start).Count(&previous).Error; err != nil {
+				return err
+			}
+			if previous > 0 {
+				continue
+			}
+
+			if err := scheduling.CheckBookable(tx, doctorID, entry.Type, start, 0); err != nil {
+				if errors.Is(err, scheduling.ErrSlotTaken) || errors.Is(err, scheduling.ErrSlotUnavailable) {
+					continue
+				}
+				return err
+			}
+
+			expires := now.Add(Hold())
+			if expires.After(start) {
+				expires = start
+			}
+			offer = &models.SlotOffer{
+				EntryID:             entry.ID,
+				PatientID:           entry.PatientID,
+				DoctorID:            doctorID,
+				Type:                entry.Type,
+				StartAt:             start,
+				EndAt:               start.Add(scheduling.Duration(tx, doctorID, entry.Type)),
+				ExpiresAt:           expires,
+				Status:              "offered",
+				SourceAppointmentID: sourceAppointmentID,
+			}
+			if err := tx.Create(offer).Error; err != nil {
+				return err
+			}
+			return tx.Model(&models.WaitlistEntry{}).Where("id = ?", entry.ID).Update("status", "offered").Error
+		}
+		return nil
+	})
+	if err != nil || offer == nil {
+		return nil, err
+	}
+
+	log.Printf("Waitlist slot offered - Offer: %d, Entry: %d, Patient: %d, Doctor: %d, Start: %s", offer.ID, offer.EntryID, offer.PatientID, doctorID, start.Format(time.RFC3339))
+	if err := notifyOffer(db, *offer); err != nil {
+		log.Printf("Error queueing notification for offer %d: %v", offer.ID, err)
+	}
+	return offer, nil
+}
+
+// CloseOffer ends an open offer with the given status (declined, expired or
+// withdrawn), puts the entry back in the queue unless it was withdrawn, and
+// offers the slot to the next patient. It returns false if the offer was no
+// longer open.
+func CloseOffer(db *gorm.DB, offer models.SlotOffer, status string) (bool, error) {
+	now := time.Now()
+	closed := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.SlotOffer{}).Where("id = ? AND status = ?", offer.ID, "offered").
+			Updates(map[string]interface{}{"status": status, "responded_at": now})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		closed = true
+
+		entryStatus := "waiting"
+		if status == "withdrawn" {
+			entryStatus = "cancelled"
+		}
+		return tx.Model(&models.WaitlistEntry{}).Where("id = ? AND status = ?", offer.EntryID, "offered").
+			Update("status", entryStatus).Error
+	})
+	if err != nil || !closed {
+		return closed, err
+	}
+
+	if _, err := OfferSlot(db, offer.DoctorID, offer.StartAt, offer.SourceAppointmentID); err != nil {
+		log.Printf("Error passing slot of offer %d to the next patient: %v", offer.ID, err)
+	}
+	return true, nil
+}
+
+// ExpireOffers closes offers that were not answered in time and passes each
+// slot on to the next patient.
+func ExpireOffers(db *gorm.DB, now time.Time) error {
+	var expired []models.SlotOffer
+	if err := db.Where("status = ? AND expires_at <= ?", "offered", now).Find(&expired).Error; err != nil {
+		return err
+	}
+
+	for _, offer := range expired {
+		if _, err := CloseOffer(db, offer, "expired"); err != nil {
+			log.Printf("Error expiring offer %d: %v", offer.ID, err)
+		}
+	}
+	return nil
+}
+
+// ExpireEntries closes waiting entries whose date window has passed, so the
+// patient can join the doctor's waitlist again
+func ExpireEntries(db *gorm.DB, now time.Time) error {
+	local := now.In(scheduling.Location())
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, scheduling.Location())
+
+	result := db.Model(&models.WaitlistEntry{}).Where("status = ? AND latest_date < ?", "waiting", today).
+		Update("status", "expired")
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Expired %d waitlist entries", result.RowsAffected)
+	}
+	return nil
+}
+
+func notifyOffer(db *gorm.DB, offer models.SlotOffer) error {
+	recipients, err := notify.PatientRecipients(db, offer.PatientID)
+	if err != nil {
+		return err
+	}
+
+	var doctor models.User
+	db.Select("name").First(&doctor, offer.DoctorID)
+
+	what := "An appointment"
+	if offer.Type != "" {
+		what = "A " + strings.ToLower(offer.Type) + " appointment"
+	}
+	loc := scheduling.Location()
+	body := fmt.Sprintf("%s with Dr. %s on %s has become available. It is held until %s; accept it in the app to book it.",
+		what, doctor.Name, offer.StartAt.In(loc).Format("Monday 2 January at 15:04"), offer.ExpiresAt.In(loc).Format("15:04 on 2 January"))
+
+	for _, recipient := range recipients {
+		err := notify.Enqueue(db, models.Notification{
+			RecipientID: recipient,
+			Kind:        "waitlist_offer",
+			Subject:     "An earlier appointment is available",
+			Body:        body,
+			DedupeKey:   fmt.Sprintf("waitlist-offer:%d:%d", offer.ID, recipient),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
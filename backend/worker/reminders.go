@@ -0,0 +1,145 @@
+package worker
+
+import (
+	"dementicare-backend/models"
+	"dementicare-backend/notify"
+	"dementicare-backend/scheduling"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var defaultReminderOffsets = []time.Duration{24 * time.Hour, 2 * time.Hour}
+
+// reminderOffsets reads REMINDER_OFFSETS, a comma separated list of
+// durations before the appointment such as "24h,2h". Largest first.
+func reminderOffsets() []time.Duration {
+	value := os.Getenv("REMINDER_OFFSETS")
+	if value == "" {
+		return defaultReminderOffsets
+	}
+
+	var offsets []time.Duration
+	for _, part := range strings.Split(value, ",") {
+		offset, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil || offset <= 0 {
+			log.Printf("Invalid REMINDER_OFFSETS %q, using defaults", value)
+			return defaultReminderOffsets
+		}
+		offsets = append(offsets, offset)
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] > offsets[j] })
+	return offsets
+}
+
+// QueueReminders adds outbox rows for reminders that are due. A reminder is
+// due from offset before the start until the next smaller offset is due, so
+// a late booking only gets the most relevant reminder. Dedupe keys include
+// the start time, so running this repeatedly is safe and a moved appointment
+// gets fresh reminders.
+func QueueReminders(db *gorm.DB, offsets []time.Duration, now time.Time) error {
+	if len(offsets) == 0 {
+		return nil
+	}
+
+	var appointments []models.Appointment
+	if err := db.Where("status IN ? AND start_at > ? AND start_at <= ?", []string{"pending", "confirmed"},
+		now, now.Add(offsets[0])).Find(&appointments).Error; err != nil {
+		return err
+	}
+
+	for _, appointment := range appointments {
+		start := scheduling.AppointmentStart(appointment)
+		offset, ok := dueOffset(offsets, start, now)
+		if !ok {
+			continue
+		}
+
+		recipients, err := notify.PatientRecipients(db, appointment.PatientID)
+		if err != nil {
+			return err
+		}
+		subject, bodies := reminderText(db, appointment, start)
+
+		for _, recipient := range recipients {
+			appointmentID := appointment.ID
+			err := notify.Enqueue(db, models.Notification{
+				RecipientID:   recipient,
+				Kind:          "appointment_reminder",
+				Subject:       subject,
+				Body:          bodies[recipient == appointment.PatientID],
+				AppointmentID: &appointmentID,
+				StartsAt:      &start,
+				DedupeKey:     fmt.Sprintf("reminder:%d:%d:%s:%d", appointment.ID, start.Unix(), offset, recipient),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dueOffset returns the reminder offset whose window contains now
+func dueOffset(offsets []time.Duration, start, now time.Time) (time.Duration, bool) {
+	if !start.After(now) {
+		return 0, false
+	}
+	for i, offset := range offsets {
+		if now.Before(start.Add(-offset)) {
+			continue
+		}
+		if i+1 < len(offsets) && !now.Before(start.Add(-offsets[i+1])) {
+			continue
+		}
+		return offset, true
+	}
+	return 0, false
+}
+
+// reminderText returns the subject and the bodies for the patient (true) and
+// for caregivers (false)
+func reminderText(db *gorm.DB, appointment models.Appointment, start time.Time) (string, map[bool]string) {
+	var doctor, patient models.User
+	db.Select("name").First(&doctor, appointment.DoctorID)
+	db.Select("name").First(&patient, appointment.PatientID)
+
+	what := "an appointment"
+	if appointment.Type != "" {
+		what = "a " + strings.ToLower(appointment.Type) + " appointment"
+	}
+	when := start.In(scheduling.Location()).Format("Monday 2 January at 15:04")
+
+	return "Appointment reminder", map[bool]string{
+		true:  fmt.Sprintf("You have %s with Dr. %s on %s.", what, doctor.Name, when),
+		false: fmt.Sprintf("%s has %s with Dr. %s on %s.", patient.Name, what, doctor.Name, when),
+	}
+}
+
+// validReminder cancels reminders whose appointment was cancelled, deleted or
+// moved after they were queued.
+func validReminder(db *gorm.DB) notify.Validator {
+	return func(n models.Notification) (bool, error) {
+		if n.AppointmentID == nil || n.StartsAt == nil {
+			return true, nil
+		}
+
+		var appointment models.Appointment
+		result := db.Limit(1).Find(&appointment, *n.AppointmentID)
+		if result.Error != nil {
+			return false, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return false, nil
+		}
+		if appointment.Status != "pending" && appointment.Status != "confirmed" {
+			return false, nil
+		}
+		return scheduling.AppointmentStart(appointment).Equal(*n.StartsAt), nil
+	}
+}
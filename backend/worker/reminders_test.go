@@ -0,0 +1,52 @@
+package worker
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDueOffset(t *testing.T) {
+	offsets := []time.Duration{24 * time.Hour, 2 * time.Hour}
+	start := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		now    time.Time
+		want   time.Duration
+		wantOK bool
+	}{
+		{"too early", start.Add(-25 * time.Hour), 0, false},
+		{"24h window opens", start.Add(-24 * time.Hour), 24 * time.Hour, true},
+		{"inside the 24h window", start.Add(-5 * time.Hour), 24 * time.Hour, true},
+		{"2h window opens", start.Add(-2 * time.Hour), 2 * time.Hour, true},
+		{"just before the start", start.Add(-time.Minute), 2 * time.Hour, true},
+		{"at the start", start, 0, false},
+		{"after the start", start.Add(time.Hour), 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := dueOffset(offsets, start, tt.now)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("%s: dueOffset() = %s, %v, want %s, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestReminderOffsets(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []time.Duration
+	}{
+		{"", defaultReminderOffsets},
+		{"2h, 48h,30m", []time.Duration{48 * time.Hour, 2 * time.Hour, 30 * time.Minute}},
+		{"24h,soon", defaultReminderOffsets},
+		{"24h,-1h", defaultReminderOffsets},
+	}
+	defer os.Unsetenv("REMINDER_OFFSETS")
+	for _, tt := range tests {
+		os.Setenv("REMINDER_OFFSETS", tt.value)
+		if got := reminderOffsets(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("reminderOffsets(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
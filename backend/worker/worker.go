@@ -0,0 +1,52 @@
+// Package worker runs periodic background jobs inside the server process.
+package worker
+
+import (
+	"dementicare-backend/medication"
+	"dementicare-backend/notify"
+	"dementicare-backend/settings"
+	"dementicare-backend/waitlist"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// deliveryBatch is how many outbox rows are sent per run
+const deliveryBatch = 100
+
+// Start runs the jobs every WORKER_INTERVAL (default 1m) until the process
+// exits.
+func Start(db *gorm.DB, notifier notify.Notifier) {
+	interval := settings.PositiveDuration("WORKER_INTERVAL", time.Minute)
+	offsets := reminderOffsets()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			run(db, notifier, offsets, time.Now())
+			<-ticker.C
+		}
+	}()
+
+	log.Printf("Background worker started (every %s, reminders at %v)", interval, offsets)
+}
+
+func run(db *gorm.DB, notifier notify.Notifier, offsets []time.Duration, now time.Time) {
+	if err := QueueReminders(db, offsets, now); err != nil {
+		log.Printf("Error queueing reminders: %v", err)
+	}
+	if err := waitlist.ExpireOffers(db, now); err != nil {
+		log.Printf("Error expiring waitlist offers: %v", err)
+	}
+	if err := waitlist.ExpireEntries(db, now); err != nil {
+		log.Printf("Error expiring waitlist entries: %v", err)
+	}
+	if err := medication.MarkMissedDoses(db, now); err != nil {
+		log.Printf("Error marking missed doses: %v", err)
+	}
+	if err := notify.DeliverDue(db, notifier, validReminder(db), now, deliveryBatch); err != nil {
+		log.Printf("Error delivering notifications: %v", err)
+	}
+}